@@ -18,7 +18,19 @@ var _ RateLimitService = &RateLimitServiceMock{}
 //
 //		// make and configure a mocked RateLimitService
 //		mockedRateLimitService := &RateLimitServiceMock{
-//			SendNotificationFunc: func(sendNotificationParams domain.SendNotificationParams) error {
+//			GetDelayedNotificationsFunc: func() ([]*domain.DelayedNotification, error) {
+//				panic("mock out the GetDelayedNotifications method")
+//			},
+//			GetDigestsFunc: func() ([]*domain.Digest, error) {
+//				panic("mock out the GetDigests method")
+//			},
+//			GetQuotaFunc: func(getQuotaParams domain.GetQuotaParams) (*domain.Quota, error) {
+//				panic("mock out the GetQuota method")
+//			},
+//			GetShadowRejectionsFunc: func() []*domain.ShadowRejections {
+//				panic("mock out the GetShadowRejections method")
+//			},
+//			SendNotificationFunc: func(sendNotificationParams domain.SendNotificationParams) (*domain.Decision, error) {
 //				panic("mock out the SendNotification method")
 //			},
 //		}
@@ -28,22 +40,165 @@ var _ RateLimitService = &RateLimitServiceMock{}
 //
 //	}
 type RateLimitServiceMock struct {
+	// GetDelayedNotificationsFunc mocks the GetDelayedNotifications method.
+	GetDelayedNotificationsFunc func() ([]*domain.DelayedNotification, error)
+
+	// GetDigestsFunc mocks the GetDigests method.
+	GetDigestsFunc func() ([]*domain.Digest, error)
+
+	// GetQuotaFunc mocks the GetQuota method.
+	GetQuotaFunc func(getQuotaParams domain.GetQuotaParams) (*domain.Quota, error)
+
+	// GetShadowRejectionsFunc mocks the GetShadowRejections method.
+	GetShadowRejectionsFunc func() []*domain.ShadowRejections
+
 	// SendNotificationFunc mocks the SendNotification method.
-	SendNotificationFunc func(sendNotificationParams domain.SendNotificationParams) error
+	SendNotificationFunc func(sendNotificationParams domain.SendNotificationParams) (*domain.Decision, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// GetDelayedNotifications holds details about calls to the GetDelayedNotifications method.
+		GetDelayedNotifications []struct {
+		}
+		// GetDigests holds details about calls to the GetDigests method.
+		GetDigests []struct {
+		}
+		// GetQuota holds details about calls to the GetQuota method.
+		GetQuota []struct {
+			// GetQuotaParams is the getQuotaParams argument value.
+			GetQuotaParams domain.GetQuotaParams
+		}
+		// GetShadowRejections holds details about calls to the GetShadowRejections method.
+		GetShadowRejections []struct {
+		}
 		// SendNotification holds details about calls to the SendNotification method.
 		SendNotification []struct {
 			// SendNotificationParams is the sendNotificationParams argument value.
 			SendNotificationParams domain.SendNotificationParams
 		}
 	}
-	lockSendNotification sync.RWMutex
+	lockGetDelayedNotifications sync.RWMutex
+	lockGetDigests              sync.RWMutex
+	lockGetQuota                sync.RWMutex
+	lockGetShadowRejections     sync.RWMutex
+	lockSendNotification        sync.RWMutex
+}
+
+// GetDelayedNotifications calls GetDelayedNotificationsFunc.
+func (mock *RateLimitServiceMock) GetDelayedNotifications() ([]*domain.DelayedNotification, error) {
+	if mock.GetDelayedNotificationsFunc == nil {
+		panic("RateLimitServiceMock.GetDelayedNotificationsFunc: method is nil but RateLimitService.GetDelayedNotifications was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetDelayedNotifications.Lock()
+	mock.calls.GetDelayedNotifications = append(mock.calls.GetDelayedNotifications, callInfo)
+	mock.lockGetDelayedNotifications.Unlock()
+	return mock.GetDelayedNotificationsFunc()
+}
+
+// GetDelayedNotificationsCalls gets all the calls that were made to GetDelayedNotifications.
+// Check the length with:
+//
+//	len(mockedRateLimitService.GetDelayedNotificationsCalls())
+func (mock *RateLimitServiceMock) GetDelayedNotificationsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetDelayedNotifications.RLock()
+	calls = mock.calls.GetDelayedNotifications
+	mock.lockGetDelayedNotifications.RUnlock()
+	return calls
+}
+
+// GetDigests calls GetDigestsFunc.
+func (mock *RateLimitServiceMock) GetDigests() ([]*domain.Digest, error) {
+	if mock.GetDigestsFunc == nil {
+		panic("RateLimitServiceMock.GetDigestsFunc: method is nil but RateLimitService.GetDigests was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetDigests.Lock()
+	mock.calls.GetDigests = append(mock.calls.GetDigests, callInfo)
+	mock.lockGetDigests.Unlock()
+	return mock.GetDigestsFunc()
+}
+
+// GetDigestsCalls gets all the calls that were made to GetDigests.
+// Check the length with:
+//
+//	len(mockedRateLimitService.GetDigestsCalls())
+func (mock *RateLimitServiceMock) GetDigestsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetDigests.RLock()
+	calls = mock.calls.GetDigests
+	mock.lockGetDigests.RUnlock()
+	return calls
+}
+
+// GetQuota calls GetQuotaFunc.
+func (mock *RateLimitServiceMock) GetQuota(getQuotaParams domain.GetQuotaParams) (*domain.Quota, error) {
+	if mock.GetQuotaFunc == nil {
+		panic("RateLimitServiceMock.GetQuotaFunc: method is nil but RateLimitService.GetQuota was just called")
+	}
+	callInfo := struct {
+		GetQuotaParams domain.GetQuotaParams
+	}{
+		GetQuotaParams: getQuotaParams,
+	}
+	mock.lockGetQuota.Lock()
+	mock.calls.GetQuota = append(mock.calls.GetQuota, callInfo)
+	mock.lockGetQuota.Unlock()
+	return mock.GetQuotaFunc(getQuotaParams)
+}
+
+// GetQuotaCalls gets all the calls that were made to GetQuota.
+// Check the length with:
+//
+//	len(mockedRateLimitService.GetQuotaCalls())
+func (mock *RateLimitServiceMock) GetQuotaCalls() []struct {
+	GetQuotaParams domain.GetQuotaParams
+} {
+	var calls []struct {
+		GetQuotaParams domain.GetQuotaParams
+	}
+	mock.lockGetQuota.RLock()
+	calls = mock.calls.GetQuota
+	mock.lockGetQuota.RUnlock()
+	return calls
+}
+
+// GetShadowRejections calls GetShadowRejectionsFunc.
+func (mock *RateLimitServiceMock) GetShadowRejections() []*domain.ShadowRejections {
+	if mock.GetShadowRejectionsFunc == nil {
+		panic("RateLimitServiceMock.GetShadowRejectionsFunc: method is nil but RateLimitService.GetShadowRejections was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetShadowRejections.Lock()
+	mock.calls.GetShadowRejections = append(mock.calls.GetShadowRejections, callInfo)
+	mock.lockGetShadowRejections.Unlock()
+	return mock.GetShadowRejectionsFunc()
+}
+
+// GetShadowRejectionsCalls gets all the calls that were made to GetShadowRejections.
+// Check the length with:
+//
+//	len(mockedRateLimitService.GetShadowRejectionsCalls())
+func (mock *RateLimitServiceMock) GetShadowRejectionsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetShadowRejections.RLock()
+	calls = mock.calls.GetShadowRejections
+	mock.lockGetShadowRejections.RUnlock()
+	return calls
 }
 
 // SendNotification calls SendNotificationFunc.
-func (mock *RateLimitServiceMock) SendNotification(sendNotificationParams domain.SendNotificationParams) error {
+func (mock *RateLimitServiceMock) SendNotification(sendNotificationParams domain.SendNotificationParams) (*domain.Decision, error) {
 	if mock.SendNotificationFunc == nil {
 		panic("RateLimitServiceMock.SendNotificationFunc: method is nil but RateLimitService.SendNotification was just called")
 	}
@@ -0,0 +1,520 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package controllers
+
+import (
+	"rate-limiter/domain"
+	"sync"
+)
+
+// Ensure, that RulesServiceMock does implement RulesService.
+// If this is not the case, regenerate this file with moq.
+var _ RulesService = &RulesServiceMock{}
+
+// RulesServiceMock is a mock implementation of RulesService.
+//
+//	func TestSomethingThatUsesRulesService(t *testing.T) {
+//
+//		// make and configure a mocked RulesService
+//		mockedRulesService := &RulesServiceMock{
+//			AddRuleFunc: func(rule *domain.RateLimitRule) error {
+//				panic("mock out the AddRule method")
+//			},
+//			DeleteRulesByTypeFunc: func(notificationType string) error {
+//				panic("mock out the DeleteRulesByType method")
+//			},
+//			DeleteUserQuietHoursFunc: func(userID string) error {
+//				panic("mock out the DeleteUserQuietHours method")
+//			},
+//			DeleteUserTierFunc: func(userID string) error {
+//				panic("mock out the DeleteUserTier method")
+//			},
+//			GetRuleByTypeFunc: func(notificationType string, userID string) ([]*domain.RateLimitRule, error) {
+//				panic("mock out the GetRuleByType method")
+//			},
+//			GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+//				panic("mock out the GetRules method")
+//			},
+//			GetUserQuietHoursFunc: func(userID string) (*domain.UserQuietHours, error) {
+//				panic("mock out the GetUserQuietHours method")
+//			},
+//			GetUserTierFunc: func(userID string) (*domain.UserTier, error) {
+//				panic("mock out the GetUserTier method")
+//			},
+//			SetUserQuietHoursFunc: func(userQuietHours *domain.UserQuietHours) error {
+//				panic("mock out the SetUserQuietHours method")
+//			},
+//			SetUserTierFunc: func(userTier *domain.UserTier) error {
+//				panic("mock out the SetUserTier method")
+//			},
+//			UpdateRulesByTypeFunc: func(notificationType string, rules []*domain.RateLimitRule) error {
+//				panic("mock out the UpdateRulesByType method")
+//			},
+//		}
+//
+//		// use mockedRulesService in code that requires RulesService
+//		// and then make assertions.
+//
+//	}
+type RulesServiceMock struct {
+	// AddRuleFunc mocks the AddRule method.
+	AddRuleFunc func(rule *domain.RateLimitRule) error
+
+	// DeleteRulesByTypeFunc mocks the DeleteRulesByType method.
+	DeleteRulesByTypeFunc func(notificationType string) error
+
+	// DeleteUserQuietHoursFunc mocks the DeleteUserQuietHours method.
+	DeleteUserQuietHoursFunc func(userID string) error
+
+	// DeleteUserTierFunc mocks the DeleteUserTier method.
+	DeleteUserTierFunc func(userID string) error
+
+	// GetRuleByTypeFunc mocks the GetRuleByType method.
+	GetRuleByTypeFunc func(notificationType string, userID string) ([]*domain.RateLimitRule, error)
+
+	// GetRulesFunc mocks the GetRules method.
+	GetRulesFunc func() (map[string][]*domain.RateLimitRule, error)
+
+	// GetUserQuietHoursFunc mocks the GetUserQuietHours method.
+	GetUserQuietHoursFunc func(userID string) (*domain.UserQuietHours, error)
+
+	// GetUserTierFunc mocks the GetUserTier method.
+	GetUserTierFunc func(userID string) (*domain.UserTier, error)
+
+	// SetUserQuietHoursFunc mocks the SetUserQuietHours method.
+	SetUserQuietHoursFunc func(userQuietHours *domain.UserQuietHours) error
+
+	// SetUserTierFunc mocks the SetUserTier method.
+	SetUserTierFunc func(userTier *domain.UserTier) error
+
+	// UpdateRulesByTypeFunc mocks the UpdateRulesByType method.
+	UpdateRulesByTypeFunc func(notificationType string, rules []*domain.RateLimitRule) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AddRule holds details about calls to the AddRule method.
+		AddRule []struct {
+			// Rule is the rule argument value.
+			Rule *domain.RateLimitRule
+		}
+		// DeleteRulesByType holds details about calls to the DeleteRulesByType method.
+		DeleteRulesByType []struct {
+			// NotificationType is the notificationType argument value.
+			NotificationType string
+		}
+		// DeleteUserQuietHours holds details about calls to the DeleteUserQuietHours method.
+		DeleteUserQuietHours []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// DeleteUserTier holds details about calls to the DeleteUserTier method.
+		DeleteUserTier []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// GetRuleByType holds details about calls to the GetRuleByType method.
+		GetRuleByType []struct {
+			// NotificationType is the notificationType argument value.
+			NotificationType string
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// GetRules holds details about calls to the GetRules method.
+		GetRules []struct {
+		}
+		// GetUserQuietHours holds details about calls to the GetUserQuietHours method.
+		GetUserQuietHours []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// GetUserTier holds details about calls to the GetUserTier method.
+		GetUserTier []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// SetUserQuietHours holds details about calls to the SetUserQuietHours method.
+		SetUserQuietHours []struct {
+			// UserQuietHours is the userQuietHours argument value.
+			UserQuietHours *domain.UserQuietHours
+		}
+		// SetUserTier holds details about calls to the SetUserTier method.
+		SetUserTier []struct {
+			// UserTier is the userTier argument value.
+			UserTier *domain.UserTier
+		}
+		// UpdateRulesByType holds details about calls to the UpdateRulesByType method.
+		UpdateRulesByType []struct {
+			// NotificationType is the notificationType argument value.
+			NotificationType string
+			// Rules is the rules argument value.
+			Rules []*domain.RateLimitRule
+		}
+	}
+	lockAddRule              sync.RWMutex
+	lockDeleteRulesByType    sync.RWMutex
+	lockDeleteUserQuietHours sync.RWMutex
+	lockDeleteUserTier       sync.RWMutex
+	lockGetRuleByType        sync.RWMutex
+	lockGetRules             sync.RWMutex
+	lockGetUserQuietHours    sync.RWMutex
+	lockGetUserTier          sync.RWMutex
+	lockSetUserQuietHours    sync.RWMutex
+	lockSetUserTier          sync.RWMutex
+	lockUpdateRulesByType    sync.RWMutex
+}
+
+// AddRule calls AddRuleFunc.
+func (mock *RulesServiceMock) AddRule(rule *domain.RateLimitRule) error {
+	if mock.AddRuleFunc == nil {
+		panic("RulesServiceMock.AddRuleFunc: method is nil but RulesService.AddRule was just called")
+	}
+	callInfo := struct {
+		Rule *domain.RateLimitRule
+	}{
+		Rule: rule,
+	}
+	mock.lockAddRule.Lock()
+	mock.calls.AddRule = append(mock.calls.AddRule, callInfo)
+	mock.lockAddRule.Unlock()
+	return mock.AddRuleFunc(rule)
+}
+
+// AddRuleCalls gets all the calls that were made to AddRule.
+// Check the length with:
+//
+//	len(mockedRulesService.AddRuleCalls())
+func (mock *RulesServiceMock) AddRuleCalls() []struct {
+	Rule *domain.RateLimitRule
+} {
+	var calls []struct {
+		Rule *domain.RateLimitRule
+	}
+	mock.lockAddRule.RLock()
+	calls = mock.calls.AddRule
+	mock.lockAddRule.RUnlock()
+	return calls
+}
+
+// DeleteRulesByType calls DeleteRulesByTypeFunc.
+func (mock *RulesServiceMock) DeleteRulesByType(notificationType string) error {
+	if mock.DeleteRulesByTypeFunc == nil {
+		panic("RulesServiceMock.DeleteRulesByTypeFunc: method is nil but RulesService.DeleteRulesByType was just called")
+	}
+	callInfo := struct {
+		NotificationType string
+	}{
+		NotificationType: notificationType,
+	}
+	mock.lockDeleteRulesByType.Lock()
+	mock.calls.DeleteRulesByType = append(mock.calls.DeleteRulesByType, callInfo)
+	mock.lockDeleteRulesByType.Unlock()
+	return mock.DeleteRulesByTypeFunc(notificationType)
+}
+
+// DeleteRulesByTypeCalls gets all the calls that were made to DeleteRulesByType.
+// Check the length with:
+//
+//	len(mockedRulesService.DeleteRulesByTypeCalls())
+func (mock *RulesServiceMock) DeleteRulesByTypeCalls() []struct {
+	NotificationType string
+} {
+	var calls []struct {
+		NotificationType string
+	}
+	mock.lockDeleteRulesByType.RLock()
+	calls = mock.calls.DeleteRulesByType
+	mock.lockDeleteRulesByType.RUnlock()
+	return calls
+}
+
+// DeleteUserQuietHours calls DeleteUserQuietHoursFunc.
+func (mock *RulesServiceMock) DeleteUserQuietHours(userID string) error {
+	if mock.DeleteUserQuietHoursFunc == nil {
+		panic("RulesServiceMock.DeleteUserQuietHoursFunc: method is nil but RulesService.DeleteUserQuietHours was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockDeleteUserQuietHours.Lock()
+	mock.calls.DeleteUserQuietHours = append(mock.calls.DeleteUserQuietHours, callInfo)
+	mock.lockDeleteUserQuietHours.Unlock()
+	return mock.DeleteUserQuietHoursFunc(userID)
+}
+
+// DeleteUserQuietHoursCalls gets all the calls that were made to DeleteUserQuietHours.
+// Check the length with:
+//
+//	len(mockedRulesService.DeleteUserQuietHoursCalls())
+func (mock *RulesServiceMock) DeleteUserQuietHoursCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockDeleteUserQuietHours.RLock()
+	calls = mock.calls.DeleteUserQuietHours
+	mock.lockDeleteUserQuietHours.RUnlock()
+	return calls
+}
+
+// DeleteUserTier calls DeleteUserTierFunc.
+func (mock *RulesServiceMock) DeleteUserTier(userID string) error {
+	if mock.DeleteUserTierFunc == nil {
+		panic("RulesServiceMock.DeleteUserTierFunc: method is nil but RulesService.DeleteUserTier was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockDeleteUserTier.Lock()
+	mock.calls.DeleteUserTier = append(mock.calls.DeleteUserTier, callInfo)
+	mock.lockDeleteUserTier.Unlock()
+	return mock.DeleteUserTierFunc(userID)
+}
+
+// DeleteUserTierCalls gets all the calls that were made to DeleteUserTier.
+// Check the length with:
+//
+//	len(mockedRulesService.DeleteUserTierCalls())
+func (mock *RulesServiceMock) DeleteUserTierCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockDeleteUserTier.RLock()
+	calls = mock.calls.DeleteUserTier
+	mock.lockDeleteUserTier.RUnlock()
+	return calls
+}
+
+// GetRuleByType calls GetRuleByTypeFunc.
+func (mock *RulesServiceMock) GetRuleByType(notificationType string, userID string) ([]*domain.RateLimitRule, error) {
+	if mock.GetRuleByTypeFunc == nil {
+		panic("RulesServiceMock.GetRuleByTypeFunc: method is nil but RulesService.GetRuleByType was just called")
+	}
+	callInfo := struct {
+		NotificationType string
+		UserID           string
+	}{
+		NotificationType: notificationType,
+		UserID:           userID,
+	}
+	mock.lockGetRuleByType.Lock()
+	mock.calls.GetRuleByType = append(mock.calls.GetRuleByType, callInfo)
+	mock.lockGetRuleByType.Unlock()
+	return mock.GetRuleByTypeFunc(notificationType, userID)
+}
+
+// GetRuleByTypeCalls gets all the calls that were made to GetRuleByType.
+// Check the length with:
+//
+//	len(mockedRulesService.GetRuleByTypeCalls())
+func (mock *RulesServiceMock) GetRuleByTypeCalls() []struct {
+	NotificationType string
+	UserID           string
+} {
+	var calls []struct {
+		NotificationType string
+		UserID           string
+	}
+	mock.lockGetRuleByType.RLock()
+	calls = mock.calls.GetRuleByType
+	mock.lockGetRuleByType.RUnlock()
+	return calls
+}
+
+// GetRules calls GetRulesFunc.
+func (mock *RulesServiceMock) GetRules() (map[string][]*domain.RateLimitRule, error) {
+	if mock.GetRulesFunc == nil {
+		panic("RulesServiceMock.GetRulesFunc: method is nil but RulesService.GetRules was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetRules.Lock()
+	mock.calls.GetRules = append(mock.calls.GetRules, callInfo)
+	mock.lockGetRules.Unlock()
+	return mock.GetRulesFunc()
+}
+
+// GetRulesCalls gets all the calls that were made to GetRules.
+// Check the length with:
+//
+//	len(mockedRulesService.GetRulesCalls())
+func (mock *RulesServiceMock) GetRulesCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetRules.RLock()
+	calls = mock.calls.GetRules
+	mock.lockGetRules.RUnlock()
+	return calls
+}
+
+// GetUserQuietHours calls GetUserQuietHoursFunc.
+func (mock *RulesServiceMock) GetUserQuietHours(userID string) (*domain.UserQuietHours, error) {
+	if mock.GetUserQuietHoursFunc == nil {
+		panic("RulesServiceMock.GetUserQuietHoursFunc: method is nil but RulesService.GetUserQuietHours was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockGetUserQuietHours.Lock()
+	mock.calls.GetUserQuietHours = append(mock.calls.GetUserQuietHours, callInfo)
+	mock.lockGetUserQuietHours.Unlock()
+	return mock.GetUserQuietHoursFunc(userID)
+}
+
+// GetUserQuietHoursCalls gets all the calls that were made to GetUserQuietHours.
+// Check the length with:
+//
+//	len(mockedRulesService.GetUserQuietHoursCalls())
+func (mock *RulesServiceMock) GetUserQuietHoursCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockGetUserQuietHours.RLock()
+	calls = mock.calls.GetUserQuietHours
+	mock.lockGetUserQuietHours.RUnlock()
+	return calls
+}
+
+// GetUserTier calls GetUserTierFunc.
+func (mock *RulesServiceMock) GetUserTier(userID string) (*domain.UserTier, error) {
+	if mock.GetUserTierFunc == nil {
+		panic("RulesServiceMock.GetUserTierFunc: method is nil but RulesService.GetUserTier was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockGetUserTier.Lock()
+	mock.calls.GetUserTier = append(mock.calls.GetUserTier, callInfo)
+	mock.lockGetUserTier.Unlock()
+	return mock.GetUserTierFunc(userID)
+}
+
+// GetUserTierCalls gets all the calls that were made to GetUserTier.
+// Check the length with:
+//
+//	len(mockedRulesService.GetUserTierCalls())
+func (mock *RulesServiceMock) GetUserTierCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockGetUserTier.RLock()
+	calls = mock.calls.GetUserTier
+	mock.lockGetUserTier.RUnlock()
+	return calls
+}
+
+// SetUserQuietHours calls SetUserQuietHoursFunc.
+func (mock *RulesServiceMock) SetUserQuietHours(userQuietHours *domain.UserQuietHours) error {
+	if mock.SetUserQuietHoursFunc == nil {
+		panic("RulesServiceMock.SetUserQuietHoursFunc: method is nil but RulesService.SetUserQuietHours was just called")
+	}
+	callInfo := struct {
+		UserQuietHours *domain.UserQuietHours
+	}{
+		UserQuietHours: userQuietHours,
+	}
+	mock.lockSetUserQuietHours.Lock()
+	mock.calls.SetUserQuietHours = append(mock.calls.SetUserQuietHours, callInfo)
+	mock.lockSetUserQuietHours.Unlock()
+	return mock.SetUserQuietHoursFunc(userQuietHours)
+}
+
+// SetUserQuietHoursCalls gets all the calls that were made to SetUserQuietHours.
+// Check the length with:
+//
+//	len(mockedRulesService.SetUserQuietHoursCalls())
+func (mock *RulesServiceMock) SetUserQuietHoursCalls() []struct {
+	UserQuietHours *domain.UserQuietHours
+} {
+	var calls []struct {
+		UserQuietHours *domain.UserQuietHours
+	}
+	mock.lockSetUserQuietHours.RLock()
+	calls = mock.calls.SetUserQuietHours
+	mock.lockSetUserQuietHours.RUnlock()
+	return calls
+}
+
+// SetUserTier calls SetUserTierFunc.
+func (mock *RulesServiceMock) SetUserTier(userTier *domain.UserTier) error {
+	if mock.SetUserTierFunc == nil {
+		panic("RulesServiceMock.SetUserTierFunc: method is nil but RulesService.SetUserTier was just called")
+	}
+	callInfo := struct {
+		UserTier *domain.UserTier
+	}{
+		UserTier: userTier,
+	}
+	mock.lockSetUserTier.Lock()
+	mock.calls.SetUserTier = append(mock.calls.SetUserTier, callInfo)
+	mock.lockSetUserTier.Unlock()
+	return mock.SetUserTierFunc(userTier)
+}
+
+// SetUserTierCalls gets all the calls that were made to SetUserTier.
+// Check the length with:
+//
+//	len(mockedRulesService.SetUserTierCalls())
+func (mock *RulesServiceMock) SetUserTierCalls() []struct {
+	UserTier *domain.UserTier
+} {
+	var calls []struct {
+		UserTier *domain.UserTier
+	}
+	mock.lockSetUserTier.RLock()
+	calls = mock.calls.SetUserTier
+	mock.lockSetUserTier.RUnlock()
+	return calls
+}
+
+// UpdateRulesByType calls UpdateRulesByTypeFunc.
+func (mock *RulesServiceMock) UpdateRulesByType(notificationType string, rules []*domain.RateLimitRule) error {
+	if mock.UpdateRulesByTypeFunc == nil {
+		panic("RulesServiceMock.UpdateRulesByTypeFunc: method is nil but RulesService.UpdateRulesByType was just called")
+	}
+	callInfo := struct {
+		NotificationType string
+		Rules            []*domain.RateLimitRule
+	}{
+		NotificationType: notificationType,
+		Rules:            rules,
+	}
+	mock.lockUpdateRulesByType.Lock()
+	mock.calls.UpdateRulesByType = append(mock.calls.UpdateRulesByType, callInfo)
+	mock.lockUpdateRulesByType.Unlock()
+	return mock.UpdateRulesByTypeFunc(notificationType, rules)
+}
+
+// UpdateRulesByTypeCalls gets all the calls that were made to UpdateRulesByType.
+// Check the length with:
+//
+//	len(mockedRulesService.UpdateRulesByTypeCalls())
+func (mock *RulesServiceMock) UpdateRulesByTypeCalls() []struct {
+	NotificationType string
+	Rules            []*domain.RateLimitRule
+} {
+	var calls []struct {
+		NotificationType string
+		Rules            []*domain.RateLimitRule
+	}
+	mock.lockUpdateRulesByType.RLock()
+	calls = mock.calls.UpdateRulesByType
+	mock.lockUpdateRulesByType.RUnlock()
+	return calls
+}
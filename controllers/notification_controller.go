@@ -1,16 +1,22 @@
 package controllers
 
 import (
+	"encoding/json"
 	"net/http"
 	"rate-limiter/domain"
 	"rate-limiter/errors"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RateLimitService interface {
-	SendNotification(domain.SendNotificationParams) error
+	SendNotification(domain.SendNotificationParams) (*domain.Decision, error)
+	GetQuota(domain.GetQuotaParams) (*domain.Quota, error)
+	GetShadowRejections() []*domain.ShadowRejections
+	GetDelayedNotifications() ([]*domain.DelayedNotification, error)
+	GetDigests() ([]*domain.Digest, error)
 }
 type NotificationController struct {
 	RateLimitService RateLimitService
@@ -34,19 +40,91 @@ func (nc NotificationController) SendNotification(c *gin.Context) {
 		return
 	}
 
-	err := nc.RateLimitService.SendNotification(domain.SendNotificationParams{
+	decision, err := nc.RateLimitService.SendNotification(domain.SendNotificationParams{
 		UserID:           userID,
 		NotificationType: notificationType,
+		Timezone:         c.GetString("timezone"),
+		Payload:          payload(c),
 	})
 	if err != nil {
-		if errors.IsTooManyRequestsError(err) {
-			c.JSON(http.StatusTooManyRequests, &errors.ApiError{Message: "message limit exceeded", ErrorStr: err.Error(), Status: http.StatusTooManyRequests})
+		c.JSON(http.StatusInternalServerError, &errors.ApiError{Message: "internal server error", ErrorStr: err.Error(), Status: http.StatusInternalServerError})
+		return
+	}
+
+	setRateLimitHeaders(c, decision)
+	switch decision.OnExceed() {
+	case domain.OnExceedDelay:
+		c.JSON(http.StatusAccepted, gin.H{"status": "success", "message": "notification delayed", "deliverAt": decision.DeliverAt})
+		return
+	case domain.OnExceedDropSilently:
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "notification dropped"})
+		return
+	case domain.OnExceedDigest:
+		c.JSON(http.StatusAccepted, gin.H{"status": "success", "message": "notification added to digest", "deliverAt": decision.DeliverAt})
+		return
+	}
+	if !decision.Allowed && decision.QuietUntil != nil {
+		c.JSON(http.StatusConflict, &errors.ApiError{Message: "notification type is in quiet hours", ErrorStr: errors.ErrQuietHours.Error(), Status: http.StatusConflict, Decision: decision})
+		return
+	}
+	if !decision.Allowed {
+		c.JSON(http.StatusTooManyRequests, &errors.ApiError{Message: "message limit exceeded", ErrorStr: errors.ErrRateLimitExceeded.Error(), Status: http.StatusTooManyRequests, Decision: decision})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "notification sent"})
+}
+
+// setRateLimitHeaders sets the RateLimit headers of the IETF draft with the quota closest to being
+// exhausted, and Retry-After when the notification is rejected, by the quotas or by quiet hours, and
+// not delayed or dropped.
+func setRateLimitHeaders(c *gin.Context, decision *domain.Decision) {
+	if limiting := decision.LimitingQuota(); limiting != nil {
+		c.Header("RateLimit-Limit", strconv.Itoa(limiting.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(limiting.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(decision.ResetAfter().Seconds())))
+	}
+	if decision.HasRetryAfter() && decision.OnExceed() == domain.OnExceedReject {
+		c.Header("Retry-After", strconv.Itoa(int(decision.RetryAfter().Seconds())))
+	}
+}
+
+func (nc NotificationController) GetQuota(c *gin.Context) {
+	quota, err := nc.RateLimitService.GetQuota(domain.GetQuotaParams{
+		UserID:           c.GetString("userID"),
+		NotificationType: c.GetString("type"),
+		Timezone:         c.GetString("timezone"),
+	})
+	if err != nil {
+		if errors.IsNotSupportedError(err) {
+			c.JSON(http.StatusNotImplemented, &errors.ApiError{Message: "quota is not supported by the notifications storage", ErrorStr: err.Error(), Status: http.StatusNotImplemented})
 		} else {
 			c.JSON(http.StatusInternalServerError, &errors.ApiError{Message: "internal server error", ErrorStr: err.Error(), Status: http.StatusInternalServerError})
 		}
-	} else {
-		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "notification sent"})
+		return
 	}
+	c.JSON(http.StatusOK, quota)
+}
+
+func (nc NotificationController) GetShadowRejections(c *gin.Context) {
+	c.JSON(http.StatusOK, nc.RateLimitService.GetShadowRejections())
+}
+
+func (nc NotificationController) GetDelayedNotifications(c *gin.Context) {
+	delayedNotifications, err := nc.RateLimitService.GetDelayedNotifications()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, &errors.ApiError{Message: "internal server error", ErrorStr: err.Error(), Status: http.StatusInternalServerError})
+		return
+	}
+	c.JSON(http.StatusOK, delayedNotifications)
+}
+
+func (nc NotificationController) GetDigests(c *gin.Context) {
+	digests, err := nc.RateLimitService.GetDigests()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, &errors.ApiError{Message: "internal server error", ErrorStr: err.Error(), Status: http.StatusInternalServerError})
+		return
+	}
+	c.JSON(http.StatusOK, digests)
 }
 
 func (nc NotificationController) ValidateNotificationType(c *gin.Context) error {
@@ -54,7 +132,11 @@ func (nc NotificationController) ValidateNotificationType(c *gin.Context) error
 	if notificationType == "" {
 		return &errors.ApiError{Message: "notification type is mandatory", ErrorStr: "invalid_type", Status: http.StatusBadRequest}
 	}
-	c.Set("type", strings.ToLower(notificationType))
+	notificationType = strings.ToLower(notificationType)
+	if notificationType == domain.NotificationTypeAll || domain.IsGroupNotificationType(notificationType) {
+		return &errors.ApiError{Message: "notification type is reserved for the global and group rules", ErrorStr: "invalid_type", Status: http.StatusBadRequest}
+	}
+	c.Set("type", notificationType)
 	return nil
 }
 
@@ -66,3 +148,33 @@ func (nc NotificationController) ValidateUserID(c *gin.Context) error {
 	c.Set("userID", userID)
 	return nil
 }
+
+func (nc NotificationController) ValidateTimezone(c *gin.Context) error {
+	timezone := c.Query("timezone")
+	if timezone == "" {
+		return nil
+	}
+	if _, err := domain.LoadLocation(timezone); err != nil {
+		return &errors.ApiError{Message: "timezone is not a valid IANA timezone", ErrorStr: "invalid_timezone", Status: http.StatusBadRequest}
+	}
+	c.Set("timezone", timezone)
+	return nil
+}
+
+// ValidatePayload checks that the body of the notification, which is optional, is json.
+func (nc NotificationController) ValidatePayload(c *gin.Context) error {
+	body, err := c.GetRawData()
+	if err != nil || len(body) > 0 && !json.Valid(body) {
+		return &errors.ApiError{Message: "payload must be json", ErrorStr: "invalid_payload", Status: http.StatusBadRequest}
+	}
+	if len(body) > 0 {
+		c.Set("payload", json.RawMessage(body))
+	}
+	return nil
+}
+
+func payload(c *gin.Context) json.RawMessage {
+	payload, _ := c.Get("payload")
+	body, _ := payload.(json.RawMessage)
+	return body
+}
@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"rate-limiter/domain"
 	"rate-limiter/errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -40,12 +44,20 @@ func TestNotificationController_Pong(t *testing.T) {
 }
 
 func TestNotificationController_SendNotification(t *testing.T) {
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		t := now.Add(offset)
+		return &t
+	}
+	hourRule := &domain.RateLimitRule{NotificationType: "testType", MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Hour}}
+	minuteRule := &domain.RateLimitRule{NotificationType: "testType", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}
 	testCases := []struct {
 		name                       string
 		userID                     string
 		notificationType           string
 		expectedCode               int
 		expectedResponse           string
+		expectedHeaders            map[string]string
 		rateLimitServiceMockConfig func(*RateLimitServiceMock)
 	}{
 		{
@@ -55,8 +67,8 @@ func TestNotificationController_SendNotification(t *testing.T) {
 			expectedCode:     http.StatusBadRequest,
 			expectedResponse: `{"message":"userID is mandatory","error":"invalid_user_id","status":400}`,
 			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
-				mock.SendNotificationFunc = func(domain.SendNotificationParams) error {
-					return nil
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{Allowed: true}, nil
 				}
 			},
 		},
@@ -67,8 +79,8 @@ func TestNotificationController_SendNotification(t *testing.T) {
 			expectedCode:     http.StatusBadRequest,
 			expectedResponse: `{"message":"notification type is mandatory","error":"invalid_type","status":400}`,
 			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
-				mock.SendNotificationFunc = func(domain.SendNotificationParams) error {
-					return nil
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{Allowed: true}, nil
 				}
 			},
 		},
@@ -79,8 +91,28 @@ func TestNotificationController_SendNotification(t *testing.T) {
 			expectedCode:     http.StatusOK,
 			expectedResponse: `{"message":"notification sent","status":"success"}`,
 			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
-				mock.SendNotificationFunc = func(domain.SendNotificationParams) error {
-					return nil
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{Allowed: true}, nil
+				}
+			},
+		},
+		{
+			name:             "success with rules",
+			userID:           "testUserID",
+			notificationType: "testType",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"message":"notification sent","status":"success"}`,
+			expectedHeaders:  map[string]string{"RateLimit-Limit": "5", "RateLimit-Remaining": "1", "RateLimit-Reset": "30", "Retry-After": ""},
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{
+						Allowed:   true,
+						Timestamp: now,
+						Quotas: []*domain.RuleQuota{
+							{Limit: 10, Used: 2, Remaining: 8, NextSlotAt: at(time.Hour)},
+							{Limit: 5, Used: 4, Remaining: 1, NextSlotAt: at(29*time.Second + time.Millisecond)},
+						},
+					}, nil
 				}
 			},
 		},
@@ -89,10 +121,96 @@ func TestNotificationController_SendNotification(t *testing.T) {
 			userID:           "testUserID",
 			notificationType: "testType",
 			expectedCode:     http.StatusTooManyRequests,
-			expectedResponse: `{"message":"message limit exceeded","error":"rate limit exceeded","status":429}`,
+			expectedResponse: `{"message":"message limit exceeded","error":"rate limit exceeded","status":429,` +
+				`"decision":{"allowed":false,"blockingRule":{"notificationType":"testType","maxLimit":2,"timeInterval":"1m"},` +
+				`"quotas":[{"rule":{"notificationType":"testType","maxLimit":10,"timeInterval":"1h"},"limit":10,"used":2,"remaining":8,"nextSlotAt":"2024-05-14T11:00:00Z"},` +
+				`{"rule":{"notificationType":"testType","maxLimit":2,"timeInterval":"1m"},"limit":2,"used":2,"remaining":0,"nextSlotAt":"2024-05-14T10:01:00Z"}],"retryAfter":60}}`,
+			expectedHeaders: map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "60", "Retry-After": "60"},
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{
+						Allowed:      false,
+						BlockingRule: minuteRule,
+						Timestamp:    now,
+						Quotas: []*domain.RuleQuota{
+							{Rule: hourRule, Limit: 10, Used: 2, Remaining: 8, NextSlotAt: at(time.Hour)},
+							{Rule: minuteRule, Limit: 2, Used: 2, Remaining: 0, NextSlotAt: at(time.Minute)},
+						},
+					}, nil
+				}
+			},
+		},
+		{
+			name:             "quiet hours",
+			userID:           "testUserID",
+			notificationType: "testType",
+			expectedCode:     http.StatusConflict,
+			expectedResponse: `{"message":"notification type is in quiet hours","error":"notification type is in quiet hours","status":409,` +
+				`"decision":{"allowed":false,"blockingRule":{"notificationType":"testType","maxLimit":0,"timeInterval":"0s","quietHours":{"start":"22:00","end":"11:30"}},` +
+				`"quietUntil":"2024-05-14T11:30:00Z","retryAfter":5400}}`,
+			expectedHeaders: map[string]string{"RateLimit-Limit": "", "Retry-After": "5400"},
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{
+						Allowed:      false,
+						BlockingRule: &domain.RateLimitRule{NotificationType: "testType", QuietHours: &domain.QuietHours{Start: "22:00", End: "11:30"}},
+						QuietUntil:   at(90 * time.Minute),
+						Timestamp:    now,
+					}, nil
+				}
+			},
+		},
+		{
+			name:             "delayed",
+			userID:           "testUserID",
+			notificationType: "testType",
+			expectedCode:     http.StatusAccepted,
+			expectedResponse: `{"deliverAt":"2024-05-14T10:01:00Z","message":"notification delayed","status":"success"}`,
+			expectedHeaders:  map[string]string{"RateLimit-Remaining": "0", "Retry-After": ""},
 			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
-				mock.SendNotificationFunc = func(domain.SendNotificationParams) error {
-					return errors.ErrRateLimitExceeded
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					delayRule := &domain.RateLimitRule{NotificationType: "testType", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: domain.OnExceedDelay}
+					return &domain.Decision{
+						Allowed:      false,
+						BlockingRule: delayRule,
+						Timestamp:    now,
+						Quotas:       []*domain.RuleQuota{{Rule: delayRule, Limit: 2, Used: 2, Remaining: 0, NextSlotAt: at(time.Minute)}},
+						DeliverAt:    at(time.Minute),
+					}, nil
+				}
+			},
+		},
+		{
+			name:             "dropped silently",
+			userID:           "testUserID",
+			notificationType: "testType",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"message":"notification dropped","status":"success"}`,
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{
+						Allowed:      false,
+						BlockingRule: &domain.RateLimitRule{NotificationType: "testType", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: domain.OnExceedDropSilently},
+						Timestamp:    now,
+					}, nil
+				}
+			},
+		},
+		{
+			name:             "added to digest",
+			userID:           "testUserID",
+			notificationType: "testType",
+			expectedCode:     http.StatusAccepted,
+			expectedResponse: `{"deliverAt":"2024-05-14T10:01:00Z","message":"notification added to digest","status":"success"}`,
+			expectedHeaders:  map[string]string{"Retry-After": ""},
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return &domain.Decision{
+						Allowed:      false,
+						BlockingRule: &domain.RateLimitRule{NotificationType: "testType", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: domain.OnExceedDigest},
+						Timestamp:    now,
+						DeliverAt:    at(time.Minute),
+					}, nil
 				}
 			},
 		},
@@ -103,8 +221,8 @@ func TestNotificationController_SendNotification(t *testing.T) {
 			expectedCode:     http.StatusInternalServerError,
 			expectedResponse: `{"message":"internal server error","error":"error getting rate limit rule for notification type","status":500}`,
 			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
-				mock.SendNotificationFunc = func(domain.SendNotificationParams) error {
-					return errors.ErrGetRateLimitRule
+				mock.SendNotificationFunc = func(domain.SendNotificationParams) (*domain.Decision, error) {
+					return nil, errors.ErrGetRateLimitRule
 				}
 			},
 		},
@@ -128,6 +246,264 @@ func TestNotificationController_SendNotification(t *testing.T) {
 			controller.SendNotification(context)
 			assert.Equal(t, tc.expectedCode, recorder.Code)
 			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+			for header, value := range tc.expectedHeaders {
+				assert.Equal(t, value, recorder.Header().Get(header), header)
+			}
+		})
+	}
+}
+
+func TestNotificationController_ValidateTimezone(t *testing.T) {
+	testCases := []struct {
+		name             string
+		query            string
+		expectedTimezone string
+		expectedErr      error
+	}{
+		{
+			name:             "no timezone",
+			query:            "",
+			expectedTimezone: "",
+		},
+		{
+			name:             "valid timezone",
+			query:            "?timezone=America/Argentina/Buenos_Aires",
+			expectedTimezone: "America/Argentina/Buenos_Aires",
+		},
+		{
+			name:        "invalid timezone",
+			query:       "?timezone=Invalid/Timezone",
+			expectedErr: &errors.ApiError{Message: "timezone is not a valid IANA timezone", ErrorStr: "invalid_timezone", Status: http.StatusBadRequest},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Request = httptest.NewRequest(http.MethodPost, "/notifications/news/users/user1"+tc.query, nil)
+			controller := NotificationController{}
+
+			err := controller.ValidateTimezone(context)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedTimezone, context.GetString("timezone"))
+		})
+	}
+}
+
+func TestNotificationController_ValidatePayload(t *testing.T) {
+	testCases := []struct {
+		name            string
+		body            string
+		expectedPayload json.RawMessage
+		expectedErr     error
+	}{
+		{
+			name: "no payload",
+			body: "",
+		},
+		{
+			name:            "json payload",
+			body:            `{"text":"user1 is online"}`,
+			expectedPayload: json.RawMessage(`{"text":"user1 is online"}`),
+		},
+		{
+			name:        "invalid payload",
+			body:        "user1 is online",
+			expectedErr: &errors.ApiError{Message: "payload must be json", ErrorStr: "invalid_payload", Status: http.StatusBadRequest},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Request = httptest.NewRequest(http.MethodPost, "/notifications/news/users/user1", strings.NewReader(tc.body))
+			controller := NotificationController{}
+
+			err := controller.ValidatePayload(context)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedPayload, payload(context))
+		})
+	}
+}
+
+func TestNotificationController_ValidateNotificationType(t *testing.T) {
+	testCases := []struct {
+		name             string
+		notificationType string
+		expectedType     string
+		expectedErr      error
+	}{
+		{
+			name:             "valid type",
+			notificationType: "News",
+			expectedType:     "news",
+		},
+		{
+			name:             "global rules type",
+			notificationType: "*",
+			expectedErr:      &errors.ApiError{Message: "notification type is reserved for the global and group rules", ErrorStr: "invalid_type", Status: http.StatusBadRequest},
+		},
+		{
+			name:             "group rules type",
+			notificationType: "Group:Promotional",
+			expectedErr:      &errors.ApiError{Message: "notification type is reserved for the global and group rules", ErrorStr: "invalid_type", Status: http.StatusBadRequest},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Params = gin.Params{{Key: "type", Value: tc.notificationType}}
+			controller := NotificationController{}
+
+			err := controller.ValidateNotificationType(context)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedType, context.GetString("type"))
+		})
+	}
+}
+
+func TestNotificationController_GetQuota(t *testing.T) {
+	nextSlotAt := time.Date(2024, 5, 14, 10, 1, 0, 0, time.UTC)
+	rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}
+	testCases := []struct {
+		name                       string
+		expectedCode               int
+		expectedResponse           string
+		rateLimitServiceMockConfig func(*RateLimitServiceMock)
+	}{
+		{
+			name:             "success",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"userId":"testUserID","notificationType":"status","rules":[{"rule":{"notificationType":"status","maxLimit":2,"timeInterval":"1m"},"limit":2,"used":1,"remaining":1,"nextSlotAt":"2024-05-14T10:01:00Z"}]}`,
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.GetQuotaFunc = func(params domain.GetQuotaParams) (*domain.Quota, error) {
+					return &domain.Quota{
+						UserID:           params.UserID,
+						NotificationType: params.NotificationType,
+						Rules:            []*domain.RuleQuota{{Rule: rule, Limit: 2, Used: 1, Remaining: 1, NextSlotAt: &nextSlotAt}},
+					}, nil
+				}
+			},
+		},
+		{
+			name:             "storage without history",
+			expectedCode:     http.StatusNotImplemented,
+			expectedResponse: `{"message":"quota is not supported by the notifications storage","error":"operation not supported by the storage","status":501}`,
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.GetQuotaFunc = func(domain.GetQuotaParams) (*domain.Quota, error) {
+					return nil, errors.ErrNotSupported
+				}
+			},
+		},
+		{
+			name:             "error getting rule limit",
+			expectedCode:     http.StatusInternalServerError,
+			expectedResponse: `{"message":"internal server error","error":"error getting rate limit rule for notification type","status":500}`,
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.GetQuotaFunc = func(domain.GetQuotaParams) (*domain.Quota, error) {
+					return nil, errors.ErrGetRateLimitRule
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+
+			serviceMock := &RateLimitServiceMock{}
+			tc.rateLimitServiceMockConfig(serviceMock)
+
+			controller := NotificationController{
+				RateLimitService: serviceMock,
+			}
+
+			context.Set("userID", "testUserID")
+			context.Set("type", "status")
+
+			controller.GetQuota(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestNotificationController_GetShadowRejections(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+
+	serviceMock := &RateLimitServiceMock{
+		GetShadowRejectionsFunc: func() []*domain.ShadowRejections {
+			return []*domain.ShadowRejections{{
+				Rule:           &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, Mode: domain.ModeShadow},
+				Rejections:     3,
+				LastRejectedAt: time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC),
+			}}
+		},
+	}
+	controller := NotificationController{
+		RateLimitService: serviceMock,
+	}
+
+	controller.GetShadowRejections(context)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `[{"rule":{"notificationType":"status","maxLimit":1,"timeInterval":"1m","mode":"shadow"},"rejections":3,"lastRejectedAt":"2024-05-14T10:00:00Z"}]`, recorder.Body.String())
+}
+
+func TestNotificationController_GetDelayedNotifications(t *testing.T) {
+	testCases := []struct {
+		name                       string
+		expectedCode               int
+		expectedResponse           string
+		rateLimitServiceMockConfig func(*RateLimitServiceMock)
+	}{
+		{
+			name:         "success",
+			expectedCode: http.StatusOK,
+			expectedResponse: `[{"id":"1","userId":"user1","notificationType":"status","createdAt":"2024-05-14T10:00:00Z",` +
+				`"deliverAt":"2024-05-14T10:01:00Z","attempts":0}]`,
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.GetDelayedNotificationsFunc = func() ([]*domain.DelayedNotification, error) {
+					return []*domain.DelayedNotification{{
+						ID:               "1",
+						UserID:           "user1",
+						NotificationType: "status",
+						CreatedAt:        time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC),
+						DeliverAt:        time.Date(2024, 5, 14, 10, 1, 0, 0, time.UTC),
+					}}, nil
+				}
+			},
+		},
+		{
+			name:             "error",
+			expectedCode:     http.StatusInternalServerError,
+			expectedResponse: `{"message":"internal server error","error":"connection refused","status":500}`,
+			rateLimitServiceMockConfig: func(mock *RateLimitServiceMock) {
+				mock.GetDelayedNotificationsFunc = func() ([]*domain.DelayedNotification, error) {
+					return nil, fmt.Errorf("connection refused")
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			serviceMock := &RateLimitServiceMock{}
+			tc.rateLimitServiceMockConfig(serviceMock)
+			controller := NotificationController{
+				RateLimitService: serviceMock,
+			}
+
+			controller.GetDelayedNotifications(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
 		})
 	}
 }
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"net/http"
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RulesService interface {
+	GetRules() (map[string][]*domain.RateLimitRule, error)
+	GetRuleByType(notificationType, userID string) ([]*domain.RateLimitRule, error)
+	AddRule(rule *domain.RateLimitRule) error
+	UpdateRulesByType(notificationType string, rules []*domain.RateLimitRule) error
+	DeleteRulesByType(notificationType string) error
+	GetUserTier(userID string) (*domain.UserTier, error)
+	SetUserTier(userTier *domain.UserTier) error
+	DeleteUserTier(userID string) error
+	GetUserQuietHours(userID string) (*domain.UserQuietHours, error)
+	SetUserQuietHours(userQuietHours *domain.UserQuietHours) error
+	DeleteUserQuietHours(userID string) error
+}
+
+type RulesController struct {
+	RulesService RulesService
+}
+
+func (rc RulesController) GetRules(c *gin.Context) {
+	rules, err := rc.RulesService.GetRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, &errors.ApiError{Message: "internal server error", ErrorStr: err.Error(), Status: http.StatusInternalServerError})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (rc RulesController) GetRulesByType(c *gin.Context) {
+	rules, err := rc.RulesService.GetRuleByType(strings.ToLower(c.Param("type")), c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, &errors.ApiError{Message: "internal server error", ErrorStr: err.Error(), Status: http.StatusInternalServerError})
+		return
+	}
+	if len(rules) == 0 {
+		c.JSON(http.StatusNotFound, &errors.ApiError{Message: "notification type has no rules", ErrorStr: errors.ErrRuleNotFound.Error(), Status: http.StatusNotFound})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (rc RulesController) CreateRule(c *gin.Context) {
+	var rule domain.RateLimitRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, &errors.ApiError{Message: "invalid rule body", ErrorStr: err.Error(), Status: http.StatusBadRequest})
+		return
+	}
+
+	if err := rc.RulesService.AddRule(&rule); err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (rc RulesController) UpdateRulesByType(c *gin.Context) {
+	var rules []*domain.RateLimitRule
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, &errors.ApiError{Message: "invalid rules body", ErrorStr: err.Error(), Status: http.StatusBadRequest})
+		return
+	}
+
+	if err := rc.RulesService.UpdateRulesByType(c.Param("type"), rules); err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (rc RulesController) DeleteRulesByType(c *gin.Context) {
+	if err := rc.RulesService.DeleteRulesByType(c.Param("type")); err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "rules deleted"})
+}
+
+func (rc RulesController) GetUserTier(c *gin.Context) {
+	userTier, err := rc.RulesService.GetUserTier(c.Param("user_id"))
+	if err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, userTier)
+}
+
+func (rc RulesController) SetUserTier(c *gin.Context) {
+	var userTier domain.UserTier
+	if err := c.ShouldBindJSON(&userTier); err != nil {
+		c.JSON(http.StatusBadRequest, &errors.ApiError{Message: "invalid tier body", ErrorStr: err.Error(), Status: http.StatusBadRequest})
+		return
+	}
+
+	userTier.UserID = c.Param("user_id")
+	if err := rc.RulesService.SetUserTier(&userTier); err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, userTier)
+}
+
+func (rc RulesController) DeleteUserTier(c *gin.Context) {
+	if err := rc.RulesService.DeleteUserTier(c.Param("user_id")); err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "user tier deleted"})
+}
+
+func (rc RulesController) GetUserQuietHours(c *gin.Context) {
+	userQuietHours, err := rc.RulesService.GetUserQuietHours(c.Param("user_id"))
+	if err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, userQuietHours)
+}
+
+func (rc RulesController) SetUserQuietHours(c *gin.Context) {
+	var userQuietHours domain.UserQuietHours
+	if err := c.ShouldBindJSON(&userQuietHours); err != nil {
+		c.JSON(http.StatusBadRequest, &errors.ApiError{Message: "invalid quiet hours body", ErrorStr: err.Error(), Status: http.StatusBadRequest})
+		return
+	}
+
+	userQuietHours.UserID = c.Param("user_id")
+	if err := rc.RulesService.SetUserQuietHours(&userQuietHours); err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, userQuietHours)
+}
+
+func (rc RulesController) DeleteUserQuietHours(c *gin.Context) {
+	if err := rc.RulesService.DeleteUserQuietHours(c.Param("user_id")); err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "user quiet hours deleted"})
+}
+
+func (rc RulesController) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.IsInvalidRuleError(err):
+		c.JSON(http.StatusBadRequest, &errors.ApiError{Message: "invalid rule", ErrorStr: err.Error(), Status: http.StatusBadRequest})
+	case errors.IsInvalidTierError(err):
+		c.JSON(http.StatusBadRequest, &errors.ApiError{Message: "invalid tier", ErrorStr: err.Error(), Status: http.StatusBadRequest})
+	case errors.IsNotFoundError(err):
+		c.JSON(http.StatusNotFound, &errors.ApiError{Message: "notification type has no rules", ErrorStr: err.Error(), Status: http.StatusNotFound})
+	case errors.IsUserTierNotFoundError(err):
+		c.JSON(http.StatusNotFound, &errors.ApiError{Message: "user has no tier", ErrorStr: err.Error(), Status: http.StatusNotFound})
+	case errors.IsInvalidQuietHoursError(err):
+		c.JSON(http.StatusBadRequest, &errors.ApiError{Message: "invalid quiet hours", ErrorStr: err.Error(), Status: http.StatusBadRequest})
+	case errors.IsUserQuietHoursNotFoundError(err):
+		c.JSON(http.StatusNotFound, &errors.ApiError{Message: "user has no quiet hours settings", ErrorStr: err.Error(), Status: http.StatusNotFound})
+	default:
+		c.JSON(http.StatusInternalServerError, &errors.ApiError{Message: "internal server error", ErrorStr: err.Error(), Status: http.StatusInternalServerError})
+	}
+}
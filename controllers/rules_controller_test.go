@@ -0,0 +1,479 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRulesTestContext(method, path, body string, params gin.Params) (*httptest.ResponseRecorder, *gin.Context) {
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(method, path, strings.NewReader(body))
+	context.Request.Header.Set("Content-Type", "application/json")
+	context.Params = params
+	return recorder, context
+}
+
+func TestRulesController_GetRules(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"status":[{"notificationType":"status","maxLimit":2,"timeInterval":"1m"}]}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetRulesFunc = func() (map[string][]*domain.RateLimitRule, error) {
+					return map[string][]*domain.RateLimitRule{
+						"status": {{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}},
+					}, nil
+				}
+			},
+		},
+		{
+			name:             "internal error",
+			expectedCode:     http.StatusInternalServerError,
+			expectedResponse: `{"message":"internal server error","error":"some error","status":500}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetRulesFunc = func() (map[string][]*domain.RateLimitRule, error) {
+					return nil, fmt.Errorf("some error")
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodGet, "/rules", "", nil)
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.GetRules(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_GetRulesByType(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `[{"notificationType":"status","maxLimit":2,"timeInterval":"1m"}]`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetRuleByTypeFunc = func(notificationType, userID string) ([]*domain.RateLimitRule, error) {
+					return []*domain.RateLimitRule{{NotificationType: notificationType, MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}}, nil
+				}
+			},
+		},
+		{
+			name:             "not found",
+			expectedCode:     http.StatusNotFound,
+			expectedResponse: `{"message":"notification type has no rules","error":"rule not found","status":404}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetRuleByTypeFunc = func(notificationType, userID string) ([]*domain.RateLimitRule, error) {
+					return nil, nil
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodGet, "/rules/Status", "", gin.Params{{Key: "type", Value: "Status"}})
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.GetRulesByType(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_CreateRule(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		body                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			body:             `{"notificationType":"status","maxLimit":2,"timeInterval":"1m"}`,
+			expectedCode:     http.StatusCreated,
+			expectedResponse: `{"notificationType":"status","maxLimit":2,"timeInterval":"1m"}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.AddRuleFunc = func(rule *domain.RateLimitRule) error {
+					return nil
+				}
+			},
+		},
+		{
+			name:                   "invalid body",
+			body:                   `{"notificationType":"status","maxLimit":2,"timeInterval":"one minute"}`,
+			expectedCode:           http.StatusBadRequest,
+			expectedResponse:       `{"message":"invalid rule body","error":"time: invalid duration \"one minute\"","status":400}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {},
+		},
+		{
+			name:             "invalid rule",
+			body:             `{"notificationType":"status","maxLimit":0,"timeInterval":"1m"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: `{"message":"invalid rule","error":"invalid rule: maxLimit must be positive","status":400}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.AddRuleFunc = func(rule *domain.RateLimitRule) error {
+					return fmt.Errorf("%w: maxLimit must be positive", errors.ErrInvalidRule)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodPost, "/rules", tc.body, nil)
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.CreateRule(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_UpdateRulesByType(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		body                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			body:             `[{"maxLimit":2,"timeInterval":"1m"}]`,
+			expectedCode:     http.StatusOK,
+			expectedResponse: `[{"notificationType":"status","maxLimit":2,"timeInterval":"1m"}]`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.UpdateRulesByTypeFunc = func(notificationType string, rules []*domain.RateLimitRule) error {
+					for _, rule := range rules {
+						rule.NotificationType = "status"
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:             "invalid rules",
+			body:             `[]`,
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: `{"message":"invalid rule","error":"invalid rule: at least one rule is mandatory","status":400}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.UpdateRulesByTypeFunc = func(notificationType string, rules []*domain.RateLimitRule) error {
+					return fmt.Errorf("%w: at least one rule is mandatory", errors.ErrInvalidRule)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodPut, "/rules/status", tc.body, gin.Params{{Key: "type", Value: "status"}})
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.UpdateRulesByType(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_DeleteRulesByType(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"message":"rules deleted","status":"success"}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.DeleteRulesByTypeFunc = func(notificationType string) error {
+					return nil
+				}
+			},
+		},
+		{
+			name:             "not found",
+			expectedCode:     http.StatusNotFound,
+			expectedResponse: `{"message":"notification type has no rules","error":"rule not found","status":404}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.DeleteRulesByTypeFunc = func(notificationType string) error {
+					return errors.ErrRuleNotFound
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodDelete, "/rules/status", "", gin.Params{{Key: "type", Value: "status"}})
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.DeleteRulesByType(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_GetUserTier(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"userId":"user1","tier":"vip"}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetUserTierFunc = func(userID string) (*domain.UserTier, error) {
+					return &domain.UserTier{UserID: userID, Tier: "vip"}, nil
+				}
+			},
+		},
+		{
+			name:             "not found",
+			expectedCode:     http.StatusNotFound,
+			expectedResponse: `{"message":"user has no tier","error":"user tier not found","status":404}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetUserTierFunc = func(userID string) (*domain.UserTier, error) {
+					return nil, errors.ErrUserTierNotFound
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodGet, "/admin/users/user1/tier", "", gin.Params{{Key: "user_id", Value: "user1"}})
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.GetUserTier(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_SetUserTier(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		body                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			body:             `{"tier":"vip"}`,
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"userId":"user1","tier":"vip"}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.SetUserTierFunc = func(userTier *domain.UserTier) error {
+					return nil
+				}
+			},
+		},
+		{
+			name:                   "invalid body",
+			body:                   `{"tier":1}`,
+			expectedCode:           http.StatusBadRequest,
+			expectedResponse:       `{"message":"invalid tier body","error":"json: cannot unmarshal number into Go struct field UserTier.tier of type string","status":400}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {},
+		},
+		{
+			name:             "invalid tier",
+			body:             `{"tier":"gold"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: `{"message":"invalid tier","error":"invalid tier: tier 'gold' has no rules","status":400}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.SetUserTierFunc = func(userTier *domain.UserTier) error {
+					return fmt.Errorf("%w: tier 'gold' has no rules", errors.ErrInvalidTier)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodPut, "/admin/users/user1/tier", tc.body, gin.Params{{Key: "user_id", Value: "user1"}})
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.SetUserTier(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_DeleteUserTier(t *testing.T) {
+	recorder, context := newRulesTestContext(http.MethodDelete, "/admin/users/user1/tier", "", gin.Params{{Key: "user_id", Value: "user1"}})
+	serviceMock := &RulesServiceMock{
+		DeleteUserTierFunc: func(userID string) error {
+			return nil
+		},
+	}
+	controller := RulesController{RulesService: serviceMock}
+
+	controller.DeleteUserTier(context)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `{"message":"user tier deleted","status":"success"}`, recorder.Body.String())
+	assert.Equal(t, "user1", serviceMock.DeleteUserTierCalls()[0].UserID)
+}
+
+func TestRulesController_GetUserQuietHours(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"userId":"user1","timezone":"Europe/Madrid","override":{"start":"23:00","end":"09:00"}}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetUserQuietHoursFunc = func(userID string) (*domain.UserQuietHours, error) {
+					return &domain.UserQuietHours{UserID: userID, Timezone: "Europe/Madrid", Override: &domain.QuietHours{Start: "23:00", End: "09:00"}}, nil
+				}
+			},
+		},
+		{
+			name:             "not found",
+			expectedCode:     http.StatusNotFound,
+			expectedResponse: `{"message":"user has no quiet hours settings","error":"user quiet hours not found","status":404}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.GetUserQuietHoursFunc = func(userID string) (*domain.UserQuietHours, error) {
+					return nil, errors.ErrUserQuietHoursNotFound
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodGet, "/admin/users/user1/quiet-hours", "", gin.Params{{Key: "user_id", Value: "user1"}})
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.GetUserQuietHours(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_SetUserQuietHours(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		body                   string
+		expectedCode           int
+		expectedResponse       string
+		rulesServiceMockConfig func(*RulesServiceMock)
+	}{
+		{
+			name:             "success",
+			body:             `{"timezone":"Europe/Madrid","disabled":true}`,
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"userId":"user1","timezone":"Europe/Madrid","disabled":true}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.SetUserQuietHoursFunc = func(userQuietHours *domain.UserQuietHours) error {
+					return nil
+				}
+			},
+		},
+		{
+			name:                   "invalid body",
+			body:                   `{"disabled":"yes"}`,
+			expectedCode:           http.StatusBadRequest,
+			expectedResponse:       `{"message":"invalid quiet hours body","error":"json: cannot unmarshal string into Go struct field UserQuietHours.disabled of type bool","status":400}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {},
+		},
+		{
+			name:             "invalid quiet hours",
+			body:             `{"timezone":"Mars/Olympus_Mons"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: `{"message":"invalid quiet hours","error":"invalid quiet hours: unknown timezone 'Mars/Olympus_Mons'","status":400}`,
+			rulesServiceMockConfig: func(mock *RulesServiceMock) {
+				mock.SetUserQuietHoursFunc = func(userQuietHours *domain.UserQuietHours) error {
+					return fmt.Errorf("%w: unknown timezone 'Mars/Olympus_Mons'", errors.ErrInvalidQuietHours)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, context := newRulesTestContext(http.MethodPut, "/admin/users/user1/quiet-hours", tc.body, gin.Params{{Key: "user_id", Value: "user1"}})
+			serviceMock := &RulesServiceMock{}
+			tc.rulesServiceMockConfig(serviceMock)
+			controller := RulesController{RulesService: serviceMock}
+
+			controller.SetUserQuietHours(context)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
+
+func TestRulesController_DeleteUserQuietHours(t *testing.T) {
+	recorder, context := newRulesTestContext(http.MethodDelete, "/admin/users/user1/quiet-hours", "", gin.Params{{Key: "user_id", Value: "user1"}})
+	serviceMock := &RulesServiceMock{
+		DeleteUserQuietHoursFunc: func(userID string) error {
+			return nil
+		},
+	}
+	controller := RulesController{RulesService: serviceMock}
+
+	controller.DeleteUserQuietHours(context)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `{"message":"user quiet hours deleted","status":"success"}`, recorder.Body.String())
+	assert.Equal(t, "user1", serviceMock.DeleteUserQuietHoursCalls()[0].UserID)
+}
@@ -0,0 +1,134 @@
+package clients
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+const (
+	// RedisFallbackFail makes the startup fail when Redis is unreachable.
+	RedisFallbackFail = "fail"
+	// RedisFallbackMemory uses the in memory storage when Redis is unreachable.
+	RedisFallbackMemory = "memory"
+)
+
+type RedisConfig struct {
+	Mode string
+	// Addrs has a single address in standalone mode, and the seed nodes in sentinel and cluster modes.
+	Addrs          []string
+	SentinelMaster string
+	Username       string
+	Password       string
+	DB             int
+	TLS            bool
+	PoolSize       int
+	DialTimeout    time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	Fallback       string
+}
+
+// LoadRedisConfig reads the Redis configuration from the environment.
+func LoadRedisConfig() (RedisConfig, error) {
+	config := RedisConfig{
+		Mode:           GetEnv("REDIS_MODE", RedisModeStandalone),
+		Addrs:          strings.Split(GetEnv("REDIS_ADDR", "localhost:6379"), ","),
+		SentinelMaster: os.Getenv("REDIS_SENTINEL_MASTER"),
+		Username:       os.Getenv("REDIS_USERNAME"),
+		Password:       os.Getenv("REDIS_PASSWORD"),
+		Fallback:       GetEnv("REDIS_FALLBACK", RedisFallbackFail),
+	}
+
+	var err error
+	if config.DB, err = strconv.Atoi(GetEnv("REDIS_DB", "0")); err != nil {
+		return config, fmt.Errorf("invalid REDIS_DB: %w", err)
+	}
+	if config.TLS, err = strconv.ParseBool(GetEnv("REDIS_TLS", "false")); err != nil {
+		return config, fmt.Errorf("invalid REDIS_TLS: %w", err)
+	}
+	if config.PoolSize, err = strconv.Atoi(GetEnv("REDIS_POOL_SIZE", "0")); err != nil {
+		return config, fmt.Errorf("invalid REDIS_POOL_SIZE: %w", err)
+	}
+	if config.DialTimeout, err = time.ParseDuration(GetEnv("REDIS_DIAL_TIMEOUT", "5s")); err != nil {
+		return config, fmt.Errorf("invalid REDIS_DIAL_TIMEOUT: %w", err)
+	}
+	if config.ReadTimeout, err = time.ParseDuration(GetEnv("REDIS_READ_TIMEOUT", "3s")); err != nil {
+		return config, fmt.Errorf("invalid REDIS_READ_TIMEOUT: %w", err)
+	}
+	if config.WriteTimeout, err = time.ParseDuration(GetEnv("REDIS_WRITE_TIMEOUT", "3s")); err != nil {
+		return config, fmt.Errorf("invalid REDIS_WRITE_TIMEOUT: %w", err)
+	}
+
+	switch config.Mode {
+	case RedisModeStandalone, RedisModeCluster:
+	case RedisModeSentinel:
+		if config.SentinelMaster == "" {
+			return config, fmt.Errorf("REDIS_SENTINEL_MASTER is mandatory in sentinel mode")
+		}
+	default:
+		return config, fmt.Errorf("unknown REDIS_MODE: '%s'", config.Mode)
+	}
+	if config.Fallback != RedisFallbackFail && config.Fallback != RedisFallbackMemory {
+		return config, fmt.Errorf("unknown REDIS_FALLBACK: '%s'", config.Fallback)
+	}
+	return config, nil
+}
+
+// NewRedisClient connects to Redis, and returns an error if it is unreachable.
+func NewRedisClient(config RedisConfig) (redis.UniversalClient, error) {
+	options := &redis.UniversalOptions{
+		Addrs:        config.Addrs,
+		MasterName:   config.SentinelMaster,
+		Username:     config.Username,
+		Password:     config.Password,
+		DB:           config.DB,
+		PoolSize:     config.PoolSize,
+		DialTimeout:  config.DialTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	}
+	if config.TLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	var client redis.UniversalClient
+	switch config.Mode {
+	case RedisModeSentinel:
+		client = redis.NewFailoverClient(options.Failover())
+	case RedisModeCluster:
+		client = redis.NewClusterClient(options.Cluster())
+	default:
+		client = redis.NewClient(options.Simple())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.DialTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("error connecting to Redis at %s: %w", strings.Join(config.Addrs, ","), err)
+	}
+	fmt.Printf("Connected to Redis at %s (%s mode)\n", strings.Join(config.Addrs, ","), config.Mode)
+	return client, nil
+}
+
+// GetEnv returns the environment variable, or defaultValue when it is not set.
+func GetEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,127 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRedisConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         map[string]string
+		expected    RedisConfig
+		expectedErr string
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			expected: RedisConfig{
+				Mode:         RedisModeStandalone,
+				Addrs:        []string{"localhost:6379"},
+				DialTimeout:  5 * time.Second,
+				ReadTimeout:  3 * time.Second,
+				WriteTimeout: 3 * time.Second,
+				Fallback:     RedisFallbackFail,
+			},
+		},
+		{
+			name: "sentinel",
+			env: map[string]string{
+				"REDIS_MODE":            "sentinel",
+				"REDIS_ADDR":            "sentinel-1:26379,sentinel-2:26379",
+				"REDIS_SENTINEL_MASTER": "mymaster",
+				"REDIS_PASSWORD":        "secret",
+				"REDIS_DB":              "2",
+				"REDIS_TLS":             "true",
+				"REDIS_POOL_SIZE":       "20",
+				"REDIS_DIAL_TIMEOUT":    "1s",
+				"REDIS_READ_TIMEOUT":    "500ms",
+				"REDIS_WRITE_TIMEOUT":   "250ms",
+				"REDIS_FALLBACK":        "memory",
+			},
+			expected: RedisConfig{
+				Mode:           RedisModeSentinel,
+				Addrs:          []string{"sentinel-1:26379", "sentinel-2:26379"},
+				SentinelMaster: "mymaster",
+				Password:       "secret",
+				DB:             2,
+				TLS:            true,
+				PoolSize:       20,
+				DialTimeout:    time.Second,
+				ReadTimeout:    500 * time.Millisecond,
+				WriteTimeout:   250 * time.Millisecond,
+				Fallback:       RedisFallbackMemory,
+			},
+		},
+		{
+			name:        "sentinel without master",
+			env:         map[string]string{"REDIS_MODE": "sentinel"},
+			expectedErr: "REDIS_SENTINEL_MASTER is mandatory in sentinel mode",
+		},
+		{
+			name:        "unknown mode",
+			env:         map[string]string{"REDIS_MODE": "replica"},
+			expectedErr: "unknown REDIS_MODE: 'replica'",
+		},
+		{
+			name:        "invalid db",
+			env:         map[string]string{"REDIS_DB": "first"},
+			expectedErr: `invalid REDIS_DB: strconv.Atoi: parsing "first": invalid syntax`,
+		},
+		{
+			name:        "invalid timeout",
+			env:         map[string]string{"REDIS_READ_TIMEOUT": "3"},
+			expectedErr: `invalid REDIS_READ_TIMEOUT: time: missing unit in duration "3"`,
+		},
+		{
+			name:        "unknown fallback",
+			env:         map[string]string{"REDIS_FALLBACK": "file"},
+			expectedErr: "unknown REDIS_FALLBACK: 'file'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			config, err := LoadRedisConfig()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, config)
+		})
+	}
+}
+
+func TestNewRedisClient(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	client, err := NewRedisClient(RedisConfig{
+		Mode:        RedisModeStandalone,
+		Addrs:       []string{server.Addr()},
+		DialTimeout: time.Second,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+}
+
+func TestNewRedisClient_Unreachable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	client, err := NewRedisClient(RedisConfig{
+		Mode:        RedisModeStandalone,
+		Addrs:       []string{addr},
+		DialTimeout: time.Second,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, client)
+}
@@ -0,0 +1,67 @@
+package delayed
+
+import (
+	"fmt"
+	"rate-limiter/domain"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type delayedNotificationsContainer interface {
+	AddDelayedNotification(notification *domain.DelayedNotification) error
+	PopDueNotifications(now time.Time, limit int) ([]*domain.DelayedNotification, error)
+	GetDelayedNotifications() ([]*domain.DelayedNotification, error)
+}
+
+func testContainers(t *testing.T) map[string]delayedNotificationsContainer {
+	server := miniredis.RunT(t)
+	return map[string]delayedNotificationsContainer{
+		"memory": NewInMemoryContainer(),
+		"redis":  NewRedisContainer(redis.NewClient(&redis.Options{Addr: server.Addr()})),
+	}
+}
+
+func TestDelayedNotificationsContainer_PopDueNotifications(t *testing.T) {
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i, offset := range []time.Duration{2 * time.Minute, -time.Minute, 0, -2 * time.Minute} {
+				require.NoError(t, container.AddDelayedNotification(&domain.DelayedNotification{
+					ID:               fmt.Sprint(i),
+					UserID:           "user1",
+					NotificationType: "status",
+					CreatedAt:        now.Add(-time.Hour),
+					DeliverAt:        now.Add(offset),
+				}))
+			}
+
+			due, err := container.PopDueNotifications(now, 2)
+			require.NoError(t, err)
+			require.Len(t, due, 2)
+			// The earliest are delivered first.
+			assert.Equal(t, "3", due[0].ID)
+			assert.Equal(t, "1", due[1].ID)
+			assert.Equal(t, "user1", due[0].UserID)
+			assert.True(t, now.Add(-2*time.Minute).Equal(due[0].DeliverAt))
+
+			due, err = container.PopDueNotifications(now, 2)
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, "2", due[0].ID)
+
+			// The notifications are removed once taken, so they are delivered once.
+			due, err = container.PopDueNotifications(now, 2)
+			require.NoError(t, err)
+			assert.Empty(t, due)
+			pending, err := container.GetDelayedNotifications()
+			require.NoError(t, err)
+			require.Len(t, pending, 1)
+			assert.Equal(t, "0", pending[0].ID)
+		})
+	}
+}
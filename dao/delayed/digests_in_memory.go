@@ -0,0 +1,84 @@
+package delayed
+
+import (
+	"rate-limiter/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InMemoryDigestsContainer keeps the digests in memory, so they are lost on restart.
+type InMemoryDigestsContainer struct {
+	digests map[string]*domain.Digest
+	mutex   *sync.Mutex
+}
+
+func NewInMemoryDigestsContainer() *InMemoryDigestsContainer {
+	return &InMemoryDigestsContainer{
+		digests: map[string]*domain.Digest{},
+		mutex:   &sync.Mutex{},
+	}
+}
+
+func (ic *InMemoryDigestsContainer) AddToDigest(digest *domain.Digest) (*domain.Digest, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	stored, ok := ic.digests[digest.Key()]
+	if !ok {
+		stored = copyDigest(digest)
+		ic.digests[digest.Key()] = stored
+	} else {
+		stored.Merge(digest)
+	}
+	return copyDigest(stored), nil
+}
+
+func (ic *InMemoryDigestsContainer) PopDueDigests(now time.Time, limit int) ([]*domain.Digest, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	due := []*domain.Digest{}
+	for _, digest := range ic.digests {
+		if !digest.DeliverAt.After(now) {
+			due = append(due, digest)
+		}
+	}
+	sortDigestsByDeliverAt(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, digest := range due {
+		delete(ic.digests, digest.Key())
+	}
+	return due, nil
+}
+
+func (ic *InMemoryDigestsContainer) GetDigests() ([]*domain.Digest, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	digests := []*domain.Digest{}
+	for _, digest := range ic.digests {
+		digests = append(digests, copyDigest(digest))
+	}
+	sortDigestsByDeliverAt(digests)
+	return digests, nil
+}
+
+// copyDigest copies the digest with its own payloads, so the stored one isn't changed by the caller.
+func copyDigest(digest *domain.Digest) *domain.Digest {
+	digestCopy := *digest
+	digestCopy.Payloads = append(digest.Payloads[:0:0], digest.Payloads...)
+	return &digestCopy
+}
+
+// sortDigestsByDeliverAt sorts the digests by delivery time, and by key the ones with the same one.
+func sortDigestsByDeliverAt(digests []*domain.Digest) {
+	sort.Slice(digests, func(i, j int) bool {
+		if !digests[i].DeliverAt.Equal(digests[j].DeliverAt) {
+			return digests[i].DeliverAt.Before(digests[j].DeliverAt)
+		}
+		return digests[i].Key() < digests[j].Key()
+	})
+}
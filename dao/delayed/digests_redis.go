@@ -0,0 +1,130 @@
+package delayed
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"rate-limiter/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The digests keys share the hash tag, so that they hash to the same Redis Cluster slot and can be
+// used by the same script.
+const (
+	// digestsKey is a hash with the json encoded digests by key.
+	digestsKey = "{digests}"
+	// dueDigestsKey is a sorted set with the keys of the digests, scored by their delivery time in
+	// milliseconds.
+	dueDigestsKey = "{digests}:due"
+)
+
+const maxTxRetries = 10
+
+// popDueDigestsScript removes and returns the digests due at ARGV[1], up to ARGV[2], so that each
+// one is sent by a single replica.
+var popDueDigestsScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+local digests = {}
+for i, key in ipairs(due) do
+	digests[i] = redis.call('HGET', KEYS[1], key)
+end
+if #due > 0 then
+	redis.call('ZREM', KEYS[2], unpack(due))
+	redis.call('HDEL', KEYS[1], unpack(due))
+end
+return digests
+`)
+
+// RedisDigestsContainer stores the digests in Redis, so the notifications of a user over the limit
+// are collected in the same digest by all the replicas.
+type RedisDigestsContainer struct {
+	Client redis.UniversalClient
+}
+
+func NewRedisDigestsContainer(client redis.UniversalClient) *RedisDigestsContainer {
+	return &RedisDigestsContainer{
+		Client: client,
+	}
+}
+
+// AddToDigest merges the digest into the stored one in an optimistic transaction, which is retried
+// if another replica changes the digests at the same time.
+func (rc *RedisDigestsContainer) AddToDigest(digest *domain.Digest) (*domain.Digest, error) {
+	ctx := context.Background()
+	var stored *domain.Digest
+	addToDigest := func(tx *redis.Tx) error {
+		data, err := tx.HGet(ctx, digestsKey, digest.Key()).Result()
+		switch {
+		case stderrors.Is(err, redis.Nil):
+			stored = copyDigest(digest)
+		case err != nil:
+			return err
+		default:
+			stored = &domain.Digest{}
+			if err := json.Unmarshal([]byte(data), stored); err != nil {
+				return err
+			}
+			stored.Merge(digest)
+		}
+
+		newData, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, digestsKey, digest.Key(), newData)
+			pipe.ZAdd(ctx, dueDigestsKey, redis.Z{Score: float64(stored.DeliverAt.UnixMilli()), Member: digest.Key()})
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxTxRetries; i++ {
+		err := rc.Client.Watch(ctx, addToDigest, digestsKey)
+		if stderrors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return stored, nil
+	}
+	return nil, fmt.Errorf("error adding to digest: %w", redis.TxFailedErr)
+}
+
+func (rc *RedisDigestsContainer) PopDueDigests(now time.Time, limit int) ([]*domain.Digest, error) {
+	values, err := popDueDigestsScript.Run(context.Background(), rc.Client,
+		[]string{digestsKey, dueDigestsKey}, now.UnixMilli(), limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	return decodeDigests(values)
+}
+
+func (rc *RedisDigestsContainer) GetDigests() ([]*domain.Digest, error) {
+	values, err := rc.Client.HVals(context.Background(), digestsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	digests, err := decodeDigests(values)
+	if err != nil {
+		return nil, err
+	}
+	sortDigestsByDeliverAt(digests)
+	return digests, nil
+}
+
+func decodeDigests(values []string) ([]*domain.Digest, error) {
+	digests := []*domain.Digest{}
+	for _, value := range values {
+		var digest domain.Digest
+		if err := json.Unmarshal([]byte(value), &digest); err != nil {
+			return nil, err
+		}
+		digests = append(digests, &digest)
+	}
+	return digests, nil
+}
@@ -0,0 +1,100 @@
+package delayed
+
+import (
+	"encoding/json"
+	"fmt"
+	"rate-limiter/domain"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type digestsContainer interface {
+	AddToDigest(digest *domain.Digest) (*domain.Digest, error)
+	PopDueDigests(now time.Time, limit int) ([]*domain.Digest, error)
+	GetDigests() ([]*domain.Digest, error)
+}
+
+func testDigestsContainers(t *testing.T) map[string]digestsContainer {
+	server := miniredis.RunT(t)
+	return map[string]digestsContainer{
+		"memory": NewInMemoryDigestsContainer(),
+		"redis":  NewRedisDigestsContainer(redis.NewClient(&redis.Options{Addr: server.Addr()})),
+	}
+}
+
+func TestDigestsContainer_AddToDigest(t *testing.T) {
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	for containerName, container := range testDigestsContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i, userID := range []string{"user1", "user1", "user2"} {
+				_, err := container.AddToDigest(domain.NewDigest(&domain.DelayedNotification{
+					UserID:           userID,
+					NotificationType: "status",
+					Payload:          json.RawMessage(fmt.Sprintf(`{"id":%d}`, i)),
+					CreatedAt:        now.Add(time.Duration(i) * time.Minute),
+				}, now.Add(time.Hour+time.Duration(i)*time.Minute)))
+				require.NoError(t, err)
+			}
+
+			digests, err := container.GetDigests()
+			require.NoError(t, err)
+			require.Len(t, digests, 2)
+			assert.Equal(t, "user1", digests[0].UserID)
+			assert.Equal(t, 2, digests[0].Count)
+			assert.Equal(t, []json.RawMessage{json.RawMessage(`{"id":0}`), json.RawMessage(`{"id":1}`)}, digests[0].Payloads)
+			// The digest is sent when the first notification over the limit was due.
+			assert.True(t, now.Add(time.Hour).Equal(digests[0].DeliverAt))
+			assert.Equal(t, "user2", digests[1].UserID)
+			assert.Equal(t, 1, digests[1].Count)
+		})
+	}
+}
+
+func TestDigestsContainer_PopDueDigests(t *testing.T) {
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	for containerName, container := range testDigestsContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i, offset := range []time.Duration{time.Minute, -time.Minute, 0} {
+				_, err := container.AddToDigest(domain.NewDigest(&domain.DelayedNotification{
+					UserID:           fmt.Sprint("user", i),
+					NotificationType: "status",
+					CreatedAt:        now.Add(-time.Hour),
+				}, now.Add(offset)))
+				require.NoError(t, err)
+			}
+
+			due, err := container.PopDueDigests(now, 1)
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, "user1", due[0].UserID)
+
+			due, err = container.PopDueDigests(now, 10)
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, "user2", due[0].UserID)
+
+			// A digest is sent once, and the notifications over the limit after it start a new one.
+			due, err = container.PopDueDigests(now, 10)
+			require.NoError(t, err)
+			assert.Empty(t, due)
+			digest, err := container.AddToDigest(domain.NewDigest(&domain.DelayedNotification{
+				UserID:           "user1",
+				NotificationType: "status",
+				CreatedAt:        now,
+			}, now.Add(time.Hour)))
+			require.NoError(t, err)
+			assert.Equal(t, 1, digest.Count)
+
+			pending, err := container.GetDigests()
+			require.NoError(t, err)
+			require.Len(t, pending, 2)
+			assert.Equal(t, "user0", pending[0].UserID)
+			assert.Equal(t, "user1", pending[1].UserID)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package delayed
+
+import (
+	"rate-limiter/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InMemoryContainer keeps the delayed notifications in memory, so they are lost on restart.
+type InMemoryContainer struct {
+	notifications map[string]*domain.DelayedNotification
+	mutex         *sync.Mutex
+}
+
+func NewInMemoryContainer() *InMemoryContainer {
+	return &InMemoryContainer{
+		notifications: map[string]*domain.DelayedNotification{},
+		mutex:         &sync.Mutex{},
+	}
+}
+
+func (ic *InMemoryContainer) AddDelayedNotification(notification *domain.DelayedNotification) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	notificationCopy := *notification
+	ic.notifications[notification.ID] = &notificationCopy
+	return nil
+}
+
+func (ic *InMemoryContainer) PopDueNotifications(now time.Time, limit int) ([]*domain.DelayedNotification, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	due := []*domain.DelayedNotification{}
+	for _, notification := range ic.notifications {
+		if !notification.DeliverAt.After(now) {
+			due = append(due, notification)
+		}
+	}
+	sortByDeliverAt(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, notification := range due {
+		delete(ic.notifications, notification.ID)
+	}
+	return due, nil
+}
+
+func (ic *InMemoryContainer) GetDelayedNotifications() ([]*domain.DelayedNotification, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	notifications := []*domain.DelayedNotification{}
+	for _, notification := range ic.notifications {
+		notificationCopy := *notification
+		notifications = append(notifications, &notificationCopy)
+	}
+	sortByDeliverAt(notifications)
+	return notifications, nil
+}
+
+// sortByDeliverAt sorts the notifications by delivery time, and by ID the ones with the same one.
+func sortByDeliverAt(notifications []*domain.DelayedNotification) {
+	sort.Slice(notifications, func(i, j int) bool {
+		if !notifications[i].DeliverAt.Equal(notifications[j].DeliverAt) {
+			return notifications[i].DeliverAt.Before(notifications[j].DeliverAt)
+		}
+		return notifications[i].ID < notifications[j].ID
+	})
+}
@@ -0,0 +1,76 @@
+package delayed
+
+import (
+	"context"
+	"encoding/json"
+	"rate-limiter/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// delayedNotificationsKey is a sorted set with the json encoded delayed notifications, scored by
+// their delivery time in milliseconds.
+const delayedNotificationsKey = "delayed_notifications"
+
+// popDueNotificationsScript removes and returns the notifications due at ARGV[1], up to ARGV[2], so
+// that each one is delivered by a single replica.
+var popDueNotificationsScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #due > 0 then
+	redis.call('ZREM', KEYS[1], unpack(due))
+end
+return due
+`)
+
+// RedisContainer stores the delayed notifications in Redis, so they survive restarts and are
+// delivered by any of the replicas.
+type RedisContainer struct {
+	Client redis.UniversalClient
+}
+
+func NewRedisContainer(client redis.UniversalClient) *RedisContainer {
+	return &RedisContainer{
+		Client: client,
+	}
+}
+
+func (rc *RedisContainer) AddDelayedNotification(notification *domain.DelayedNotification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return rc.Client.ZAdd(context.Background(), delayedNotificationsKey, redis.Z{
+		Score:  float64(notification.DeliverAt.UnixMilli()),
+		Member: data,
+	}).Err()
+}
+
+func (rc *RedisContainer) PopDueNotifications(now time.Time, limit int) ([]*domain.DelayedNotification, error) {
+	members, err := popDueNotificationsScript.Run(context.Background(), rc.Client,
+		[]string{delayedNotificationsKey}, now.UnixMilli(), limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	return decodeNotifications(members)
+}
+
+func (rc *RedisContainer) GetDelayedNotifications() ([]*domain.DelayedNotification, error) {
+	members, err := rc.Client.ZRange(context.Background(), delayedNotificationsKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeNotifications(members)
+}
+
+func decodeNotifications(members []string) ([]*domain.DelayedNotification, error) {
+	notifications := []*domain.DelayedNotification{}
+	for _, member := range members {
+		var notification domain.DelayedNotification
+		if err := json.Unmarshal([]byte(member), &notification); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &notification)
+	}
+	return notifications, nil
+}
@@ -1,31 +1,254 @@
 package dao
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"rate-limiter/dao/clients"
+	"rate-limiter/dao/delayed"
 	"rate-limiter/dao/notifications"
 	"rate-limiter/dao/rules"
+	"rate-limiter/domain"
 	"rate-limiter/services"
+	"rate-limiter/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func NewRulesContainer() services.RulesContainer {
-	return rules.NewInMemoryRulesContainer()
+// NewRulesContainer returns the rules container of RULES_DAO_TYPE.
+func NewRulesContainer() (services.RulesContainer, error) {
+	daoType := getRulesDAOType()
+	fmt.Printf("Container Rules DAO_TYPE: %s\n", daoType)
+	switch daoType {
+	case "memory":
+		return newInMemoryRulesContainer(), nil
+	case "redis":
+		return newRedisRulesContainer()
+	default:
+		fmt.Printf("unknown Rules DAO type: '%s'. Load default in memory\n", daoType)
+		return newInMemoryRulesContainer(), nil
+	}
+}
+
+// newInMemoryRulesContainer returns the in memory rules container, which reloads the rules file
+// when it changes or the process receives a SIGHUP.
+func newInMemoryRulesContainer() services.RulesContainer {
+	container := rules.NewInMemoryRulesContainer()
+	if _, err := container.Watch(); err != nil {
+		fmt.Println("Rules - error watching rules file, hot reload disabled:", err)
+	}
+	return container
 }
 
-func NewNotificationContainer() services.NotificationsContainer {
+func newRedisRulesContainer() (services.RulesContainer, error) {
+	client, err := sharedRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return newInMemoryRulesContainer(), nil
+	}
+	return rules.NewRedisRulesContainer(client, utils.RulesFilePath)
+}
+
+// NewNotificationContainer returns the notifications container of NOTIFICATIONS_DAO_TYPE. getRules
+// is used to evict the expired notifications of the in memory container.
+func NewNotificationContainer(getRules func() (map[string][]*domain.RateLimitRule, error)) (services.NotificationsContainer, error) {
 	daoType := getNotificationsDAOType()
 	fmt.Printf("Container Notifications DAO_TYPE: %s\n", daoType)
 	switch daoType {
 	case "memory":
-		return notifications.NewInMemoryNotificationsContainer()
+		return newInMemoryNotificationsContainer(getRules)
 	case "redis":
-		return notifications.NewRedisContainer()
+		return newRedisNotificationsContainer(getRules)
+	case "file":
+		return newFileNotificationsContainer()
+	case "memcached":
+		return newMemcachedNotificationsContainer()
+	case "mongo":
+		return newMongoNotificationsContainer()
 	default:
 		fmt.Printf("unknown Notifications DAO type: '%s'. Load default in memory\n", daoType)
-		return notifications.NewInMemoryNotificationsContainer()
+		return newInMemoryNotificationsContainer(getRules)
+	}
+}
+
+func newInMemoryNotificationsContainer(getRules func() (map[string][]*domain.RateLimitRule, error)) (services.NotificationsContainer, error) {
+	janitorInterval, err := time.ParseDuration(clients.GetEnv("JANITOR_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JANITOR_INTERVAL: %w", err)
+	}
+
+	container := notifications.NewInMemoryNotificationsContainer()
+	container.StartJanitor(janitorInterval, getRules)
+	return container, nil
+}
+
+// newFileNotificationsContainer returns the container stored in NOTIFICATIONS_FILE_PATH, which
+// removes the expired entries every JANITOR_INTERVAL.
+func newFileNotificationsContainer() (services.NotificationsContainer, error) {
+	compactionInterval, err := time.ParseDuration(clients.GetEnv("JANITOR_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JANITOR_INTERVAL: %w", err)
+	}
+
+	container, err := notifications.NewFileContainer(clients.GetEnv("NOTIFICATIONS_FILE_PATH", "./data/notifications.db"))
+	if err != nil {
+		return nil, err
+	}
+	container.StartCompactor(compactionInterval)
+	return container, nil
+}
+
+// newMemcachedNotificationsContainer returns the container of the comma separated servers of
+// MEMCACHED_ADDR.
+func newMemcachedNotificationsContainer() (services.NotificationsContainer, error) {
+	servers := strings.Split(clients.GetEnv("MEMCACHED_ADDR", "localhost:11211"), ",")
+	client := memcache.New(servers...)
+	if err := client.Ping(); err != nil {
+		return nil, fmt.Errorf("error connecting to memcached %v: %w", servers, err)
+	}
+	return notifications.NewMemcachedContainer(client), nil
+}
+
+// newMongoNotificationsContainer returns the container of the MONGO_DATABASE database of MONGO_URI,
+// which keeps the notifications for at least MONGO_HISTORY_RETENTION.
+func newMongoNotificationsContainer() (services.NotificationsContainer, error) {
+	historyRetention, err := time.ParseDuration(clients.GetEnv("MONGO_HISTORY_RETENTION", "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MONGO_HISTORY_RETENTION: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(clients.GetEnv("MONGO_URI", "mongodb://localhost:27017")))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, fmt.Errorf("error connecting to mongo: %w", err)
+	}
+	return notifications.NewMongoContainer(ctx, client, clients.GetEnv("MONGO_DATABASE", "rate_limiter"), historyRetention)
+}
+
+func newRedisNotificationsContainer(getRules func() (map[string][]*domain.RateLimitRule, error)) (services.NotificationsContainer, error) {
+	client, err := sharedRedisClient()
+	if err != nil {
+		return nil, err
 	}
+	if client == nil {
+		return newInMemoryNotificationsContainer(getRules)
+	}
+	return notifications.NewRedisContainer(client), nil
+}
+
+// NewDelayedNotificationsContainer returns the delayed notifications container of
+// DELAYED_NOTIFICATIONS_DAO_TYPE.
+func NewDelayedNotificationsContainer() (services.DelayedNotificationsContainer, error) {
+	daoType := getDelayedNotificationsDAOType()
+	fmt.Printf("Container Delayed Notifications DAO_TYPE: %s\n", daoType)
+	switch daoType {
+	case "memory":
+		return delayed.NewInMemoryContainer(), nil
+	case "redis":
+		return newRedisDelayedNotificationsContainer()
+	default:
+		fmt.Printf("unknown Delayed Notifications DAO type: '%s'. Load default in memory\n", daoType)
+		return delayed.NewInMemoryContainer(), nil
+	}
+}
+
+func newRedisDelayedNotificationsContainer() (services.DelayedNotificationsContainer, error) {
+	client, err := sharedRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return delayed.NewInMemoryContainer(), nil
+	}
+	return delayed.NewRedisContainer(client), nil
+}
+
+// NewDigestsContainer returns the digests container of DELAYED_NOTIFICATIONS_DAO_TYPE, which keeps
+// the digests with the delayed notifications.
+func NewDigestsContainer() (services.DigestsContainer, error) {
+	daoType := getDelayedNotificationsDAOType()
+	fmt.Printf("Container Digests DAO_TYPE: %s\n", daoType)
+	switch daoType {
+	case "memory":
+		return delayed.NewInMemoryDigestsContainer(), nil
+	case "redis":
+		return newRedisDigestsContainer()
+	default:
+		fmt.Printf("unknown Digests DAO type: '%s'. Load default in memory\n", daoType)
+		return delayed.NewInMemoryDigestsContainer(), nil
+	}
+}
+
+func newRedisDigestsContainer() (services.DigestsContainer, error) {
+	client, err := sharedRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return delayed.NewInMemoryDigestsContainer(), nil
+	}
+	return delayed.NewRedisDigestsContainer(client), nil
+}
+
+// StartScheduler delivers the delayed notifications and the digests of the containers every
+// SCHEDULER_INTERVAL.
+func StartScheduler(delayedContainer services.DelayedNotificationsContainer, digestsContainer services.DigestsContainer, rateLimitService *services.RateLimitService) error {
+	interval, err := time.ParseDuration(clients.GetEnv("SCHEDULER_INTERVAL", "5s"))
+	if err != nil {
+		return fmt.Errorf("invalid SCHEDULER_INTERVAL: %w", err)
+	}
+	services.NewScheduler(delayedContainer, digestsContainer, rateLimitService).Start(interval)
+	return nil
+}
+
+func getRulesDAOType() string {
+	return os.Getenv("RULES_DAO_TYPE")
 }
 
 func getNotificationsDAOType() string {
 	return os.Getenv("NOTIFICATIONS_DAO_TYPE")
 }
+
+func getDelayedNotificationsDAOType() string {
+	return os.Getenv("DELAYED_NOTIFICATIONS_DAO_TYPE")
+}
+
+var (
+	redisClientOnce sync.Once
+	redisClient     redis.UniversalClient
+	redisClientErr  error
+)
+
+// sharedRedisClient connects to Redis the first time it is called, and returns the same client to
+// all the containers, so they share its connection pool. The client is nil when Redis is
+// unreachable and REDIS_FALLBACK is memory, so the containers are loaded in memory.
+func sharedRedisClient() (redis.UniversalClient, error) {
+	redisClientOnce.Do(func() {
+		config, err := clients.LoadRedisConfig()
+		if err != nil {
+			redisClientErr = err
+			return
+		}
+		redisClient, err = clients.NewRedisClient(config)
+		if err != nil {
+			if config.Fallback == clients.RedisFallbackMemory {
+				fmt.Printf("%s. Load in memory\n", err)
+				return
+			}
+			redisClientErr = err
+		}
+	})
+	return redisClient, redisClientErr
+}
@@ -0,0 +1,275 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rate-limiter/domain"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	notificationsBucket = []byte("notifications")
+	ruleStatesBucket    = []byte("rule_states")
+)
+
+// FileContainer stores the notifications in a bbolt database on the local disk, so the quotas of the
+// users survive restarts without an external database. Every write is a bbolt transaction, which
+// are serialized, so checking the rules and registering a notification is atomic.
+//
+// The notifications are stored with the key "notifications:{user}:type" followed by their timestamp
+// and a sequence number, so the notifications of a user are sorted by time, and with their expiration
+// as value. The rule states are stored as json, together with their expiration.
+type FileContainer struct {
+	db        *bolt.DB
+	compactor *compactor
+}
+
+type storedRuleState struct {
+	State     *domain.RuleState `json:"state"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+type compactor struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFileContainer opens the database of filePath, creating it if it doesn't exist.
+func NewFileContainer(filePath string) (*FileContainer, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filePath, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening notifications file %s: %w", filePath, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(notificationsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(ruleStatesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &FileContainer{db: db}, nil
+}
+
+// Close stops the compactor and closes the database.
+func (fc *FileContainer) Close() error {
+	fc.StopCompactor()
+	return fc.db.Close()
+}
+
+func (fc *FileContainer) GetNotificationsByUser(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+	notificationsToReturn := []*domain.Notification{}
+	err := fc.db.View(func(tx *bolt.Tx) error {
+		prefix := notificationsPrefix(params.UserID, params.NotificationType)
+		forEachNotificationSince(tx, prefix, time.Now().Add(-params.TimeInterval), func(timestamp time.Time) {
+			notificationsToReturn = append(notificationsToReturn, &domain.Notification{
+				Timestamp: timestamp,
+				UserID:    params.UserID,
+				Type:      params.NotificationType,
+			})
+		})
+		return nil
+	})
+	return notificationsToReturn, err
+}
+
+func (fc *FileContainer) AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+	var result *domain.AddNotificationResult
+	err := fc.db.Update(func(tx *bolt.Tx) error {
+		states := tx.Bucket(ruleStatesBucket)
+		var nextStates map[string]*storedRuleState
+		var err error
+		result, nextStates, err = checkRules(params,
+			func(rule *domain.RateLimitRule, since time.Time) (int, error) {
+				sent := 0
+				prefix := notificationsPrefix(params.UserID, historyType(params, rule))
+				forEachNotificationSince(tx, prefix, since, func(time.Time) { sent++ })
+				return sent, nil
+			},
+			func(stateKey string) (*domain.RuleState, error) {
+				return getStoredRuleState(states, stateKey)
+			},
+		)
+		if err != nil || result.RejectedBy != nil {
+			return err
+		}
+
+		for stateKey, nextState := range nextStates {
+			data, err := json.Marshal(nextState)
+			if err != nil {
+				return err
+			}
+			if err := states.Put([]byte(stateKey), data); err != nil {
+				return err
+			}
+		}
+		notifications := tx.Bucket(notificationsBucket)
+		for notificationType, retention := range historyRetentions(params) {
+			sequence, err := notifications.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := notificationKey(notificationsPrefix(params.UserID, notificationType), params.Timestamp, sequence)
+			if err := notifications.Put(key, encodeTime(params.Timestamp.Add(retention))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (fc *FileContainer) GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error) {
+	var state *domain.RuleState
+	err := fc.db.View(func(tx *bolt.Tx) error {
+		var err error
+		state, err = getStoredRuleState(tx.Bucket(ruleStatesBucket), ruleStateKey(params))
+		return err
+	})
+	return state, err
+}
+
+// Compact removes the notifications and rule states that expired before now, and returns how many
+// entries were removed. bbolt reuses the pages they free, so the file stops growing once the
+// entries expire as fast as they are added.
+func (fc *FileContainer) Compact(now time.Time) (int64, error) {
+	var removed int64
+	err := fc.db.Update(func(tx *bolt.Tx) error {
+		notifications := tx.Bucket(notificationsBucket)
+		expiredNotifications := [][]byte{}
+		err := notifications.ForEach(func(key, value []byte) error {
+			if decodeTime(value).Before(now) {
+				expiredNotifications = append(expiredNotifications, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		states := tx.Bucket(ruleStatesBucket)
+		expiredStates := [][]byte{}
+		err = states.ForEach(func(key, value []byte) error {
+			var stored storedRuleState
+			if err := json.Unmarshal(value, &stored); err != nil {
+				return err
+			}
+			if stored.ExpiresAt.Before(now) {
+				expiredStates = append(expiredStates, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Keys can't be deleted while iterating the bucket.
+		for _, key := range expiredNotifications {
+			if err := notifications.Delete(key); err != nil {
+				return err
+			}
+		}
+		for _, key := range expiredStates {
+			if err := states.Delete(key); err != nil {
+				return err
+			}
+		}
+		removed = int64(len(expiredNotifications) + len(expiredStates))
+		return nil
+	})
+	return removed, err
+}
+
+// StartCompactor runs Compact every interval.
+func (fc *FileContainer) StartCompactor(interval time.Duration) {
+	fc.compactor = &compactor{stop: make(chan struct{})}
+	go func(compactor *compactor) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-compactor.stop:
+				return
+			case now := <-ticker.C:
+				removed, err := fc.Compact(now)
+				if err != nil {
+					fmt.Println("Compactor - error removing expired entries:", err)
+					continue
+				}
+				if removed > 0 {
+					fmt.Printf("Compactor - removed %d expired entries\n", removed)
+				}
+			}
+		}
+	}(fc.compactor)
+}
+
+// StopCompactor stops the compactor started by StartCompactor.
+func (fc *FileContainer) StopCompactor() {
+	if fc.compactor == nil {
+		return
+	}
+	fc.compactor.stopOnce.Do(func() {
+		close(fc.compactor.stop)
+	})
+}
+
+// getStoredRuleState ignores the expiration of the state, which only matters to Compact: by the
+// time a state expires, it allows the same as a missing one.
+func getStoredRuleState(states *bolt.Bucket, stateKey string) (*domain.RuleState, error) {
+	data := states.Get([]byte(stateKey))
+	if data == nil {
+		return nil, nil
+	}
+	var stored storedRuleState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return stored.State, nil
+}
+
+// forEachNotificationSince calls fn with the timestamp of every notification of prefix after since.
+func forEachNotificationSince(tx *bolt.Tx, prefix []byte, since time.Time, fn func(time.Time)) {
+	cursor := tx.Bucket(notificationsBucket).Cursor()
+	start := append(bytes.Clone(prefix), encodeTime(since.Add(time.Nanosecond))...)
+	for key, _ := cursor.Seek(start); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		fn(decodeTime(key[len(prefix):]))
+	}
+}
+
+// notificationsPrefix ends with a separator that can't be part of a notification type, so the prefix
+// of a type doesn't match the notifications of a longer one.
+func notificationsPrefix(userID, notificationType string) []byte {
+	return []byte(notificationsKey(userID, notificationType) + "\x00")
+}
+
+func notificationKey(prefix []byte, timestamp time.Time, sequence uint64) []byte {
+	key := append(bytes.Clone(prefix), encodeTime(timestamp)...)
+	return binary.BigEndian.AppendUint64(key, sequence)
+}
+
+// encodeTime encodes the time as big endian nanoseconds, so the keys sort by time.
+func encodeTime(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+}
+
+func decodeTime(data []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(data[:8])))
+}
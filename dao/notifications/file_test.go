@@ -0,0 +1,76 @@
+package notifications
+
+import (
+	"path/filepath"
+	"rate-limiter/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileContainer_SurvivesRestart(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "notifications.db")
+	now := time.Now()
+	params := domain.AddNotificationParams{
+		UserID:           "user",
+		NotificationType: "status",
+		Timestamp:        now,
+		Rules: []*domain.RateLimitRule{
+			{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+			{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmGCRA},
+		},
+	}
+
+	container, err := NewFileContainer(filePath)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		result, err := container.AddNotificationIfAllowed(params)
+		require.NoError(t, err)
+		require.Nil(t, result.RejectedBy)
+	}
+	require.NoError(t, container.Close())
+
+	container = newTestFileContainer(t, filePath)
+	result, err := container.AddNotificationIfAllowed(params)
+	assert.NoError(t, err)
+	assert.NotNil(t, result.RejectedBy)
+
+	notifications, err := container.GetNotificationsByUser(domain.GetNotificationParams{
+		UserID:           "user",
+		NotificationType: "status",
+		TimeInterval:     time.Minute,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 2)
+}
+
+func TestFileContainer_Compact(t *testing.T) {
+	container := newTestFileContainer(t, filepath.Join(t.TempDir(), "notifications.db"))
+	start := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Minute}},
+		{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmTokenBucket},
+	}
+	for _, offset := range []time.Duration{0, 30 * time.Second, 2 * time.Minute} {
+		result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+			UserID:           "user",
+			NotificationType: "status",
+			Timestamp:        start.Add(offset),
+			Rules:            rules,
+		})
+		require.NoError(t, err)
+		require.Nil(t, result.RejectedBy)
+	}
+
+	// The history, and the one of every type, are kept for the longest rule that counts them, and the
+	// bucket until it is full again.
+	removed, err := container.Compact(start.Add(2*time.Minute + time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), removed)
+
+	removed, err = container.Compact(start.Add(2 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), removed)
+}
@@ -1,44 +1,150 @@
 package notifications
 
 import (
+	"hash/fnv"
 	"rate-limiter/domain"
-	"strings"
+	"runtime"
 	"sync"
 	"time"
 )
 
+// InMemoryNotificationsContainer splits the users in shards, each one with its own lock, so requests
+// of different users don't wait for each other.
 type InMemoryNotificationsContainer struct {
+	shards  []*shard
+	janitor *janitor
+}
+
+type shard struct {
 	notifications map[string][]*domain.Notification
-	mutex         *sync.Mutex
+	// ruleStates are kept with their expiration, so the janitor removes the states of idle users.
+	ruleStates map[string]*storedRuleState
+	mutex      *sync.Mutex
 }
 
 func NewInMemoryNotificationsContainer() *InMemoryNotificationsContainer {
+	return NewShardedInMemoryNotificationsContainer(4 * runtime.NumCPU())
+}
+
+func NewShardedInMemoryNotificationsContainer(shardsCount int) *InMemoryNotificationsContainer {
+	if shardsCount < 1 {
+		shardsCount = 1
+	}
+
+	shards := make([]*shard, shardsCount)
+	for i := range shards {
+		shards[i] = &shard{
+			notifications: map[string][]*domain.Notification{},
+			ruleStates:    map[string]*storedRuleState{},
+			mutex:         &sync.Mutex{},
+		}
+	}
 	return &InMemoryNotificationsContainer{
-		notifications: map[string][]*domain.Notification{},
-		mutex:         &sync.Mutex{},
+		shards: shards,
 	}
 }
 
+// GetNotifications returns a copy of the notifications of all the users.
 func (ic *InMemoryNotificationsContainer) GetNotifications() (map[string][]*domain.Notification, error) {
-	return ic.notifications, nil
+	notifications := map[string][]*domain.Notification{}
+	for _, shard := range ic.shards {
+		shard.mutex.Lock()
+		for userID, userNotifications := range shard.notifications {
+			notifications[userID] = copyNotifications(userNotifications)
+		}
+		shard.mutex.Unlock()
+	}
+	return notifications, nil
 }
 
 func (ic *InMemoryNotificationsContainer) GetNotificationsByUser(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+	shard := ic.getShard(params.UserID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
 	notificationsToReturn := []*domain.Notification{}
 	startTime := time.Now().Add(-params.TimeInterval)
-	for _, notification := range ic.notifications[params.UserID] {
+	for _, notification := range shard.notifications[params.UserID] {
 		if notification.Timestamp.After(startTime) && notification.Type == params.NotificationType {
-			notificationsToReturn = append(notificationsToReturn, notification)
+			notificationCopy := *notification
+			notificationsToReturn = append(notificationsToReturn, &notificationCopy)
 		}
 	}
 	return notificationsToReturn, nil
 }
 
-func (ic *InMemoryNotificationsContainer) AddNotification(userID, notificationType string) error {
-	ic.notifications[userID] = append(ic.notifications[userID], &domain.Notification{
-		Timestamp: time.Now(),
-		UserID:    userID,
-		Type:      strings.ToLower(notificationType),
-	})
-	return nil
+func (ic *InMemoryNotificationsContainer) AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+	shard := ic.getShard(params.UserID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	result, nextStates, err := checkRules(params,
+		func(rule *domain.RateLimitRule, since time.Time) (int, error) {
+			return shard.countNotifications(params.UserID, historyType(params, rule), since), nil
+		},
+		func(stateKey string) (*domain.RuleState, error) {
+			return shard.ruleState(stateKey), nil
+		},
+	)
+	if err != nil || result.RejectedBy != nil {
+		return result, err
+	}
+
+	for stateKey, nextState := range nextStates {
+		shard.ruleStates[stateKey] = nextState
+	}
+	for notificationType := range historyRetentions(params) {
+		shard.notifications[params.UserID] = append(shard.notifications[params.UserID], &domain.Notification{
+			Timestamp: params.Timestamp,
+			UserID:    params.UserID,
+			Type:      notificationType,
+		})
+	}
+	return result, nil
+}
+
+func (ic *InMemoryNotificationsContainer) GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error) {
+	shard := ic.getShard(params.UserID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	state := shard.ruleState(ruleStateKey(params))
+	if state == nil {
+		return nil, nil
+	}
+	stateCopy := *state
+	return &stateCopy, nil
+}
+
+func (s *shard) ruleState(stateKey string) *domain.RuleState {
+	stored, ok := s.ruleStates[stateKey]
+	if !ok {
+		return nil
+	}
+	return stored.State
+}
+
+func (ic *InMemoryNotificationsContainer) getShard(userID string) *shard {
+	hash := fnv.New32a()
+	hash.Write([]byte(userID))
+	return ic.shards[hash.Sum32()%uint32(len(ic.shards))]
+}
+
+func (s *shard) countNotifications(userID, notificationType string, since time.Time) int {
+	count := 0
+	for _, notification := range s.notifications[userID] {
+		if notification.Timestamp.After(since) && notification.Type == notificationType {
+			count++
+		}
+	}
+	return count
+}
+
+func copyNotifications(notifications []*domain.Notification) []*domain.Notification {
+	notificationsCopy := make([]*domain.Notification, len(notifications))
+	for i, notification := range notifications {
+		notificationCopy := *notification
+		notificationsCopy[i] = &notificationCopy
+	}
+	return notificationsCopy
 }
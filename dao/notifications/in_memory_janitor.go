@@ -0,0 +1,135 @@
+package notifications
+
+import (
+	"fmt"
+	"rate-limiter/domain"
+	"sync"
+	"time"
+)
+
+// JanitorStats counts the sweeps of the janitor and the expired notifications and rule states they
+// evicted.
+type JanitorStats struct {
+	Sweeps       int64     `json:"sweeps"`
+	LastSweep    time.Time `json:"lastSweep"`
+	LastEvicted  int64     `json:"lastEvicted"`
+	TotalEvicted int64     `json:"totalEvicted"`
+}
+
+type janitor struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	stats    JanitorStats
+	mutex    sync.Mutex
+}
+
+// StartJanitor removes every interval the notifications that are older than the longest rule of
+// their type, and the rule states that expired, so they don't pile up in memory.
+func (ic *InMemoryNotificationsContainer) StartJanitor(interval time.Duration, getRules func() (map[string][]*domain.RateLimitRule, error)) {
+	ic.janitor = &janitor{stop: make(chan struct{})}
+	go func(janitor *janitor) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-janitor.stop:
+				return
+			case now := <-ticker.C:
+				rules, err := getRules()
+				if err != nil {
+					fmt.Println("Janitor - error getting rules:", err)
+					continue
+				}
+				evicted := ic.evictExpired(rules, now)
+				janitor.record(evicted, now)
+				if evicted > 0 {
+					fmt.Printf("Janitor - evicted %d expired entries\n", evicted)
+				}
+			}
+		}
+	}(ic.janitor)
+}
+
+// StopJanitor stops the janitor started by StartJanitor.
+func (ic *InMemoryNotificationsContainer) StopJanitor() {
+	if ic.janitor == nil {
+		return
+	}
+	ic.janitor.stopOnce.Do(func() {
+		close(ic.janitor.stop)
+	})
+}
+
+// JanitorStats returns the stats of the janitor started by StartJanitor, empty if it wasn't started.
+func (ic *InMemoryNotificationsContainer) JanitorStats() JanitorStats {
+	if ic.janitor == nil {
+		return JanitorStats{}
+	}
+	ic.janitor.mutex.Lock()
+	defer ic.janitor.mutex.Unlock()
+
+	return ic.janitor.stats
+}
+
+func (j *janitor) record(evicted int64, now time.Time) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.stats.Sweeps++
+	j.stats.LastSweep = now
+	j.stats.LastEvicted = evicted
+	j.stats.TotalEvicted += evicted
+}
+
+// evictExpired removes the expired notifications and rule states, and returns how many were removed.
+func (ic *InMemoryNotificationsContainer) evictExpired(rules map[string][]*domain.RateLimitRule, now time.Time) int64 {
+	retentions := map[string]time.Duration{}
+	for notificationType, typeRules := range rules {
+		for _, rule := range typeRules {
+			if rule.Retention() > retentions[notificationType] {
+				retentions[notificationType] = rule.Retention()
+			}
+		}
+	}
+	// The history of every type keeps the notifications of all the types.
+	for _, retention := range retentions {
+		retentions[domain.NotificationTypeAll] = max(retentions[domain.NotificationTypeAll], retention)
+	}
+
+	var evicted int64
+	for _, shard := range ic.shards {
+		evicted += shard.evictExpired(retentions, now)
+	}
+	return evicted
+}
+
+func (s *shard) evictExpired(retentions map[string]time.Duration, now time.Time) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var evicted int64
+	for userID, userNotifications := range s.notifications {
+		notificationsToKeep := []*domain.Notification{}
+		for _, notification := range userNotifications {
+			if notification.Timestamp.After(now.Add(-retentions[notification.Type])) {
+				notificationsToKeep = append(notificationsToKeep, notification)
+			}
+		}
+
+		evicted += int64(len(userNotifications) - len(notificationsToKeep))
+		if len(notificationsToKeep) == 0 {
+			delete(s.notifications, userID)
+		} else {
+			s.notifications[userID] = notificationsToKeep
+		}
+	}
+
+	// An expired state allows the same as a missing one.
+	for stateKey, stored := range s.ruleStates {
+		if stored.ExpiresAt.Before(now) {
+			delete(s.ruleStates, stateKey)
+			evicted++
+		}
+	}
+	return evicted
+}
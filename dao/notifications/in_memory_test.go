@@ -0,0 +1,113 @@
+package notifications
+
+import (
+	"rate-limiter/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryNotificationsContainer_EvictExpired(t *testing.T) {
+	rules := map[string][]*domain.RateLimitRule{
+		"status": {
+			{NotificationType: "status", MaxLimit: 100, TimeInterval: domain.Duration{Duration: time.Minute}},
+			{NotificationType: "status", MaxLimit: 100, TimeInterval: domain.Duration{Duration: time.Hour}},
+		},
+		"news": {
+			{NotificationType: "news", MaxLimit: 100, Window: domain.WindowDay},
+		},
+	}
+	now := time.Now()
+	container := NewInMemoryNotificationsContainer()
+	add := func(userID, notificationType string, age time.Duration) {
+		result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+			UserID:           userID,
+			NotificationType: notificationType,
+			Timestamp:        now.Add(-age),
+			Rules:            rules[notificationType],
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, result.RejectedBy)
+	}
+	add("user1", "status", 2*time.Hour)
+	add("user1", "status", 30*time.Minute)
+	add("user1", "news", 2*time.Hour)
+	add("user2", "status", 90*time.Minute)
+
+	evicted := container.evictExpired(rules, now)
+
+	// The history of every type keeps the notifications for the longest retention of the rules.
+	assert.Equal(t, int64(2), evicted)
+	notifications, err := container.GetNotifications()
+	assert.NoError(t, err)
+	assert.Len(t, notifications["user1"], 5)
+	assert.Len(t, notifications["user2"], 1)
+
+	// notifications of types without rules are no longer needed
+	delete(rules, "news")
+	assert.Equal(t, int64(4), container.evictExpired(rules, now))
+	notifications, err = container.GetNotifications()
+	assert.NoError(t, err)
+	assert.Len(t, notifications["user1"], 2)
+	assert.NotContains(t, notifications, "user2")
+}
+
+func TestInMemoryNotificationsContainer_Janitor(t *testing.T) {
+	rules := map[string][]*domain.RateLimitRule{
+		"status": {
+			{NotificationType: "status", MaxLimit: 100, TimeInterval: domain.Duration{Duration: time.Minute}},
+		},
+	}
+	container := NewInMemoryNotificationsContainer()
+	_, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+		UserID:           "user1",
+		NotificationType: "status",
+		Timestamp:        time.Now().Add(-time.Hour),
+		Rules:            rules["status"],
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, JanitorStats{}, container.JanitorStats())
+
+	container.StartJanitor(time.Millisecond, func() (map[string][]*domain.RateLimitRule, error) {
+		return rules, nil
+	})
+	assert.Eventually(t, func() bool {
+		return container.JanitorStats().Sweeps >= 2
+	}, time.Second, time.Millisecond)
+	container.StopJanitor()
+	container.StopJanitor()
+
+	notifications, err := container.GetNotifications()
+	assert.NoError(t, err)
+	assert.Empty(t, notifications)
+	// The notification is evicted once from its history and from the one of every type, and the
+	// later sweeps don't evict anything.
+	stats := container.JanitorStats()
+	assert.Equal(t, int64(2), stats.TotalEvicted)
+	assert.Zero(t, stats.LastEvicted)
+	assert.False(t, stats.LastSweep.IsZero())
+}
+
+func TestInMemoryNotificationsContainer_EvictExpiredRuleStates(t *testing.T) {
+	rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmGCRA}
+	now := time.Now()
+	container := NewInMemoryNotificationsContainer()
+	for userID, age := range map[string]time.Duration{"user1": time.Hour, "user2": time.Second} {
+		_, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+			UserID:           userID,
+			NotificationType: "status",
+			Timestamp:        now.Add(-age),
+			Rules:            []*domain.RateLimitRule{rule},
+		})
+		assert.NoError(t, err)
+	}
+
+	// The state of user1 expired with the retention of the rule, and the one of user2 is kept.
+	assert.Equal(t, int64(1), container.evictExpired(map[string][]*domain.RateLimitRule{"status": {rule}}, now))
+	for userID, expected := range map[string]bool{"user1": false, "user2": true} {
+		state, err := container.GetRuleState(domain.RuleStateParams{UserID: userID, NotificationType: "status", RuleKey: rule.Key()})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, state != nil, userID)
+	}
+}
@@ -0,0 +1,56 @@
+package notifications
+
+import (
+	"fmt"
+	"rate-limiter/domain"
+	"strings"
+	"time"
+)
+
+// The user ID is wrapped in braces so that all the keys of a user hash to the same Redis Cluster
+// slot, and can be used by the same script.
+
+func notificationsKey(userID, notificationType string) string {
+	return fmt.Sprintf("notifications:{%s}:%s", userID, strings.ToLower(notificationType))
+}
+
+func ruleStateKey(params domain.RuleStateParams) string {
+	return fmt.Sprintf("rule_state:{%s}:%s:%s", params.UserID, params.NotificationType, params.RuleKey)
+}
+
+func ruleStateParams(params domain.AddNotificationParams, rule *domain.RateLimitRule) domain.RuleStateParams {
+	return domain.RuleStateParams{
+		UserID:           params.UserID,
+		NotificationType: rule.NotificationType,
+		RuleKey:          rule.Key(),
+	}
+}
+
+// historyType is the notification type of the history the rule counts: the type of the notification,
+// or the type of the global and group rules, which have a history with the notifications of all
+// their types.
+func historyType(params domain.AddNotificationParams, rule *domain.RateLimitRule) string {
+	if rule.IsGlobal() || rule.IsGroup() {
+		return rule.NotificationType
+	}
+	return strings.ToLower(params.NotificationType)
+}
+
+// historyRetentions returns how long the notification must be kept in each history counted by the
+// rules. Stateful algorithms don't need the notifications history, so it is only kept when a rule
+// counts it. A stored notification is kept in the history of every type too, for as long as its
+// longest history, so the history of every type has all the stored notifications, like the
+// notifications collection of Mongo, even without global rules.
+func historyRetentions(params domain.AddNotificationParams) map[string]time.Duration {
+	retentions := map[string]time.Duration{}
+	for _, rule := range params.Rules {
+		if rule.UsesHistory() {
+			notificationType := historyType(params, rule)
+			retentions[notificationType] = max(retentions[notificationType], rule.Retention())
+		}
+	}
+	for _, retention := range retentions {
+		retentions[domain.NotificationTypeAll] = max(retentions[domain.NotificationTypeAll], retention)
+	}
+	return retentions
+}
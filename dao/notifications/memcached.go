@@ -0,0 +1,128 @@
+package notifications
+
+import (
+	"crypto/sha256"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// maxRelativeExpiration is the longest expiration memcached takes as seconds from now. Longer ones
+// are taken as a unix timestamp.
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// maxKeyLength is the longest key memcached takes.
+const maxKeyLength = 250
+
+// MemcachedContainer counts the notifications of every rule in fixed windows, with a memcached counter
+// per user, rule and window, which expires when the window ends. Every rule is enforced as a fixed
+// window of its calendar window or its timeInterval, whatever its algorithm, so up to twice maxLimit
+// notifications can be sent around the boundary of two windows.
+//
+// The counters are incremented before checking them, and decremented again when any rule rejects
+// the notification, so concurrent requests can't pass the limit, although one of them can be
+// rejected because of another request that is rejected too.
+type MemcachedContainer struct {
+	Client *memcache.Client
+}
+
+func NewMemcachedContainer(client *memcache.Client) *MemcachedContainer {
+	return &MemcachedContainer{
+		Client: client,
+	}
+}
+
+// GetNotificationsByUser is not supported, the counters don't keep the notifications history.
+func (mc *MemcachedContainer) GetNotificationsByUser(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+	return nil, errors.ErrNotSupported
+}
+
+func (mc *MemcachedContainer) AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+	result := &domain.AddNotificationResult{}
+	incremented := []string{}
+	for _, rule := range params.Rules {
+		if skipsRule(result, rule) {
+			continue
+		}
+		windowStart, windowEnd, err := rule.FixedWindow(params.Timestamp, params.Timezone)
+		if err != nil {
+			mc.rollback(incremented)
+			return nil, err
+		}
+
+		key := counterKey(ruleStateParams(params, rule), windowStart)
+		count, err := mc.increment(key, expiration(params.Timestamp, windowEnd))
+		if err != nil {
+			mc.rollback(incremented)
+			return nil, err
+		}
+		if count > uint64(rule.MaxLimit) && rule.IsShadow() {
+			mc.rollback([]string{key})
+			result.ShadowRejections = append(result.ShadowRejections, rule)
+			continue
+		}
+		incremented = append(incremented, key)
+		if count > uint64(rule.MaxLimit) {
+			result.RejectedBy = rule
+		}
+	}
+	if result.RejectedBy != nil {
+		mc.rollback(incremented)
+	}
+	return result, nil
+}
+
+// GetRuleState is not supported, the counters are not rule states.
+func (mc *MemcachedContainer) GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error) {
+	return nil, errors.ErrNotSupported
+}
+
+// increment adds one to the counter, creating it if it doesn't exist.
+func (mc *MemcachedContainer) increment(key string, expiration int32) (uint64, error) {
+	count, err := mc.Client.Increment(key, 1)
+	if !stderrors.Is(err, memcache.ErrCacheMiss) {
+		return count, err
+	}
+
+	err = mc.Client.Add(&memcache.Item{Key: key, Value: []byte("1"), Expiration: expiration})
+	if stderrors.Is(err, memcache.ErrNotStored) {
+		// Another request created the counter in between.
+		return mc.Client.Increment(key, 1)
+	}
+	return 1, err
+}
+
+func (mc *MemcachedContainer) rollback(keys []string) {
+	for _, key := range keys {
+		if _, err := mc.Client.Decrement(key, 1); err != nil && !stderrors.Is(err, memcache.ErrCacheMiss) {
+			fmt.Printf("Memcached - error decrementing %s: %s\n", key, err)
+		}
+	}
+}
+
+// counterKey escapes the user ID, the notification type and the rule, memcached keys can't have
+// spaces or control characters. The keys longer than memcached allows are hashed.
+func counterKey(params domain.RuleStateParams, windowStart time.Time) string {
+	key := fmt.Sprintf("counter:%s:%s:%s:%s", url.QueryEscape(params.UserID), url.QueryEscape(params.NotificationType),
+		url.QueryEscape(params.RuleKey), strconv.FormatInt(windowStart.Unix(), 10))
+	if len(key) > maxKeyLength {
+		return fmt.Sprintf("counter:%x", sha256.Sum256([]byte(key)))
+	}
+	return key
+}
+
+// expiration returns the memcached expiration of a counter of a window that ends at windowEnd.
+func expiration(now, windowEnd time.Time) int32 {
+	ttl := windowEnd.Sub(now)
+	if ttl > maxRelativeExpiration {
+		return int32(windowEnd.Unix())
+	}
+	// Rounded up, an expiration of 0 never expires.
+	return max(int32((ttl+time.Second-1)/time.Second), 1)
+}
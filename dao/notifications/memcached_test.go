@@ -0,0 +1,300 @@
+package notifications
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemcached implements the commands of the memcached text protocol used by MemcachedContainer.
+type fakeMemcached struct {
+	listener    net.Listener
+	mutex       sync.Mutex
+	values      map[string]uint64
+	expirations map[string]int32
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeMemcached{
+		listener:    listener,
+		values:      map[string]uint64{},
+		expirations: map[string]int32{},
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var reply string
+		switch fields[0] {
+		case "add":
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+			expiration, _ := strconv.Atoi(fields[3])
+			reply = f.add(fields[1], string(data[:size]), int32(expiration))
+		case "incr", "decr":
+			delta, _ := strconv.ParseUint(fields[2], 10, 64)
+			reply = f.incrDecr(fields[1], delta, fields[0] == "incr")
+		default:
+			reply = "ERROR"
+		}
+		fmt.Fprintf(conn, "%s\r\n", reply)
+	}
+}
+
+func (f *fakeMemcached) add(key, value string, expiration int32) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.values[key]; ok {
+		return "NOT_STORED"
+	}
+	f.values[key], _ = strconv.ParseUint(value, 10, 64)
+	f.expirations[key] = expiration
+	return "STORED"
+}
+
+func (f *fakeMemcached) incrDecr(key string, delta uint64, increment bool) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	value, ok := f.values[key]
+	if !ok {
+		return "NOT_FOUND"
+	}
+	if increment {
+		value += delta
+	} else if delta > value {
+		value = 0
+	} else {
+		value -= delta
+	}
+	f.values[key] = value
+	return strconv.FormatUint(value, 10)
+}
+
+func newTestMemcachedContainer(t *testing.T) (*MemcachedContainer, *fakeMemcached) {
+	server := newFakeMemcached(t)
+	return NewMemcachedContainer(memcache.New(server.listener.Addr().String())), server
+}
+
+func TestMemcachedContainer_AddNotificationIfAllowed(t *testing.T) {
+	start := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		timezone string
+		rules    []*domain.RateLimitRule
+		steps    []sendStep
+	}{
+		{
+			name: "fixed window",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: time.Second, expected: true},
+				{offset: 59 * time.Second, expected: false},
+				{offset: time.Minute, expected: true},
+			},
+		},
+		{
+			name:     "calendar day in the user timezone",
+			timezone: "America/Argentina/Buenos_Aires",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "news", MaxLimit: 1, Window: domain.WindowDay},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: 16 * time.Hour, expected: false},
+				// midnight in Buenos Aires is 03:00 UTC
+				{offset: 17 * time.Hour, expected: true},
+			},
+		},
+		{
+			name: "rejected notifications are not counted",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Hour}},
+				{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: time.Second, expected: false},
+				{offset: 2 * time.Second, expected: false},
+				{offset: time.Minute, expected: true},
+				{offset: 2 * time.Minute, expected: true},
+				{offset: 3 * time.Minute, expected: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			container, _ := newTestMemcachedContainer(t)
+			for i, step := range tc.steps {
+				result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+					UserID:           "user 1",
+					NotificationType: tc.rules[0].NotificationType,
+					Timezone:         tc.timezone,
+					Timestamp:        start.Add(step.offset),
+					Rules:            tc.rules,
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, step.expected, result.RejectedBy == nil, "step %d", i)
+			}
+		})
+	}
+}
+
+func TestMemcachedContainer_AddNotificationIfAllowed_LongUserID(t *testing.T) {
+	container, _ := newTestMemcachedContainer(t)
+	rules := []*domain.RateLimitRule{{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}}}
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+
+	// The keys of the users with IDs longer than the memcached keys are hashed, so each one has its
+	// own counter.
+	for _, userID := range []string{strings.Repeat("user 1\n", 50), strings.Repeat("user 2\n", 50)} {
+		for i, expected := range []bool{true, false} {
+			result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+				UserID:           userID,
+				NotificationType: "status",
+				Timestamp:        now,
+				Rules:            rules,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, expected, result.RejectedBy == nil, "step %d", i)
+		}
+	}
+	key := counterKey(domain.RuleStateParams{UserID: strings.Repeat("user 1\n", 50), NotificationType: "status", RuleKey: rules[0].Key()}, now)
+	assert.LessOrEqual(t, len(key), maxKeyLength)
+}
+
+func TestMemcachedContainer_AddNotificationIfAllowed_Concurrent(t *testing.T) {
+	container, _ := newTestMemcachedContainer(t)
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Hour}},
+	}
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+
+	var allowedCount int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+				UserID:           "user1",
+				NotificationType: "status",
+				Timestamp:        now,
+				Rules:            rules,
+			})
+			assert.NoError(t, err)
+			if result.RejectedBy == nil {
+				atomic.AddInt64(&allowedCount, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(5), allowedCount)
+}
+
+func TestMemcachedContainer_AddNotificationIfAllowed_ShadowRules(t *testing.T) {
+	container, _ := newTestMemcachedContainer(t)
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	enforced := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}
+	shadow := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, Mode: domain.ModeShadow}
+
+	expected := []*domain.AddNotificationResult{
+		{},
+		{ShadowRejections: []*domain.RateLimitRule{shadow}},
+		{RejectedBy: enforced, ShadowRejections: []*domain.RateLimitRule{shadow}},
+		{RejectedBy: enforced, ShadowRejections: []*domain.RateLimitRule{shadow}},
+	}
+	for i, expectedResult := range expected {
+		result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+			UserID:           "user1",
+			NotificationType: "status",
+			Timestamp:        now,
+			Rules:            []*domain.RateLimitRule{shadow, enforced},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, expectedResult, result, "notification %d", i)
+	}
+}
+
+func TestMemcachedContainer_Expiration(t *testing.T) {
+	container, server := newTestMemcachedContainer(t)
+	now := time.Date(2024, 5, 1, 10, 0, 30, 0, time.UTC)
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Minute}},
+		{NotificationType: "status", MaxLimit: 5, Window: domain.WindowMonth},
+	}
+
+	result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+		UserID:           "user1",
+		NotificationType: "status",
+		Timestamp:        now,
+		Rules:            rules,
+	})
+	require.NoError(t, err)
+	require.Nil(t, result.RejectedBy)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	minuteKey := counterKey(domain.RuleStateParams{UserID: "user1", NotificationType: "status", RuleKey: rules[0].Key()}, now.Truncate(time.Minute))
+	monthKey := counterKey(domain.RuleStateParams{UserID: "user1", NotificationType: "status", RuleKey: rules[1].Key()}, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, int32(30), server.expirations[minuteKey])
+	// Longer than 30 days, so it is the unix timestamp of the end of the month.
+	assert.Equal(t, int32(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).Unix()), server.expirations[monthKey])
+}
+
+func TestMemcachedContainer_NotSupported(t *testing.T) {
+	container, _ := newTestMemcachedContainer(t)
+
+	_, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user1", NotificationType: "status"})
+	assert.ErrorIs(t, err, errors.ErrNotSupported)
+
+	_, err = container.GetRuleState(domain.RuleStateParams{UserID: "user1", NotificationType: "status"})
+	assert.ErrorIs(t, err, errors.ErrNotSupported)
+}
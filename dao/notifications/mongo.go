@@ -0,0 +1,252 @@
+package notifications
+
+import (
+	"context"
+	stderrors "errors"
+	"rate-limiter/domain"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	notificationsCollection = "notifications"
+	ruleStatesCollection    = "rule_states"
+	// guardsCollection has a document per user and notification type, which every check updates so
+	// that concurrent checks of the same user and type conflict, and one of them is retried.
+	guardsCollection = "notification_guards"
+)
+
+// MongoContainer stores every notification sent as a document, which is kept for the longest rule of
+// its type or the history retention, whichever is longer, so the notifications can also be queried
+// as an audit history. Without a history retention, only the notifications counted by a rule are
+// stored. The documents are removed by TTL indexes.
+//
+// The rules are checked in a transaction, so it needs a replica set or a sharded cluster.
+type MongoContainer struct {
+	Client           *mongo.Client
+	database         *mongo.Database
+	historyRetention time.Duration
+}
+
+// notificationDocument has the groups of the rules that counted it in Groups, so the group rules
+// count the notifications of all their types.
+type notificationDocument struct {
+	UserID    string    `bson:"userId"`
+	Type      string    `bson:"type"`
+	Groups    []string  `bson:"groups,omitempty"`
+	Timestamp time.Time `bson:"timestamp"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type ruleStateDocument struct {
+	ID        string           `bson:"_id"`
+	State     domain.RuleState `bson:"state"`
+	ExpiresAt time.Time        `bson:"expiresAt"`
+}
+
+// NewMongoContainer returns a container that stores the notifications in the database, and creates
+// its indexes if they don't exist.
+func NewMongoContainer(ctx context.Context, client *mongo.Client, database string, historyRetention time.Duration) (*MongoContainer, error) {
+	mc := &MongoContainer{
+		Client:           client,
+		database:         client.Database(database),
+		historyRetention: historyRetention,
+	}
+	if err := mc.createIndexes(ctx); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+func (mc *MongoContainer) createIndexes(ctx context.Context) error {
+	expiresAtIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err := mc.database.Collection(notificationsCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "type", Value: 1}, {Key: "timestamp", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "timestamp", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "groups", Value: 1}, {Key: "timestamp", Value: 1}}},
+		expiresAtIndex,
+	})
+	if err != nil {
+		return err
+	}
+	for _, collection := range []string{ruleStatesCollection, guardsCollection} {
+		if _, err := mc.database.Collection(collection).Indexes().CreateOne(ctx, expiresAtIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mc *MongoContainer) GetNotificationsByUser(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+	ctx := context.Background()
+	cursor, err := mc.database.Collection(notificationsCollection).Find(ctx,
+		notificationsFilter(params.UserID, params.NotificationType, time.Now().Add(-params.TimeInterval)),
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []notificationDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	notificationsToReturn := []*domain.Notification{}
+	for _, document := range documents {
+		notificationsToReturn = append(notificationsToReturn, &domain.Notification{
+			Timestamp: document.Timestamp,
+			UserID:    document.UserID,
+			Type:      document.Type,
+		})
+	}
+	return notificationsToReturn, nil
+}
+
+func (mc *MongoContainer) AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+	ctx := context.Background()
+	session, err := mc.Client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	// WithTransaction retries the transaction when it conflicts with a concurrent one.
+	result, err := session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return mc.addNotificationIfAllowed(sessionCtx, params)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*domain.AddNotificationResult), nil
+}
+
+func (mc *MongoContainer) addNotificationIfAllowed(ctx mongo.SessionContext, params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+	retention := notificationRetention(params.Rules, mc.historyRetention)
+	guards, groups := notificationGuards(params)
+	for _, guard := range guards {
+		_, err := mc.database.Collection(guardsCollection).UpdateByID(ctx,
+			notificationsKey(params.UserID, guard),
+			bson.M{"$inc": bson.M{"version": 1}, "$set": bson.M{"expiresAt": params.Timestamp.Add(retention)}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, nextStates, err := checkRules(params,
+		func(rule *domain.RateLimitRule, since time.Time) (int, error) {
+			count, err := mc.database.Collection(notificationsCollection).CountDocuments(ctx,
+				notificationsFilter(params.UserID, historyType(params, rule), since))
+			return int(count), err
+		},
+		func(stateKey string) (*domain.RuleState, error) {
+			return mc.getRuleState(ctx, stateKey)
+		},
+	)
+	if err != nil || result.RejectedBy != nil {
+		return result, err
+	}
+
+	for stateKey, nextState := range nextStates {
+		_, err := mc.database.Collection(ruleStatesCollection).ReplaceOne(ctx, bson.M{"_id": stateKey}, &ruleStateDocument{
+			ID:        stateKey,
+			State:     *nextState.State,
+			ExpiresAt: nextState.ExpiresAt,
+		}, options.Replace().SetUpsert(true))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !storesNotification(params.Rules, mc.historyRetention) {
+		return result, nil
+	}
+	_, err = mc.database.Collection(notificationsCollection).InsertOne(ctx, notificationDocument{
+		UserID:    params.UserID,
+		Type:      strings.ToLower(params.NotificationType),
+		Groups:    groups,
+		Timestamp: params.Timestamp,
+		ExpiresAt: params.Timestamp.Add(retention),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// notificationRetention is how long the notification is kept: for the longest of its rules, or the
+// history retention if it is longer.
+func notificationRetention(rules []*domain.RateLimitRule, historyRetention time.Duration) time.Duration {
+	retention := historyRetention
+	for _, rule := range rules {
+		retention = max(retention, rule.Retention())
+	}
+	return retention
+}
+
+// storesNotification tells if the notification document is needed, either for the history or
+// because a rule counts it. The stateful rules only need their state, so without a history
+// retention the notifications of the types that only have stateful rules aren't stored.
+func storesNotification(rules []*domain.RateLimitRule, historyRetention time.Duration) bool {
+	return historyRetention > 0 || slices.ContainsFunc(rules, (*domain.RateLimitRule).UsesHistory)
+}
+
+// notificationGuards returns the guards the check updates, and the groups of the rules that count
+// the notification. The global and group rules count the notifications of several types, so they
+// conflict with the checks of any of them.
+func notificationGuards(params domain.AddNotificationParams) ([]string, []string) {
+	guards := []string{strings.ToLower(params.NotificationType)}
+	groups := []string{}
+	for _, rule := range params.Rules {
+		if historyType := historyType(params, rule); !slices.Contains(guards, historyType) {
+			guards = append(guards, historyType)
+			if rule.IsGroup() {
+				groups = append(groups, historyType)
+			}
+		}
+	}
+	return guards, groups
+}
+
+func (mc *MongoContainer) GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error) {
+	return mc.getRuleState(context.Background(), ruleStateKey(params))
+}
+
+func (mc *MongoContainer) getRuleState(ctx context.Context, stateKey string) (*domain.RuleState, error) {
+	var document ruleStateDocument
+	err := mc.database.Collection(ruleStatesCollection).FindOne(ctx, bson.M{"_id": stateKey}).Decode(&document)
+	if stderrors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &document.State, nil
+}
+
+// notificationsFilter uses the (userId, type, timestamp) index, the (userId, groups, timestamp) one
+// for the notifications of a group, or the (userId, timestamp) one for the notifications of every
+// type.
+func notificationsFilter(userID, notificationType string, since time.Time) bson.M {
+	filter := bson.M{
+		"userId":    userID,
+		"timestamp": bson.M{"$gt": since},
+	}
+	notificationType = strings.ToLower(notificationType)
+	switch {
+	case notificationType == domain.NotificationTypeAll:
+	case domain.IsGroupNotificationType(notificationType):
+		filter["groups"] = notificationType
+	default:
+		filter["type"] = notificationType
+	}
+	return filter
+}
@@ -0,0 +1,247 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"rate-limiter/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTestURIEnv is the URI of the MongoDB replica set the Mongo tests run against. They are
+// skipped when it is not set.
+const mongoTestURIEnv = "MONGO_TEST_URI"
+
+func newTestMongoContainer(t *testing.T) *MongoContainer {
+	uri := os.Getenv(mongoTestURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", mongoTestURIEnv)
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	database := fmt.Sprintf("rate_limiter_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		client.Database(database).Drop(ctx)
+		client.Disconnect(ctx)
+	})
+
+	container, err := NewMongoContainer(ctx, client, database, 24*time.Hour)
+	require.NoError(t, err)
+	return container
+}
+
+func TestMongoContainer_History(t *testing.T) {
+	container := newTestMongoContainer(t)
+	now := time.Now().Truncate(time.Millisecond)
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+			UserID:           "user1",
+			NotificationType: "Status",
+			Timestamp:        now,
+			Rules:            rules,
+		})
+		require.NoError(t, err)
+	}
+
+	// The notifications are kept for the history retention even if no rule counts them.
+	notifications, err := container.GetNotificationsByUser(domain.GetNotificationParams{
+		UserID:           "user1",
+		NotificationType: "status",
+		TimeInterval:     time.Hour,
+	})
+	assert.NoError(t, err)
+	require.Len(t, notifications, 2)
+	assert.True(t, now.Equal(notifications[0].Timestamp))
+
+	var document notificationDocument
+	err = container.database.Collection(notificationsCollection).FindOne(context.Background(), bson.M{"userId": "user1"}).Decode(&document)
+	assert.NoError(t, err)
+	assert.True(t, now.Add(24*time.Hour).Equal(document.ExpiresAt))
+}
+
+func TestMongoContainer_StatefulRulesWithoutHistoryRetention(t *testing.T) {
+	container := newTestMongoContainer(t)
+	container.historyRetention = 0
+	now := time.Now().Truncate(time.Millisecond)
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket},
+	}
+
+	for i, expected := range []bool{true, true, false} {
+		result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+			UserID:           "user1",
+			NotificationType: "status",
+			Timestamp:        now,
+			Rules:            rules,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, expected, result.RejectedBy == nil, "step %d", i)
+	}
+
+	// The token bucket only needs its state, so the notifications aren't stored.
+	count, err := container.database.Collection(notificationsCollection).CountDocuments(context.Background(), bson.M{})
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestMongoContainer_Indexes(t *testing.T) {
+	container := newTestMongoContainer(t)
+
+	cursor, err := container.database.Collection(notificationsCollection).Indexes().List(context.Background())
+	require.NoError(t, err)
+	var indexes []bson.M
+	require.NoError(t, cursor.All(context.Background(), &indexes))
+
+	names := []string{}
+	for _, index := range indexes {
+		names = append(names, index["name"].(string))
+	}
+	assert.Contains(t, names, "userId_1_type_1_timestamp_1")
+	assert.Contains(t, names, "expiresAt_1")
+}
+
+func TestNotificationsFilter(t *testing.T) {
+	since := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name             string
+		notificationType string
+		expectedFilter   bson.M
+	}{
+		{
+			name:             "notification type",
+			notificationType: "Status",
+			expectedFilter:   bson.M{"userId": "user1", "type": "status", "timestamp": bson.M{"$gt": since}},
+		},
+		{
+			name:             "group",
+			notificationType: "group:Marketing",
+			expectedFilter:   bson.M{"userId": "user1", "groups": "group:marketing", "timestamp": bson.M{"$gt": since}},
+		},
+		{
+			name:             "every type",
+			notificationType: domain.NotificationTypeAll,
+			expectedFilter:   bson.M{"userId": "user1", "timestamp": bson.M{"$gt": since}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedFilter, notificationsFilter("user1", tc.notificationType, since))
+		})
+	}
+}
+
+func TestNotificationGuards(t *testing.T) {
+	testCases := []struct {
+		name           string
+		rules          []*domain.RateLimitRule
+		expectedGuards []string
+		expectedGroups []string
+	}{
+		{
+			name:           "no rules",
+			expectedGuards: []string{"status"},
+			expectedGroups: []string{},
+		},
+		{
+			name: "rules of the type",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+				{NotificationType: "status", MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Hour}},
+			},
+			expectedGuards: []string{"status"},
+			expectedGroups: []string{},
+		},
+		{
+			name: "global and group rules",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+				{NotificationType: domain.NotificationTypeAll, MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Hour}},
+				{NotificationType: "group:updates", Group: "updates", NotificationTypes: []string{"status"}, MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Hour}},
+				{NotificationType: "group:updates", Group: "updates", NotificationTypes: []string{"status"}, MaxLimit: 20, TimeInterval: domain.Duration{Duration: 24 * time.Hour}},
+			},
+			expectedGuards: []string{"status", domain.NotificationTypeAll, "group:updates"},
+			expectedGroups: []string{"group:updates"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			guards, groups := notificationGuards(domain.AddNotificationParams{
+				UserID:           "user1",
+				NotificationType: "Status",
+				Rules:            tc.rules,
+			})
+
+			assert.Equal(t, tc.expectedGuards, guards)
+			assert.Equal(t, tc.expectedGroups, groups)
+		})
+	}
+}
+
+func TestNotificationRetention(t *testing.T) {
+	fixedWindow := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Hour}}
+	slidingWindow := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmSlidingWindow}
+	tokenBucket := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket}
+	testCases := []struct {
+		name              string
+		rules             []*domain.RateLimitRule
+		historyRetention  time.Duration
+		expectedRetention time.Duration
+		expectedStored    bool
+	}{
+		{
+			name:              "history retention longer than the rules",
+			rules:             []*domain.RateLimitRule{fixedWindow},
+			historyRetention:  24 * time.Hour,
+			expectedRetention: 24 * time.Hour,
+			expectedStored:    true,
+		},
+		{
+			name:              "rule longer than the history retention",
+			rules:             []*domain.RateLimitRule{fixedWindow, slidingWindow},
+			historyRetention:  time.Hour,
+			expectedRetention: 2 * time.Hour,
+			expectedStored:    true,
+		},
+		{
+			name:              "no history retention",
+			rules:             []*domain.RateLimitRule{fixedWindow},
+			expectedRetention: time.Hour,
+			expectedStored:    true,
+		},
+		{
+			name:              "only stateful rules with history retention",
+			rules:             []*domain.RateLimitRule{tokenBucket},
+			historyRetention:  24 * time.Hour,
+			expectedRetention: 24 * time.Hour,
+			expectedStored:    true,
+		},
+		{
+			name:              "only stateful rules without history retention",
+			rules:             []*domain.RateLimitRule{tokenBucket},
+			expectedRetention: tokenBucket.Retention(),
+			expectedStored:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedRetention, notificationRetention(tc.rules, tc.historyRetention))
+			assert.Equal(t, tc.expectedStored, storesNotification(tc.rules, tc.historyRetention))
+		})
+	}
+}
@@ -0,0 +1,542 @@
+package notifications
+
+import (
+	"os"
+	"path/filepath"
+	"rate-limiter/domain"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notificationsContainer interface {
+	AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error)
+	GetNotificationsByUser(params domain.GetNotificationParams) ([]*domain.Notification, error)
+	GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error)
+}
+
+func newTestRedisContainer(t *testing.T) *RedisContainer {
+	server := miniredis.RunT(t)
+	return &RedisContainer{
+		Client: redis.NewClient(&redis.Options{Addr: server.Addr()}),
+	}
+}
+
+func newTestFileContainer(t *testing.T, filePath string) *FileContainer {
+	container, err := NewFileContainer(filePath)
+	assert.NoError(t, err)
+	t.Cleanup(func() { container.Close() })
+	return container
+}
+
+func testContainers(t *testing.T) map[string]notificationsContainer {
+	containers := map[string]notificationsContainer{
+		"memory": NewInMemoryNotificationsContainer(),
+		"redis":  newTestRedisContainer(t),
+		"file":   newTestFileContainer(t, filepath.Join(t.TempDir(), "notifications.db")),
+	}
+	if os.Getenv(mongoTestURIEnv) != "" {
+		containers["mongo"] = newTestMongoContainer(t)
+	}
+	return containers
+}
+
+type sendStep struct {
+	offset   time.Duration
+	expected bool
+}
+
+func TestNotificationsContainer_AddNotificationIfAllowed(t *testing.T) {
+	start := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		timezone string
+		rules    []*domain.RateLimitRule
+		steps    []sendStep
+	}{
+		{
+			name: "sliding log",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: time.Second, expected: true},
+				{offset: 2 * time.Second, expected: false},
+				{offset: 59 * time.Second, expected: false},
+				{offset: 61 * time.Second, expected: true},
+			},
+		},
+		{
+			name:     "calendar day in the user timezone",
+			timezone: "America/Argentina/Buenos_Aires",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "news", MaxLimit: 1, Window: domain.WindowDay, Timezone: "Asia/Tokyo"},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: 13 * time.Hour, expected: false},
+				// midnight in Buenos Aires is 03:00 UTC
+				{offset: 17*time.Hour + time.Second, expected: true},
+			},
+		},
+		{
+			name: "token bucket",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket, Burst: 3},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: 0, expected: true},
+				{offset: 0, expected: true},
+				{offset: 30 * time.Second, expected: false},
+				{offset: 60 * time.Second, expected: true},
+				{offset: 61 * time.Second, expected: false},
+				{offset: 5 * time.Minute, expected: true},
+				{offset: 5 * time.Minute, expected: true},
+				{offset: 5 * time.Minute, expected: true},
+				{offset: 5 * time.Minute, expected: false},
+			},
+		},
+		{
+			name: "gcra",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmGCRA},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: 0, expected: true},
+				{offset: 29 * time.Second, expected: false},
+				{offset: 30 * time.Second, expected: true},
+				{offset: 31 * time.Second, expected: false},
+			},
+		},
+		{
+			name: "sliding window",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "marketing", MaxLimit: 4, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmSlidingWindow},
+			},
+			steps: []sendStep{
+				{offset: 50 * time.Minute, expected: true},
+				{offset: 50 * time.Minute, expected: true},
+				{offset: 50 * time.Minute, expected: true},
+				{offset: 50 * time.Minute, expected: true},
+				{offset: 55 * time.Minute, expected: false},
+				// the previous window is weighted at 75%
+				{offset: 75 * time.Minute, expected: true},
+				{offset: 75 * time.Minute, expected: false},
+			},
+		},
+		{
+			name: "all rules must allow the notification",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "status", MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket},
+				{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}},
+			},
+			steps: []sendStep{
+				{offset: 0, expected: true},
+				{offset: time.Second, expected: false},
+				{offset: 2 * time.Second, expected: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		for containerName, container := range testContainers(t) {
+			t.Run(tc.name+"/"+containerName, func(t *testing.T) {
+				for i, step := range tc.steps {
+					result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+						UserID:           "user1",
+						NotificationType: tc.rules[0].NotificationType,
+						Timezone:         tc.timezone,
+						Timestamp:        start.Add(step.offset),
+						Rules:            tc.rules,
+					})
+					require.NoError(t, err)
+					assert.Equal(t, step.expected, result.RejectedBy == nil, "step %d", i)
+				}
+			})
+		}
+	}
+}
+
+func TestNotificationsContainer_AddNotificationIfAllowed_RejectedDoesNotUpdateState(t *testing.T) {
+	start := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmGCRA},
+		{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}},
+	}
+
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				_, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+					UserID:           "user1",
+					NotificationType: "status",
+					Timestamp:        start,
+					Rules:            rules,
+				})
+				assert.NoError(t, err)
+			}
+
+			state, err := container.GetRuleState(domain.RuleStateParams{
+				UserID:           "user1",
+				NotificationType: "status",
+				RuleKey:          rules[0].Key(),
+			})
+			assert.NoError(t, err)
+			assert.True(t, start.Add(6*time.Second).Equal(state.TAT))
+		})
+	}
+}
+
+func TestNotificationsContainer_AddNotificationIfAllowed_BlockingRule(t *testing.T) {
+	start := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket},
+		{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmGCRA},
+		{NotificationType: "status", MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Minute}},
+	}
+
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			var blockingRules []*domain.RateLimitRule
+			for i := 0; i < 2; i++ {
+				result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+					UserID:           "user1",
+					NotificationType: "status",
+					Timestamp:        start,
+					Rules:            rules,
+				})
+				assert.NoError(t, err)
+				blockingRules = append(blockingRules, result.RejectedBy)
+			}
+
+			assert.Nil(t, blockingRules[0])
+			assert.Same(t, rules[1], blockingRules[1])
+		})
+	}
+}
+
+// The shadow rules are checked, and their rejections returned, even when an enforced rule rejects
+// the notification.
+func TestNotificationsContainer_AddNotificationIfAllowed_ShadowRules(t *testing.T) {
+	start := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	enforced := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Minute}}
+	shadowBucket := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket, Mode: domain.ModeShadow}
+	shadowLog := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Mode: domain.ModeShadow}
+	rules := []*domain.RateLimitRule{enforced, shadowBucket, shadowLog}
+
+	expected := []*domain.AddNotificationResult{
+		{},
+		{ShadowRejections: []*domain.RateLimitRule{shadowBucket}},
+		{ShadowRejections: []*domain.RateLimitRule{shadowBucket, shadowLog}},
+		{RejectedBy: enforced, ShadowRejections: []*domain.RateLimitRule{shadowBucket, shadowLog}},
+	}
+
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i, expectedResult := range expected {
+				result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+					UserID:           "user1",
+					NotificationType: "status",
+					Timestamp:        start.Add(time.Duration(i) * time.Millisecond),
+					Rules:            rules,
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, expectedResult, result, "notification %d", i)
+			}
+		})
+	}
+}
+
+func TestNotificationsContainer_AddNotificationIfAllowed_GlobalRules(t *testing.T) {
+	now := time.Now()
+	globalRules := []*domain.RateLimitRule{
+		{NotificationType: domain.NotificationTypeAll, MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Hour}},
+		{NotificationType: domain.NotificationTypeAll, MaxLimit: 4, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmGCRA},
+	}
+	sends := []struct {
+		notificationType string
+		expected         bool
+	}{
+		{notificationType: "status", expected: true},
+		{notificationType: "news", expected: true},
+		{notificationType: "status", expected: true},
+		{notificationType: "marketing", expected: false},
+	}
+
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i, send := range sends {
+				rules := append([]*domain.RateLimitRule{
+					{NotificationType: send.notificationType, MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Hour}},
+				}, globalRules...)
+				result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+					UserID:           "user1",
+					NotificationType: send.notificationType,
+					Timestamp:        now.Add(time.Duration(i-len(sends)) * time.Second),
+					Rules:            rules,
+				})
+				require.NoError(t, err)
+				assert.Equal(t, send.expected, result.RejectedBy == nil, "notification %d", i)
+			}
+
+			all, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user1", NotificationType: domain.NotificationTypeAll, TimeInterval: time.Hour})
+			require.NoError(t, err)
+			assert.Len(t, all, 3)
+			status, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user1", NotificationType: "status", TimeInterval: time.Hour})
+			require.NoError(t, err)
+			assert.Len(t, status, 2)
+			// The state of the stateful global rules is shared by every type too.
+			state, err := container.GetRuleState(domain.RuleStateParams{UserID: "user1", NotificationType: domain.NotificationTypeAll, RuleKey: globalRules[1].Key()})
+			require.NoError(t, err)
+			assert.NotNil(t, state)
+		})
+	}
+}
+
+func TestNotificationsContainer_AddNotificationIfAllowed_GroupRules(t *testing.T) {
+	now := time.Now()
+	groupRule := &domain.RateLimitRule{
+		NotificationType:  domain.GroupNotificationType("promotional"),
+		Group:             "promotional",
+		NotificationTypes: []string{"marketing", "newsletters"},
+		MaxLimit:          2,
+		TimeInterval:      domain.Duration{Duration: time.Hour},
+	}
+	globalRule := &domain.RateLimitRule{NotificationType: domain.NotificationTypeAll, MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Hour}}
+	sends := []struct {
+		notificationType string
+		expected         bool
+	}{
+		{notificationType: "marketing", expected: true},
+		{notificationType: "newsletters", expected: true},
+		{notificationType: "marketing", expected: false},
+		{notificationType: "status", expected: true},
+	}
+
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i, send := range sends {
+				rules := []*domain.RateLimitRule{
+					{NotificationType: send.notificationType, MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Hour}},
+				}
+				if groupRule.AppliesTo(send.notificationType) {
+					rules = append(rules, groupRule)
+				}
+				result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+					UserID:           "user1",
+					NotificationType: send.notificationType,
+					Timestamp:        now.Add(time.Duration(i-len(sends)) * time.Second),
+					Rules:            append(rules, globalRule),
+				})
+				require.NoError(t, err)
+				assert.Equal(t, send.expected, result.RejectedBy == nil, "notification %d", i)
+			}
+
+			group, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user1", NotificationType: groupRule.NotificationType, TimeInterval: time.Hour})
+			require.NoError(t, err)
+			assert.Len(t, group, 2)
+			all, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user1", NotificationType: domain.NotificationTypeAll, TimeInterval: time.Hour})
+			require.NoError(t, err)
+			assert.Len(t, all, 3)
+			marketing, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user1", NotificationType: "marketing", TimeInterval: time.Hour})
+			require.NoError(t, err)
+			assert.Len(t, marketing, 1)
+		})
+	}
+}
+
+func TestNotificationsContainer_GetNotificationsByUser_AllTypes(t *testing.T) {
+	now := time.Now()
+	sends := []string{"status", "news", "status"}
+
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			for i, notificationType := range sends {
+				result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+					UserID:           "user1",
+					NotificationType: notificationType,
+					Timestamp:        now.Add(time.Duration(i-len(sends)) * time.Second),
+					Rules: []*domain.RateLimitRule{
+						{NotificationType: notificationType, MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Hour}},
+					},
+				})
+				require.NoError(t, err)
+				require.Nil(t, result.RejectedBy)
+			}
+
+			// The notifications of every type are returned without global rules too.
+			all, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user1", NotificationType: domain.NotificationTypeAll, TimeInterval: time.Hour})
+			require.NoError(t, err)
+			assert.Len(t, all, len(sends))
+			other, err := container.GetNotificationsByUser(domain.GetNotificationParams{UserID: "user2", NotificationType: domain.NotificationTypeAll, TimeInterval: time.Hour})
+			require.NoError(t, err)
+			assert.Empty(t, other)
+		})
+	}
+}
+
+func TestNotificationsContainer_AddNotificationIfAllowed_InvalidTimezone(t *testing.T) {
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			_, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+				UserID:           "user1",
+				NotificationType: "news",
+				Timezone:         "Invalid/Timezone",
+				Timestamp:        time.Now(),
+				Rules: []*domain.RateLimitRule{
+					{NotificationType: "news", MaxLimit: 1, Window: domain.WindowDay},
+				},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNotificationsContainer_AddNotificationIfAllowed_ConcurrentRequests(t *testing.T) {
+	rules := map[string][]*domain.RateLimitRule{
+		"status": {
+			{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Hour}},
+		},
+		"news": {
+			{NotificationType: "news", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmGCRA},
+		},
+		"marketing": {
+			{NotificationType: "marketing", MaxLimit: 4, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmTokenBucket},
+			{NotificationType: "marketing", MaxLimit: 2, Window: domain.WindowDay},
+		},
+		"promotions": {
+			{NotificationType: "promotions", MaxLimit: 6, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmSlidingWindow},
+		},
+	}
+	expectedSent := map[string]int64{"status": 5, "news": 3, "marketing": 2, "promotions": 6}
+	users := []string{"user1", "user2", "user3", "user4"}
+
+	for containerName, container := range testContainers(t) {
+		t.Run(containerName, func(t *testing.T) {
+			sent := map[string]*int64{}
+			for _, userID := range users {
+				for notificationType := range rules {
+					sent[userID+":"+notificationType] = new(int64)
+				}
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for _, userID := range users {
+						for notificationType, typeRules := range rules {
+							result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+								UserID:           userID,
+								NotificationType: notificationType,
+								Timestamp:        time.Now(),
+								Rules:            typeRules,
+							})
+							assert.NoError(t, err)
+							if err == nil && result.RejectedBy == nil {
+								atomic.AddInt64(sent[userID+":"+notificationType], 1)
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			for _, userID := range users {
+				for notificationType, expected := range expectedSent {
+					assert.Equal(t, expected, *sent[userID+":"+notificationType], "%s %s", userID, notificationType)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisContainer_AddNotificationIfAllowed_ConcurrentReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	replicas := []*RedisContainer{
+		{Client: redis.NewClient(&redis.Options{Addr: server.Addr()})},
+		{Client: redis.NewClient(&redis.Options{Addr: server.Addr()})},
+	}
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Minute}},
+		{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmTokenBucket},
+	}
+
+	var allowedCount int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(replica *RedisContainer) {
+			defer wg.Done()
+			result, err := replica.AddNotificationIfAllowed(domain.AddNotificationParams{
+				UserID:           "user1",
+				NotificationType: "status",
+				Timestamp:        time.Now(),
+				Rules:            rules,
+			})
+			require.NoError(t, err)
+			if result.RejectedBy == nil {
+				atomic.AddInt64(&allowedCount, 1)
+			}
+		}(replicas[i%len(replicas)])
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(5), allowedCount)
+	notifications, err := replicas[0].GetNotificationsByUser(domain.GetNotificationParams{
+		UserID:           "user1",
+		NotificationType: "status",
+		TimeInterval:     time.Minute,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 5)
+}
+
+func TestRedisContainer_AddNotificationIfAllowed_Expiration(t *testing.T) {
+	server := miniredis.RunT(t)
+	container := &RedisContainer{Client: redis.NewClient(&redis.Options{Addr: server.Addr()})}
+	rules := []*domain.RateLimitRule{
+		{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+		{NotificationType: "status", MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Hour}},
+		{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}, Algorithm: domain.AlgorithmGCRA},
+	}
+	notificationsKey := notificationsKey("user1", "status")
+	stateKey := ruleStateKey(domain.RuleStateParams{UserID: "user1", NotificationType: "status", RuleKey: rules[2].Key()})
+
+	now := time.Now()
+	for _, timestamp := range []time.Time{now.Add(-2 * time.Hour), now.Add(-30 * time.Minute), now} {
+		result, err := container.AddNotificationIfAllowed(domain.AddNotificationParams{
+			UserID:           "user1",
+			NotificationType: "status",
+			Timestamp:        timestamp,
+			Rules:            rules,
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, result.RejectedBy)
+	}
+
+	// the notifications older than the longest interval are removed
+	members, err := server.ZMembers(notificationsKey)
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+
+	assert.Equal(t, time.Hour, server.TTL(notificationsKey))
+	assert.Equal(t, time.Minute, server.TTL(stateKey))
+
+	server.FastForward(time.Minute)
+	assert.False(t, server.Exists(stateKey))
+	assert.True(t, server.Exists(notificationsKey))
+
+	server.FastForward(time.Hour)
+	assert.False(t, server.Exists(notificationsKey))
+}
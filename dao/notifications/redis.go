@@ -2,88 +2,143 @@ package notifications
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"math/rand"
 	"rate-limiter/domain"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisContainer struct {
-	Client *redis.Client
+	Client redis.UniversalClient
 }
 
-func NewRedisContainer() *RedisContainer {
-
-	ctx := context.Background()
-
-	// Credentials harcoded. It is just a sandbox
-	client := redis.NewClient(&redis.Options{
-		Addr:     "redis-15763.c84.us-east-1-2.ec2.redns.redis-cloud.com:15763",
-		Password: "sJyxJIhJBZJK0l9q0MxIbOEwl2CTEExa",
-		DB:       0,
-	})
-
-	pong, err := client.Ping(ctx).Result()
-	if err != nil {
-		fmt.Println("Error connecting to Redis:", err)
-	}
-	fmt.Println("Connected to Redis:", pong)
+func NewRedisContainer(client redis.UniversalClient) *RedisContainer {
 	return &RedisContainer{
 		Client: client,
 	}
 }
 
-func (rc *RedisContainer) GetNotifications() (map[string][]*domain.Notification, error) {
-	notificationsJSON, err := rc.Client.Get(context.Background(), "notifications").Result()
-	if err != nil {
-		return map[string][]*domain.Notification{}, nil
-	}
-
-	var notifications map[string][]*domain.Notification
-	if err := json.Unmarshal([]byte(notificationsJSON), &notifications); err != nil {
-		return nil, err
-	}
-	return notifications, nil
-}
-
 func (rc *RedisContainer) GetNotificationsByUser(params domain.GetNotificationParams) ([]*domain.Notification, error) {
-	notifications, err := rc.GetNotifications()
+	since := time.Now().Add(-params.TimeInterval)
+	results, err := rc.Client.ZRangeByScoreWithScores(context.Background(), notificationsKey(params.UserID, params.NotificationType), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(since.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
 	if err != nil {
 		return nil, err
 	}
 
 	notificationsToReturn := []*domain.Notification{}
-	timestamp := time.Now().Add(-params.TimeInterval)
-	for _, notification := range notifications[params.UserID] {
-		if notification.Timestamp.After(timestamp) && notification.Type == params.NotificationType {
-			notificationsToReturn = append(notificationsToReturn, notification)
-		}
+	for _, result := range results {
+		notificationsToReturn = append(notificationsToReturn, &domain.Notification{
+			Timestamp: time.UnixMilli(int64(result.Score)),
+			UserID:    params.UserID,
+			Type:      params.NotificationType,
+		})
 	}
 	return notificationsToReturn, nil
 }
 
-func (rc *RedisContainer) AddNotification(userID, notificationType string) error {
-	notifications, err := rc.GetNotifications()
-	if err != nil {
-		return err
+func (rc *RedisContainer) AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+	keys := []string{}
+	historyKeys := []string{}
+	historyPositions := map[string]int{}
+	args := []interface{}{
+		params.Timestamp.UnixMilli(),
+		fmt.Sprintf("%d-%d", params.Timestamp.UnixNano(), rand.Int63()),
+		len(params.Rules),
 	}
+	for _, rule := range params.Rules {
+		since, err := rule.Since(params.Timestamp, params.Timezone)
+		if err != nil {
+			return nil, err
+		}
 
-	notifications[userID] = append(notifications[userID], &domain.Notification{
-		Timestamp: time.Now(),
-		UserID:    userID,
-		Type:      notificationType,
-	})
+		interval := rule.TimeInterval.Duration
+		shadow := 0
+		if rule.IsShadow() {
+			shadow = 1
+		}
+		historyKey := notificationsKey(params.UserID, historyType(params, rule))
+		if _, ok := historyPositions[historyKey]; !ok {
+			historyKeys = append(historyKeys, historyKey)
+			historyPositions[historyKey] = len(historyKeys)
+		}
+		keys = append(keys, ruleStateKey(ruleStateParams(params, rule)))
+		args = append(args,
+			rule.Algorithm,
+			since.UnixMilli(),
+			rule.MaxLimit,
+			rule.Capacity(),
+			rule.TokensPerSecond()/1000,
+			rule.EmissionInterval().Milliseconds(),
+			rule.BurstTolerance().Milliseconds(),
+			params.Timestamp.Truncate(interval).UnixMilli(),
+			interval.Milliseconds(),
+			rule.Retention().Milliseconds(),
+			shadow,
+			historyPositions[historyKey],
+		)
+	}
+	allHistory := 0
+	if slices.ContainsFunc(params.Rules, (*domain.RateLimitRule).UsesHistory) {
+		allKey := notificationsKey(params.UserID, domain.NotificationTypeAll)
+		if _, ok := historyPositions[allKey]; !ok {
+			historyKeys = append(historyKeys, allKey)
+			historyPositions[allKey] = len(historyKeys)
+		}
+		allHistory = historyPositions[allKey]
+	}
+	args = append(args, allHistory)
+	keys = append(keys, historyKeys...)
 
-	notificationsJSON, err := json.Marshal(notifications)
+	positions, err := addNotificationIfAllowedScript.Run(context.Background(), rc.Client, keys, args...).Int64Slice()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	result := &domain.AddNotificationResult{}
+	if positions[0] != 0 {
+		result.RejectedBy = params.Rules[positions[0]-1]
+	}
+	for _, position := range positions[1:] {
+		result.ShadowRejections = append(result.ShadowRejections, params.Rules[position-1])
 	}
+	return result, nil
+}
 
-	err = rc.Client.Set(context.Background(), "notifications", notificationsJSON, 0).Err()
+func (rc *RedisContainer) GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error) {
+	fields, err := rc.Client.HGetAll(context.Background(), ruleStateKey(params)).Result()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	state := &domain.RuleState{}
+	for field, value := range fields {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case "tokens":
+			state.Tokens = number
+		case "last_refill":
+			state.LastRefill = time.UnixMilli(int64(number))
+		case "tat":
+			state.TAT = time.UnixMilli(int64(number))
+		case "window_start":
+			state.WindowStart = time.UnixMilli(int64(number))
+		case "current_count":
+			state.CurrentCount = int(number)
+		case "previous_count":
+			state.PreviousCount = int(number)
+		}
 	}
-	return nil
+	return state, nil
 }
@@ -0,0 +1,130 @@
+package notifications
+
+import "github.com/redis/go-redis/v9"
+
+// addNotificationIfAllowedScript mirrors domain.RateLimitRule Allows and Register. Times are in
+// milliseconds.
+//
+// KEYS[1..n]: state hash of each rule.
+// KEYS[n+1..]: notifications sorted sets of the user counted by the rules, scored by timestamp: the
+// one of the type, and the ones of the groups and of every type.
+// ARGV[1]: timestamp of the notification.
+// ARGV[2]: member of the notification in the sorted sets.
+// ARGV[3]: number of rules, n.
+// Then, for each rule: algorithm, since, max limit, capacity, tokens per millisecond, emission
+// interval, burst tolerance, window start, interval, retention, 1 if it is a shadow rule and the
+// position, from 1, of the notifications sorted set it counts.
+// Last ARGV: position, from 1, of the notifications sorted set of every type, or 0 when no rule
+// counts a history. It keeps every stored notification, like historyRetentions.
+//
+// It returns {rejected by, shadow rejections...}: the position, from 1, of the first enforced rule
+// that rejects the notification, or 0 when it is added, and the positions of the shadow rules that
+// would have rejected it, which are checked either way.
+//
+// The keys expire after the retention of their rules, and the notifications older than the longest
+// retention of the rules that count them are removed, so the keys of idle users disappear.
+var addNotificationIfAllowedScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local rulesCount = tonumber(ARGV[3])
+local argsCount = 12
+
+local function getState(key)
+	local fields = redis.call('HGETALL', key)
+	local state = {}
+	for i = 1, #fields, 2 do
+		state[fields[i]] = tonumber(fields[i + 1])
+	end
+	return state
+end
+
+local historyRetentions = {}
+local nextStates = {}
+local retentions = {}
+local shadowRejections = {}
+local rejectedBy = 0
+for i = 1, rulesCount do
+	local offset = 3 + (i - 1) * argsCount
+	local algorithm = ARGV[offset + 1]
+	local since = ARGV[offset + 2]
+	local maxLimit = tonumber(ARGV[offset + 3])
+	local capacity = tonumber(ARGV[offset + 4])
+	local rate = tonumber(ARGV[offset + 5])
+	local emissionInterval = tonumber(ARGV[offset + 6])
+	local burstTolerance = tonumber(ARGV[offset + 7])
+	local windowStart = tonumber(ARGV[offset + 8])
+	local interval = tonumber(ARGV[offset + 9])
+	local retention = math.max(1, tonumber(ARGV[offset + 10]))
+	local shadow = ARGV[offset + 11] == '1'
+	local historyKey = KEYS[rulesCount + tonumber(ARGV[offset + 12])]
+
+	local allowed = true
+	local nextState = nil
+	if algorithm == 'token_bucket' then
+		local state = getState(KEYS[i])
+		local tokens = capacity
+		local lastRefill = now
+		if state.last_refill then
+			tokens = state.tokens
+			lastRefill = math.max(state.last_refill, now)
+			tokens = math.min(capacity, tokens + (lastRefill - state.last_refill) * rate)
+		end
+		allowed = tokens >= 1
+		nextState = {'tokens', math.max(0, tokens - 1), 'last_refill', lastRefill}
+	elseif algorithm == 'gcra' then
+		local tat = getState(KEYS[i]).tat or now
+		allowed = rate > 0 and capacity >= 1 and tat <= now + burstTolerance
+		nextState = {'tat', math.max(tat, now) + emissionInterval}
+	elseif algorithm == 'sliding_window' then
+		local state = getState(KEYS[i])
+		local current, previous = 0, 0
+		if state.window_start == windowStart then
+			current, previous = state.current_count, state.previous_count
+		elseif state.window_start == windowStart - interval then
+			previous = state.current_count
+		end
+		local estimate = 0
+		if interval > 0 then
+			estimate = previous * (1 - (now - windowStart) / interval) + current
+		end
+		allowed = estimate < maxLimit
+		nextState = {'window_start', windowStart, 'current_count', current + 1, 'previous_count', previous}
+	else
+		historyRetentions[historyKey] = math.max(historyRetentions[historyKey] or 1, retention)
+		allowed = redis.call('ZCOUNT', historyKey, '(' .. since, '+inf') < maxLimit
+	end
+
+	if not allowed then
+		if shadow then
+			table.insert(shadowRejections, i)
+		elseif rejectedBy == 0 then
+			rejectedBy = i
+		end
+	elseif nextState then
+		nextStates[i] = nextState
+		retentions[i] = retention
+	end
+end
+if rejectedBy ~= 0 then
+	return {rejectedBy, unpack(shadowRejections)}
+end
+
+local allHistory = tonumber(ARGV[3 + rulesCount * argsCount + 1])
+if allHistory ~= 0 then
+	local allRetention = 1
+	for _, historyRetention in pairs(historyRetentions) do
+		allRetention = math.max(allRetention, historyRetention)
+	end
+	historyRetentions[KEYS[rulesCount + allHistory]] = allRetention
+end
+
+for i, nextState in pairs(nextStates) do
+	redis.call('HSET', KEYS[i], unpack(nextState))
+	redis.call('PEXPIRE', KEYS[i], retentions[i])
+end
+for historyKey, historyRetention in pairs(historyRetentions) do
+	redis.call('ZADD', historyKey, now, ARGV[2])
+	redis.call('ZREMRANGEBYSCORE', historyKey, '-inf', now - historyRetention)
+	redis.call('PEXPIRE', historyKey, historyRetention)
+end
+return {0, unpack(shadowRejections)}
+`)
@@ -0,0 +1,62 @@
+package notifications
+
+import (
+	"rate-limiter/domain"
+	"time"
+)
+
+// checkRules checks the rules of the notification with the notifications each rule counts since a
+// time, from countSince, and the state of each rule, from ruleState, which are read from the
+// storage. It returns the result and, when the notification is allowed, the states of the rules to
+// store by state key.
+func checkRules(
+	params domain.AddNotificationParams,
+	countSince func(rule *domain.RateLimitRule, since time.Time) (int, error),
+	ruleState func(stateKey string) (*domain.RuleState, error),
+) (*domain.AddNotificationResult, map[string]*storedRuleState, error) {
+	result := &domain.AddNotificationResult{}
+	nextStates := map[string]*storedRuleState{}
+	for _, rule := range params.Rules {
+		if skipsRule(result, rule) {
+			continue
+		}
+		sent := 0
+		if rule.UsesHistory() {
+			since, err := rule.Since(params.Timestamp, params.Timezone)
+			if err != nil {
+				return nil, nil, err
+			}
+			if sent, err = countSince(rule, since); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		stateKey := ruleStateKey(ruleStateParams(params, rule))
+		state, err := ruleState(stateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !rule.Allows(sent, state, params.Timestamp) {
+			if rule.IsShadow() {
+				result.ShadowRejections = append(result.ShadowRejections, rule)
+			} else {
+				result.RejectedBy = rule
+			}
+			continue
+		}
+		if nextState := rule.Register(state, params.Timestamp); nextState != nil {
+			nextStates[stateKey] = &storedRuleState{State: nextState, ExpiresAt: params.Timestamp.Add(rule.Retention())}
+		}
+	}
+	if result.RejectedBy != nil {
+		return result, nil, nil
+	}
+	return result, nextStates, nil
+}
+
+// skipsRule tells if the rule doesn't need to be checked: the enforced rules after the first one
+// that rejects the notification. The shadow rules are checked even then, so what they would have
+// rejected doesn't depend on the enforced rules.
+func skipsRule(result *domain.AddNotificationResult, rule *domain.RateLimitRule) bool {
+	return result.RejectedBy != nil && !rule.IsShadow()
+}
@@ -0,0 +1,80 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"rate-limiter/domain"
+	"rate-limiter/utils"
+	"sort"
+)
+
+func readRulesFile(filePath string) (map[string][]*domain.RateLimitRule, error) {
+	fileData, err := utils.LoadRulesFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return parseRules(fileData)
+}
+
+// parseRules returns the rules of the file by notification type, or an error if any of them is invalid.
+func parseRules(fileData []byte) (map[string][]*domain.RateLimitRule, error) {
+	var rules []*domain.RateLimitRule
+	if err := json.Unmarshal(fileData, &rules); err != nil {
+		return nil, fmt.Errorf("error unmarshaling rules.json file: %w", err)
+	}
+
+	ruleMap := make(map[string][]*domain.RateLimitRule)
+	for _, rule := range rules {
+		if rule == nil {
+			return nil, fmt.Errorf("error validating rules.json file: null rule")
+		}
+		rule.Normalize()
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("error validating rules.json file: %w", err)
+		}
+		ruleMap[rule.NotificationType] = append(ruleMap[rule.NotificationType], rule)
+	}
+	for _, typeRules := range ruleMap {
+		if err := domain.ValidateGroup(typeRules); err != nil {
+			return nil, fmt.Errorf("error validating rules.json file: %w", err)
+		}
+	}
+	return ruleMap, nil
+}
+
+// RulesDiff holds the notification types whose rules changed.
+type RulesDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Updated []string `json:"updated"`
+}
+
+func (d RulesDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+func (d RulesDiff) String() string {
+	return fmt.Sprintf("added: %v, removed: %v, updated: %v", d.Added, d.Removed, d.Updated)
+}
+
+func diffRules(oldRules, newRules map[string][]*domain.RateLimitRule) RulesDiff {
+	diff := RulesDiff{}
+	for notificationType, typeRules := range newRules {
+		oldTypeRules, ok := oldRules[notificationType]
+		if !ok {
+			diff.Added = append(diff.Added, notificationType)
+		} else if utils.SerializeObject(oldTypeRules) != utils.SerializeObject(typeRules) {
+			diff.Updated = append(diff.Updated, notificationType)
+		}
+	}
+	for notificationType := range oldRules {
+		if _, ok := newRules[notificationType]; !ok {
+			diff.Removed = append(diff.Removed, notificationType)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Updated)
+	return diff
+}
@@ -1,32 +1,51 @@
 package rules
 
 import (
-	"encoding/json"
 	"fmt"
 	"rate-limiter/domain"
+	"rate-limiter/errors"
 	"rate-limiter/utils"
-	"strings"
 	"sync"
 )
 
 type InMemoryRulesContainer struct {
 	rules map[string][]*domain.RateLimitRule
-	mutex *sync.Mutex
+	// userTiers and userQuietHours are not in the rules file, so they are kept when it is reloaded.
+	userTiers      map[string]string
+	userQuietHours map[string]*domain.UserQuietHours
+	// apiRules are the rules of the types changed through the rules endpoints, nil for the deleted
+	// ones, which replace the ones of the file when it is reloaded.
+	apiRules map[string][]*domain.RateLimitRule
+	mutex    *sync.Mutex
+	filePath string
 }
 
 func NewInMemoryRulesContainer() *InMemoryRulesContainer {
-	rules := setInitialRules()
+	return NewInMemoryRulesContainerFromFile(utils.RulesFilePath)
+}
+
+func NewInMemoryRulesContainerFromFile(filePath string) *InMemoryRulesContainer {
+	rules := setInitialRules(filePath)
 	return &InMemoryRulesContainer{
-		rules: rules,
-		mutex: &sync.Mutex{},
+		rules:          rules,
+		userTiers:      map[string]string{},
+		userQuietHours: map[string]*domain.UserQuietHours{},
+		apiRules:       map[string][]*domain.RateLimitRule{},
+		mutex:          &sync.Mutex{},
+		filePath:       filePath,
 	}
 }
 
+// GetRules returns a copy of the rules, which is safe to use while they are modified.
 func (ic *InMemoryRulesContainer) GetRules() (map[string][]*domain.RateLimitRule, error) {
 	ic.mutex.Lock()
 	defer ic.mutex.Unlock()
 
-	return ic.rules, nil
+	rules := make(map[string][]*domain.RateLimitRule, len(ic.rules))
+	for notificationType, typeRules := range ic.rules {
+		rules[notificationType] = typeRules
+	}
+	return rules, nil
 }
 
 func (ic *InMemoryRulesContainer) GetRuleByType(notificationType string) ([]*domain.RateLimitRule, error) {
@@ -36,24 +55,96 @@ func (ic *InMemoryRulesContainer) GetRuleByType(notificationType string) ([]*dom
 	return ic.rules[notificationType], nil
 }
 
-func setInitialRules() map[string][]*domain.RateLimitRule {
-	fileData := utils.LoadRulesFile()
-	var rules []*domain.RateLimitRule
-	if err := json.Unmarshal(fileData, &rules); err != nil {
-		fmt.Println("error unarshaling rules.json file", err)
+// The slices of rules are never modified in place, so the ones already returned are not affected.
+
+func (ic *InMemoryRulesContainer) AddRule(rule *domain.RateLimitRule) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	typeRules := ic.rules[rule.NotificationType]
+	ic.rules[rule.NotificationType] = append(typeRules[:len(typeRules):len(typeRules)], rule)
+	ic.apiRules[rule.NotificationType] = ic.rules[rule.NotificationType]
+	return nil
+}
+
+func (ic *InMemoryRulesContainer) UpdateRulesByType(notificationType string, rules []*domain.RateLimitRule) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	ic.rules[notificationType] = rules
+	ic.apiRules[notificationType] = rules
+	return nil
+}
+
+func (ic *InMemoryRulesContainer) DeleteRulesByType(notificationType string) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	if _, ok := ic.rules[notificationType]; !ok {
+		return errors.ErrRuleNotFound
+	}
+	delete(ic.rules, notificationType)
+	ic.apiRules[notificationType] = nil
+	return nil
+}
+
+func (ic *InMemoryRulesContainer) GetUserTier(userID string) (string, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	return ic.userTiers[userID], nil
+}
+
+func (ic *InMemoryRulesContainer) SetUserTier(userID, tier string) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	ic.userTiers[userID] = tier
+	return nil
+}
+
+func (ic *InMemoryRulesContainer) DeleteUserTier(userID string) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	if _, ok := ic.userTiers[userID]; !ok {
+		return errors.ErrUserTierNotFound
 	}
+	delete(ic.userTiers, userID)
+	return nil
+}
+
+func (ic *InMemoryRulesContainer) GetUserQuietHours(userID string) (*domain.UserQuietHours, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	return ic.userQuietHours[userID], nil
+}
+
+func (ic *InMemoryRulesContainer) SetUserQuietHours(userQuietHours *domain.UserQuietHours) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	ic.userQuietHours[userQuietHours.UserID] = userQuietHours
+	return nil
+}
+
+func (ic *InMemoryRulesContainer) DeleteUserQuietHours(userID string) error {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	if _, ok := ic.userQuietHours[userID]; !ok {
+		return errors.ErrUserQuietHoursNotFound
+	}
+	delete(ic.userQuietHours, userID)
+	return nil
+}
 
-	ruleMap := make(map[string][]*domain.RateLimitRule)
-	for _, rule := range rules {
-		rule.NotificationType = strings.ToLower(rule.NotificationType)
-		_, ok := ruleMap[strings.ToLower(rule.NotificationType)]
-		if !ok {
-			ruleMap[strings.ToLower(rule.NotificationType)] = []*domain.RateLimitRule{
-				rule,
-			}
-		} else {
-			ruleMap[strings.ToLower(rule.NotificationType)] = append(ruleMap[strings.ToLower(rule.NotificationType)], rule)
-		}
+func setInitialRules(filePath string) map[string][]*domain.RateLimitRule {
+	ruleMap, err := readRulesFile(filePath)
+	if err != nil {
+		fmt.Println(err)
+		return map[string][]*domain.RateLimitRule{}
 	}
 	return ruleMap
 }
@@ -0,0 +1,220 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	initialRules = `[
+  {"notificationType": "News", "maxLimit": 1, "timeInterval": "24h"},
+  {"notificationType": "Status", "maxLimit": 2, "timeInterval": "1m"}
+]`
+	updatedRules = `[
+  {"notificationType": "News", "maxLimit": 5, "timeInterval": "24h"},
+  {"notificationType": "Marketing", "maxLimit": 3, "timeInterval": "1h"}
+]`
+)
+
+func writeRulesFile(t *testing.T, filePath, content string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(filePath, []byte(content), 0o644))
+}
+
+func newTestContainer(t *testing.T) (*InMemoryRulesContainer, string) {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), "rules.json")
+	writeRulesFile(t, filePath, initialRules)
+	return NewInMemoryRulesContainerFromFile(filePath), filePath
+}
+
+func TestInMemoryRulesContainer_Reload(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		expectedDiff  RulesDiff
+		expectedLimit int
+		expectedError bool
+	}{
+		{
+			name:    "Reload replaces the rules",
+			content: updatedRules,
+			expectedDiff: RulesDiff{
+				Added:   []string{"marketing"},
+				Removed: []string{"status"},
+				Updated: []string{"news"},
+			},
+			expectedLimit: 5,
+		},
+		{
+			name:          "Unchanged file",
+			content:       initialRules,
+			expectedLimit: 1,
+		},
+		{
+			name:          "Malformed file keeps the current rules",
+			content:       `[{"notificationType": "News",`,
+			expectedLimit: 1,
+			expectedError: true,
+		},
+		{
+			name:          "Invalid rule keeps the current rules",
+			content:       `[{"notificationType": "News", "maxLimit": 0, "timeInterval": "24h"}]`,
+			expectedLimit: 1,
+			expectedError: true,
+		},
+		{
+			name: "Group rules are stored with the type of the group",
+			content: `[
+  {"notificationType": "News", "maxLimit": 1, "timeInterval": "24h"},
+  {"group": "Promotional", "notificationTypes": ["Marketing", "News"], "maxLimit": 3, "timeInterval": "24h"}
+]`,
+			expectedDiff: RulesDiff{
+				Added:   []string{"group:promotional"},
+				Removed: []string{"status"},
+			},
+			expectedLimit: 1,
+		},
+		{
+			name: "Group rules with different types keep the current rules",
+			content: `[
+  {"notificationType": "News", "maxLimit": 5, "timeInterval": "24h"},
+  {"group": "promotional", "notificationTypes": ["marketing", "news"], "maxLimit": 3, "timeInterval": "24h"},
+  {"group": "promotional", "notificationTypes": ["marketing"], "maxLimit": 10, "timeInterval": "168h"}
+]`,
+			expectedLimit: 1,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			container, filePath := newTestContainer(t)
+			writeRulesFile(t, filePath, tc.content)
+
+			diff, err := container.Reload()
+
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedDiff, diff)
+			}
+			rules, _ := container.GetRuleByType("news")
+			if assert.Len(t, rules, 1) {
+				assert.Equal(t, tc.expectedLimit, rules[0].MaxLimit)
+			}
+		})
+	}
+}
+
+func TestInMemoryRulesContainer_Reload_KeepsAPIChanges(t *testing.T) {
+	container, filePath := newTestContainer(t)
+	assert.NoError(t, container.UpdateRulesByType("news", []*domain.RateLimitRule{
+		{NotificationType: "news", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Hour}},
+	}))
+	assert.NoError(t, container.DeleteRulesByType("status"))
+	assert.NoError(t, container.AddRule(&domain.RateLimitRule{NotificationType: "alerts", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}}))
+
+	writeRulesFile(t, filePath, `[
+  {"notificationType": "News", "maxLimit": 5, "timeInterval": "24h"},
+  {"notificationType": "Status", "maxLimit": 4, "timeInterval": "1m"},
+  {"notificationType": "Marketing", "maxLimit": 3, "timeInterval": "1h"}
+]`)
+	diff, err := container.Reload()
+
+	// Only the types that were not changed through the API are taken from the file.
+	assert.NoError(t, err)
+	assert.Equal(t, RulesDiff{Added: []string{"marketing"}}, diff)
+	rules, _ := container.GetRules()
+	assert.Len(t, rules, 3)
+	if assert.Len(t, rules["news"], 1) {
+		assert.Equal(t, 2, rules["news"][0].MaxLimit)
+	}
+	assert.NotContains(t, rules, "status")
+	assert.Len(t, rules["alerts"], 1)
+	assert.Len(t, rules["marketing"], 1)
+}
+
+func TestInMemoryRulesContainer_Watch(t *testing.T) {
+	container, filePath := newTestContainer(t)
+	stop, err := container.Watch()
+	assert.NoError(t, err)
+	defer stop()
+
+	writeRulesFile(t, filePath, updatedRules)
+
+	assert.Eventually(t, func() bool {
+		rules, _ := container.GetRuleByType("marketing")
+		return len(rules) == 1
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestInMemoryRulesContainer_WatchSIGHUP(t *testing.T) {
+	container, filePath := newTestContainer(t)
+	// The file is changed before watching it, so only the signal reloads it.
+	writeRulesFile(t, filePath, updatedRules)
+	stop, err := container.Watch()
+	assert.NoError(t, err)
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	assert.Eventually(t, func() bool {
+		rules, _ := container.GetRuleByType("marketing")
+		return len(rules) == 1
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestInMemoryRulesContainer_UserTiers(t *testing.T) {
+	container, filePath := newTestContainer(t)
+	assert.NoError(t, container.SetUserTier("user1", "vip"))
+
+	writeRulesFile(t, filePath, `[
+  {"notificationType": "Status", "maxLimit": 2, "timeInterval": "1m"},
+  {"notificationType": "Status", "maxLimit": 5, "timeInterval": "1m", "tier": "VIP"}
+]`)
+	_, err := container.Reload()
+	assert.NoError(t, err)
+
+	// The tiers of the users are not in the file, so they survive the reload.
+	tier, err := container.GetUserTier("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, "vip", tier)
+	rules, _ := container.GetRuleByType("status")
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "vip", rules[1].Tier)
+	}
+
+	assert.NoError(t, container.DeleteUserTier("user1"))
+	tier, _ = container.GetUserTier("user1")
+	assert.Empty(t, tier)
+	assert.ErrorIs(t, container.DeleteUserTier("user1"), errors.ErrUserTierNotFound)
+}
+
+func TestInMemoryRulesContainer_UserQuietHours(t *testing.T) {
+	container, filePath := newTestContainer(t)
+	userQuietHours := &domain.UserQuietHours{UserID: "user1", Timezone: "Europe/Madrid", Override: &domain.QuietHours{Start: "21:00", End: "07:00"}}
+	assert.NoError(t, container.SetUserQuietHours(userQuietHours))
+
+	writeRulesFile(t, filePath, updatedRules)
+	_, err := container.Reload()
+	assert.NoError(t, err)
+
+	// The quiet hours of the users are not in the file, so they survive the reload.
+	stored, err := container.GetUserQuietHours("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, userQuietHours, stored)
+
+	assert.NoError(t, container.DeleteUserQuietHours("user1"))
+	stored, _ = container.GetUserQuietHours("user1")
+	assert.Nil(t, stored)
+	assert.ErrorIs(t, container.DeleteUserQuietHours("user1"), errors.ErrUserQuietHoursNotFound)
+}
@@ -0,0 +1,356 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// rulesKey is a hash with the json encoded rules of each notification type.
+	rulesKey = "rules"
+	// rulesSeededKey is set once the rules file has been copied to Redis, so the rules deleted
+	// through the API are not seeded again when a replica starts. Its hash tag is the name of
+	// rulesKey, so that both hash to the same Redis Cluster slot and can be used by the same script.
+	rulesSeededKey = "{rules}:seeded"
+	// rulesChannel receives the notification type of every change.
+	rulesChannel = "rules:changed"
+	// userTiersKey is a hash with the tier of each user. It is not cached, as there can be many users.
+	userTiersKey = "rules:user_tiers"
+	// userQuietHoursKey is a hash with the json encoded quiet hours settings of each user.
+	userQuietHoursKey = "rules:user_quiet_hours"
+
+	maxTxRetries = 10
+)
+
+// seedScript sets KEYS[2] and copies the rules of ARGV, as pairs of notification type and json
+// encoded rules, to the KEYS[1] hash, unless it was already set. The types already in the hash are
+// kept.
+var seedScript = redis.NewScript(`
+if redis.call('SETNX', KEYS[2], 1) == 0 then
+	return 0
+end
+for i = 1, #ARGV, 2 do
+	redis.call('HSETNX', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return 1
+`)
+
+// RedisRulesContainer stores the rules in Redis, so they are shared by all the replicas. Every
+// replica keeps a local copy of the rules, which is refreshed when any of them publishes a change.
+type RedisRulesContainer struct {
+	Client redis.UniversalClient
+	rules  map[string][]*domain.RateLimitRule
+	mutex  *sync.Mutex
+	// updateMutex orders the updates of the local copy, so a refresh that read the rules from Redis
+	// before a write of this replica can't overwrite it.
+	updateMutex *sync.Mutex
+	pubsub      *redis.PubSub
+}
+
+// NewRedisRulesContainer returns a container with the rules stored in Redis. The rules of filePath
+// are copied to Redis by the first replica that starts.
+func NewRedisRulesContainer(client redis.UniversalClient, filePath string) (*RedisRulesContainer, error) {
+	ctx := context.Background()
+	rc := &RedisRulesContainer{
+		Client:      client,
+		rules:       map[string][]*domain.RateLimitRule{},
+		mutex:       &sync.Mutex{},
+		updateMutex: &sync.Mutex{},
+	}
+	if err := rc.seed(ctx, filePath); err != nil {
+		return nil, err
+	}
+
+	// Subscribing before loading the rules ensures that no change is missed in between.
+	rc.pubsub = client.Subscribe(ctx, rulesChannel)
+	if _, err := rc.pubsub.Receive(ctx); err != nil {
+		rc.pubsub.Close()
+		return nil, err
+	}
+	if err := rc.loadAll(ctx); err != nil {
+		rc.pubsub.Close()
+		return nil, err
+	}
+	go rc.listen()
+	return rc, nil
+}
+
+// Close stops listening to the changes of the other replicas.
+func (rc *RedisRulesContainer) Close() error {
+	return rc.pubsub.Close()
+}
+
+// GetRules returns a copy of the rules, which is safe to use while they are modified.
+func (rc *RedisRulesContainer) GetRules() (map[string][]*domain.RateLimitRule, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rules := make(map[string][]*domain.RateLimitRule, len(rc.rules))
+	for notificationType, typeRules := range rc.rules {
+		rules[notificationType] = typeRules
+	}
+	return rules, nil
+}
+
+func (rc *RedisRulesContainer) GetRuleByType(notificationType string) ([]*domain.RateLimitRule, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	return rc.rules[notificationType], nil
+}
+
+// AddRule appends the rule in an optimistic transaction, which is retried if another replica
+// changes the rules at the same time.
+func (rc *RedisRulesContainer) AddRule(rule *domain.RateLimitRule) error {
+	ctx := context.Background()
+	var typeRules []*domain.RateLimitRule
+	addRule := func(tx *redis.Tx) error {
+		var err error
+		typeRules, err = getTypeRules(ctx, tx, rule.NotificationType)
+		if err != nil {
+			return err
+		}
+		typeRules = append(typeRules, rule)
+		data, err := json.Marshal(typeRules)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, rulesKey, rule.NotificationType, data)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxTxRetries; i++ {
+		err := rc.Client.Watch(ctx, addRule, rulesKey)
+		if stderrors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return rc.changed(ctx, rule.NotificationType, typeRules)
+	}
+	return fmt.Errorf("error adding rule: %w", redis.TxFailedErr)
+}
+
+func (rc *RedisRulesContainer) UpdateRulesByType(notificationType string, rules []*domain.RateLimitRule) error {
+	ctx := context.Background()
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	if err := rc.Client.HSet(ctx, rulesKey, notificationType, data).Err(); err != nil {
+		return err
+	}
+	return rc.changed(ctx, notificationType, rules)
+}
+
+func (rc *RedisRulesContainer) DeleteRulesByType(notificationType string) error {
+	ctx := context.Background()
+	deleted, err := rc.Client.HDel(ctx, rulesKey, notificationType).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.ErrRuleNotFound
+	}
+	return rc.changed(ctx, notificationType, nil)
+}
+
+func (rc *RedisRulesContainer) GetUserTier(userID string) (string, error) {
+	tier, err := rc.Client.HGet(context.Background(), userTiersKey, userID).Result()
+	if stderrors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return tier, err
+}
+
+func (rc *RedisRulesContainer) SetUserTier(userID, tier string) error {
+	return rc.Client.HSet(context.Background(), userTiersKey, userID, tier).Err()
+}
+
+func (rc *RedisRulesContainer) DeleteUserTier(userID string) error {
+	deleted, err := rc.Client.HDel(context.Background(), userTiersKey, userID).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.ErrUserTierNotFound
+	}
+	return nil
+}
+
+func (rc *RedisRulesContainer) GetUserQuietHours(userID string) (*domain.UserQuietHours, error) {
+	data, err := rc.Client.HGet(context.Background(), userQuietHoursKey, userID).Bytes()
+	if stderrors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var userQuietHours domain.UserQuietHours
+	if err := json.Unmarshal(data, &userQuietHours); err != nil {
+		return nil, err
+	}
+	return &userQuietHours, nil
+}
+
+func (rc *RedisRulesContainer) SetUserQuietHours(userQuietHours *domain.UserQuietHours) error {
+	data, err := json.Marshal(userQuietHours)
+	if err != nil {
+		return err
+	}
+	return rc.Client.HSet(context.Background(), userQuietHoursKey, userQuietHours.UserID, data).Err()
+}
+
+func (rc *RedisRulesContainer) DeleteUserQuietHours(userID string) error {
+	deleted, err := rc.Client.HDel(context.Background(), userQuietHoursKey, userID).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.ErrUserQuietHoursNotFound
+	}
+	return nil
+}
+
+// changed updates the local copy of the rules of the type and tells the other replicas to do it.
+func (rc *RedisRulesContainer) changed(ctx context.Context, notificationType string, rules []*domain.RateLimitRule) error {
+	rc.updateMutex.Lock()
+	rc.setTypeRules(notificationType, rules)
+	rc.updateMutex.Unlock()
+	return rc.Client.Publish(ctx, rulesChannel, notificationType).Err()
+}
+
+// seed copies the rules file to Redis if no replica did it before. The file is read before, so an
+// invalid file fails the start of the replica instead of seeding no rules, and the rules and the
+// flag are written by a single script, so a replica that stops can't leave the flag without them.
+func (rc *RedisRulesContainer) seed(ctx context.Context, filePath string) error {
+	seeded, err := rc.Client.Exists(ctx, rulesSeededKey).Result()
+	if err != nil || seeded > 0 {
+		return err
+	}
+
+	ruleMap, err := readRulesFile(filePath)
+	if err != nil {
+		return err
+	}
+	args := make([]interface{}, 0, 2*len(ruleMap))
+	for notificationType, typeRules := range ruleMap {
+		data, err := json.Marshal(typeRules)
+		if err != nil {
+			return err
+		}
+		args = append(args, notificationType, data)
+	}
+	return seedScript.Run(ctx, rc.Client, []string{rulesKey, rulesSeededKey}, args...).Err()
+}
+
+func (rc *RedisRulesContainer) loadAll(ctx context.Context) error {
+	rc.updateMutex.Lock()
+	defer rc.updateMutex.Unlock()
+
+	values, err := rc.Client.HGetAll(ctx, rulesKey).Result()
+	if err != nil {
+		return err
+	}
+
+	rules := make(map[string][]*domain.RateLimitRule, len(values))
+	for notificationType, value := range values {
+		typeRules, err := decodeTypeRules(notificationType, value)
+		if err != nil {
+			return err
+		}
+		rules[notificationType] = typeRules
+	}
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.rules = rules
+	return nil
+}
+
+// listen refreshes the rules of the types changed by any replica. The changes published while the
+// connection is down are lost, so all the rules are loaded again when it is restored.
+func (rc *RedisRulesContainer) listen() {
+	ctx := context.Background()
+	for message := range rc.pubsub.ChannelWithSubscriptions() {
+		var err error
+		switch message := message.(type) {
+		case *redis.Subscription:
+			err = rc.loadAll(ctx)
+		case *redis.Message:
+			err = rc.refresh(ctx, message.Payload)
+		}
+		if err != nil {
+			fmt.Println("Rules - error refreshing rules:", err)
+		}
+	}
+}
+
+func (rc *RedisRulesContainer) refresh(ctx context.Context, notificationType string) error {
+	rc.updateMutex.Lock()
+	defer rc.updateMutex.Unlock()
+
+	typeRules, err := getTypeRules(ctx, rc.Client, notificationType)
+	if err != nil {
+		return err
+	}
+	rc.setTypeRules(notificationType, typeRules)
+	return nil
+}
+
+func (rc *RedisRulesContainer) setTypeRules(notificationType string, rules []*domain.RateLimitRule) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if len(rules) == 0 {
+		delete(rc.rules, notificationType)
+		return
+	}
+	rc.rules[notificationType] = rules
+}
+
+func getTypeRules(ctx context.Context, client redis.Cmdable, notificationType string) ([]*domain.RateLimitRule, error) {
+	value, err := client.HGet(ctx, rulesKey, notificationType).Result()
+	if stderrors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTypeRules(notificationType, value)
+}
+
+// decodeTypeRules unmarshals the rules of the type stored in Redis and validates them like the
+// rules file, so a replica doesn't enforce rules written by hand or by an older version.
+func decodeTypeRules(notificationType, value string) ([]*domain.RateLimitRule, error) {
+	var typeRules []*domain.RateLimitRule
+	if err := json.Unmarshal([]byte(value), &typeRules); err != nil {
+		return nil, fmt.Errorf("error unmarshaling rules of %s: %w", notificationType, err)
+	}
+	for _, rule := range typeRules {
+		if rule == nil {
+			return nil, fmt.Errorf("error validating rules of %s: null rule", notificationType)
+		}
+		rule.Normalize()
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("error validating rules of %s: %w", notificationType, err)
+		}
+	}
+	if err := domain.ValidateGroup(typeRules); err != nil {
+		return nil, fmt.Errorf("error validating rules of %s: %w", notificationType, err)
+	}
+	return typeRules, nil
+}
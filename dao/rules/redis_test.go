@@ -0,0 +1,180 @@
+package rules
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisReplica(t *testing.T, server *miniredis.Miniredis, filePath string) *RedisRulesContainer {
+	t.Helper()
+	container, err := NewRedisRulesContainer(redis.NewClient(&redis.Options{Addr: server.Addr()}), filePath)
+	require.NoError(t, err)
+	t.Cleanup(func() { container.Close() })
+	return container
+}
+
+func newEmptyRulesFile(t *testing.T) string {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), "rules.json")
+	writeRulesFile(t, filePath, "[]")
+	return filePath
+}
+
+func TestRedisRulesContainer_Seed(t *testing.T) {
+	server := miniredis.RunT(t)
+	filePath := filepath.Join(t.TempDir(), "rules.json")
+	writeRulesFile(t, filePath, initialRules)
+
+	first := newTestRedisReplica(t, server, filePath)
+	require.NoError(t, first.DeleteRulesByType("status"))
+
+	// The rules file is only copied by the first replica, so the deleted type is not seeded again.
+	second := newTestRedisReplica(t, server, filePath)
+	rules, err := second.GetRules()
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+	assert.Len(t, rules["news"], 1)
+}
+
+func TestRedisRulesContainer_Seed_InvalidFile(t *testing.T) {
+	server := miniredis.RunT(t)
+	filePath := filepath.Join(t.TempDir(), "rules.json")
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	// A replica that can't read the rules file doesn't start, and doesn't mark the rules as seeded.
+	_, err := NewRedisRulesContainer(client, filePath)
+	assert.Error(t, err)
+	writeRulesFile(t, filePath, `[{"notificationType": "News", "maxLimit": 0, "timeInterval": "24h"}]`)
+	_, err = NewRedisRulesContainer(client, filePath)
+	assert.Error(t, err)
+	assert.False(t, server.Exists(rulesSeededKey))
+
+	writeRulesFile(t, filePath, initialRules)
+	container := newTestRedisReplica(t, server, filePath)
+	rules, err := container.GetRules()
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.True(t, server.Exists(rulesSeededKey))
+}
+
+func TestRedisRulesContainer_CRUD(t *testing.T) {
+	server := miniredis.RunT(t)
+	container := newTestRedisReplica(t, server, newEmptyRulesFile(t))
+	rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}
+
+	require.NoError(t, container.AddRule(rule))
+	require.NoError(t, container.AddRule(rule))
+	rules, _ := container.GetRuleByType("status")
+	assert.Len(t, rules, 2)
+
+	require.NoError(t, container.UpdateRulesByType("status", []*domain.RateLimitRule{rule}))
+	rules, _ = container.GetRuleByType("status")
+	assert.Len(t, rules, 1)
+
+	require.NoError(t, container.DeleteRulesByType("status"))
+	rules, _ = container.GetRuleByType("status")
+	assert.Empty(t, rules)
+	assert.ErrorIs(t, container.DeleteRulesByType("status"), errors.ErrRuleNotFound)
+}
+
+func TestRedisRulesContainer_UserTiers(t *testing.T) {
+	server := miniredis.RunT(t)
+	filePath := newEmptyRulesFile(t)
+	container := newTestRedisReplica(t, server, filePath)
+	replica := newTestRedisReplica(t, server, filePath)
+
+	tier, err := container.GetUserTier("user1")
+	require.NoError(t, err)
+	assert.Empty(t, tier)
+
+	require.NoError(t, container.SetUserTier("user1", "vip"))
+	tier, err = replica.GetUserTier("user1")
+	require.NoError(t, err)
+	assert.Equal(t, "vip", tier)
+
+	require.NoError(t, replica.DeleteUserTier("user1"))
+	tier, _ = container.GetUserTier("user1")
+	assert.Empty(t, tier)
+	assert.ErrorIs(t, container.DeleteUserTier("user1"), errors.ErrUserTierNotFound)
+}
+
+func TestRedisRulesContainer_UserQuietHours(t *testing.T) {
+	server := miniredis.RunT(t)
+	filePath := newEmptyRulesFile(t)
+	container := newTestRedisReplica(t, server, filePath)
+	replica := newTestRedisReplica(t, server, filePath)
+
+	stored, err := container.GetUserQuietHours("user1")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	userQuietHours := &domain.UserQuietHours{UserID: "user1", Timezone: "Europe/Madrid", Disabled: true}
+	require.NoError(t, container.SetUserQuietHours(userQuietHours))
+	stored, err = replica.GetUserQuietHours("user1")
+	require.NoError(t, err)
+	assert.Equal(t, userQuietHours, stored)
+
+	require.NoError(t, replica.DeleteUserQuietHours("user1"))
+	stored, _ = container.GetUserQuietHours("user1")
+	assert.Nil(t, stored)
+	assert.ErrorIs(t, container.DeleteUserQuietHours("user1"), errors.ErrUserQuietHoursNotFound)
+}
+
+func TestRedisRulesContainer_Replicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	filePath := filepath.Join(t.TempDir(), "rules.json")
+	writeRulesFile(t, filePath, initialRules)
+	replicas := []*RedisRulesContainer{
+		newTestRedisReplica(t, server, filePath),
+		newTestRedisReplica(t, server, filePath),
+	}
+	rule := &domain.RateLimitRule{NotificationType: "marketing", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Hour}}
+
+	require.NoError(t, replicas[0].AddRule(rule))
+	require.NoError(t, replicas[0].DeleteRulesByType("news"))
+
+	assert.Eventually(t, func() bool {
+		marketing, _ := replicas[1].GetRuleByType("marketing")
+		news, _ := replicas[1].GetRuleByType("news")
+		return len(marketing) == 1 && len(news) == 0
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestRedisRulesContainer_Replicas_Intervals(t *testing.T) {
+	server := miniredis.RunT(t)
+	filePath := newEmptyRulesFile(t)
+	first := newTestRedisReplica(t, server, filePath)
+
+	// The intervals are written to Redis as JSON, so the other replicas load the exact durations.
+	intervals := []time.Duration{90 * time.Minute, 500 * time.Millisecond, time.Hour + 30*time.Second}
+	for _, interval := range intervals {
+		require.NoError(t, first.AddRule(&domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: interval}}))
+	}
+
+	second := newTestRedisReplica(t, server, filePath)
+	rules, err := second.GetRuleByType("status")
+	require.NoError(t, err)
+	require.Len(t, rules, len(intervals))
+	for i, interval := range intervals {
+		assert.Equal(t, interval, rules[i].TimeInterval.Duration)
+	}
+}
+
+func TestRedisRulesContainer_InvalidStoredRules(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.HSet(rulesKey, "status", `[{"notificationType": "status", "maxLimit": 0, "timeInterval": "1m"}]`)
+	server.Set(rulesSeededKey, "1")
+
+	// The rules stored in Redis are validated like the rules file.
+	_, err := NewRedisRulesContainer(redis.NewClient(&redis.Options{Addr: server.Addr()}), newEmptyRulesFile(t))
+	assert.Error(t, err)
+}
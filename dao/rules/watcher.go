@@ -0,0 +1,107 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"rate-limiter/utils"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay groups the events of a single file save, which usually truncates and then writes it.
+const reloadDelay = 100 * time.Millisecond
+
+// Reload replaces the rules with the ones of the rules file, except the rules of the types changed
+// through the rules endpoints, which are kept. If the file can't be read or has invalid rules, the
+// current rules are kept.
+func (ic *InMemoryRulesContainer) Reload() (RulesDiff, error) {
+	newRules, err := readRulesFile(ic.filePath)
+	if err != nil {
+		return RulesDiff{}, err
+	}
+
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	for notificationType, typeRules := range ic.apiRules {
+		if utils.SerializeObject(newRules[notificationType]) != utils.SerializeObject(typeRules) {
+			fmt.Printf("Rules - keeping the rules of %s changed through the API instead of the ones of the file\n", notificationType)
+		}
+		if typeRules == nil {
+			delete(newRules, notificationType)
+		} else {
+			newRules[notificationType] = typeRules
+		}
+	}
+	diff := diffRules(ic.rules, newRules)
+	ic.rules = newRules
+	return diff, nil
+}
+
+// Watch reloads the rules when the rules file changes or the process receives a SIGHUP, until the
+// returned stop function is called.
+func (ic *InMemoryRulesContainer) Watch() (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// The directory is watched because editors usually replace the file instead of writing it.
+	if err := watcher.Add(filepath.Dir(ic.filePath)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(ic.filePath) && event.Has(fsnotify.Write|fsnotify.Create) {
+					reload = time.After(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Println("Rules - error watching rules file:", err)
+			case <-reload:
+				reload = nil
+				ic.reloadAndLog("rules file changed")
+			case <-signals:
+				ic.reloadAndLog("SIGHUP received")
+			}
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		watcher.Close()
+		close(done)
+	}
+	return stop, nil
+}
+
+func (ic *InMemoryRulesContainer) reloadAndLog(reason string) {
+	diff, err := ic.Reload()
+	if err != nil {
+		fmt.Printf("Rules - %s, keeping the current rules: %s\n", reason, err)
+		return
+	}
+	if diff.IsEmpty() {
+		fmt.Printf("Rules - %s, no changes\n", reason)
+		return
+	}
+	fmt.Printf("Rules - %s, reloaded. %s\n", reason, diff)
+}
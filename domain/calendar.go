@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+var locations sync.Map
+
+// Location returns the timezone the calendar window of the rule is evaluated in: the user timezone,
+// then the rule timezone, and UTC when neither is set.
+func (r *RateLimitRule) Location(userTimezone string) (*time.Location, error) {
+	timezone := userTimezone
+	if timezone == "" {
+		timezone = r.Timezone
+	}
+	return LoadLocation(timezone)
+}
+
+// LoadLocation is a cached time.LoadLocation, which reads the timezone database on every call.
+func LoadLocation(timezone string) (*time.Location, error) {
+	if location, ok := locations.Load(timezone); ok {
+		return location.(*time.Location), nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(timezone, location)
+	return location, nil
+}
+
+// WindowStart returns when the calendar window of the rule that contains now started. Weeks start
+// on monday. Rules without a calendar window start TimeInterval before now.
+func (r *RateLimitRule) WindowStart(now time.Time, location *time.Location) time.Time {
+	localNow := now.In(location)
+	year, month, day := localNow.Date()
+	switch r.Window {
+	case WindowDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, location)
+	case WindowWeek:
+		daysSinceMonday := (int(localNow.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, location)
+	case WindowMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, location)
+	}
+	return now.Add(-r.TimeInterval.Duration)
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitRule_WindowStart(t *testing.T) {
+	buenosAires, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	assert.NoError(t, err)
+	// wednesday 2024-05-15 01:30 in UTC is still tuesday 2024-05-14 22:30 in Buenos Aires
+	now := time.Date(2024, 5, 15, 1, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		rule     RateLimitRule
+		location *time.Location
+		expected time.Time
+	}{
+		{
+			name:     "rolling interval",
+			rule:     RateLimitRule{TimeInterval: Duration{Duration: 24 * time.Hour}},
+			location: buenosAires,
+			expected: time.Date(2024, 5, 14, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "day in UTC",
+			rule:     RateLimitRule{Window: WindowDay},
+			location: time.UTC,
+			expected: time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day in Buenos Aires",
+			rule:     RateLimitRule{Window: WindowDay},
+			location: buenosAires,
+			expected: time.Date(2024, 5, 14, 0, 0, 0, 0, buenosAires),
+		},
+		{
+			name:     "week in Buenos Aires",
+			rule:     RateLimitRule{Window: WindowWeek},
+			location: buenosAires,
+			expected: time.Date(2024, 5, 13, 0, 0, 0, 0, buenosAires),
+		},
+		{
+			name:     "month in UTC",
+			rule:     RateLimitRule{Window: WindowMonth},
+			location: time.UTC,
+			expected: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.True(t, tc.expected.Equal(tc.rule.WindowStart(now, tc.location)))
+		})
+	}
+}
+
+func TestRateLimitRule_Location(t *testing.T) {
+	rule := RateLimitRule{Window: WindowDay, Timezone: "Europe/Madrid"}
+
+	location, err := rule.Location("")
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Madrid", location.String())
+
+	location, err = rule.Location("Asia/Tokyo")
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", location.String())
+
+	_, err = rule.Location("Mars/Olympus_Mons")
+	assert.Error(t, err)
+
+	location, err = (&RateLimitRule{Window: WindowDay}).Location("")
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, location)
+}
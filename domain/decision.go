@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// Decision is the result of checking the rules of a notification. BlockingRule is the first rule
+// that rejected it. Quotas has the quota of every rule after the decision, and is empty when the
+// type has no rules, the notifications storage can't tell them or the notification was rejected by
+// quiet hours, which end at QuietUntil. DeliverAt is when the notification is delivered if the
+// blocking rule delayed it. Timezone is the one of the user the rules were checked in.
+type Decision struct {
+	Allowed      bool           `json:"allowed"`
+	BlockingRule *RateLimitRule `json:"blockingRule,omitempty"`
+	Quotas       []*RuleQuota   `json:"quotas,omitempty"`
+	QuietUntil   *time.Time     `json:"quietUntil,omitempty"`
+	DeliverAt    *time.Time     `json:"deliverAt,omitempty"`
+	Timestamp    time.Time      `json:"-"`
+	Timezone     string         `json:"-"`
+}
+
+// OnExceed returns what is done with the notification when it is rejected: the policy of the
+// blocking rule, or OnExceedReject when it has none.
+func (d *Decision) OnExceed() string {
+	if d.Allowed || d.BlockingRule == nil || d.BlockingRule.OnExceed == "" {
+		return OnExceedReject
+	}
+	return d.BlockingRule.OnExceed
+}
+
+// MarshalJSON adds retryAfter, the seconds until the notification would be allowed, to the
+// rejected decisions that can tell it.
+func (d *Decision) MarshalJSON() ([]byte, error) {
+	type decision Decision
+	var retryAfter *int
+	if d.HasRetryAfter() {
+		seconds := int(d.RetryAfter().Seconds())
+		retryAfter = &seconds
+	}
+	return json.Marshal(struct {
+		*decision
+		RetryAfter *int `json:"retryAfter,omitempty"`
+	}{
+		decision:   (*decision)(d),
+		RetryAfter: retryAfter,
+	})
+}
+
+// LimitingQuota returns the quota closest to being exhausted, the one with the fewest remaining
+// notifications and, among them, the one that takes longest to free a slot. nil if there are none.
+func (d *Decision) LimitingQuota() *RuleQuota {
+	var limiting *RuleQuota
+	for _, quota := range d.Quotas {
+		if limiting == nil || quota.Remaining < limiting.Remaining ||
+			quota.Remaining == limiting.Remaining && d.untilNextSlot(quota) > d.untilNextSlot(limiting) {
+			limiting = quota
+		}
+	}
+	return limiting
+}
+
+// HasRetryAfter tells if the decision was rejected by quiet hours or has the quotas to tell when to
+// retry.
+func (d *Decision) HasRetryAfter() bool {
+	return !d.Allowed && (len(d.Quotas) > 0 || d.QuietUntil != nil)
+}
+
+// RetryAfter returns how long until the quiet hours end and every exhausted quota has a free slot,
+// rounded up to seconds.
+func (d *Decision) RetryAfter() time.Duration {
+	var retryAfter time.Duration
+	if d.QuietUntil != nil {
+		retryAfter = d.QuietUntil.Sub(d.Timestamp)
+	}
+	for _, quota := range d.Quotas {
+		if quota.Remaining == 0 {
+			retryAfter = max(retryAfter, d.untilNextSlot(quota))
+		}
+	}
+	return CeilSeconds(retryAfter)
+}
+
+// ResetAfter returns how long until the limiting quota frees a slot, rounded up to seconds.
+func (d *Decision) ResetAfter() time.Duration {
+	limiting := d.LimitingQuota()
+	if limiting == nil {
+		return 0
+	}
+	return CeilSeconds(d.untilNextSlot(limiting))
+}
+
+func (d *Decision) untilNextSlot(quota *RuleQuota) time.Duration {
+	if quota.NextSlotAt == nil {
+		return 0
+	}
+	return max(quota.NextSlotAt.Sub(d.Timestamp), 0)
+}
+
+// CeilSeconds rounds the duration up to whole seconds.
+func CeilSeconds(d time.Duration) time.Duration {
+	return time.Duration(math.Ceil(d.Seconds())) * time.Second
+}
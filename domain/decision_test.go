@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecision(t *testing.T) {
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		t := now.Add(offset)
+		return &t
+	}
+
+	testCases := []struct {
+		name               string
+		quotas             []*RuleQuota
+		expectedLimit      int
+		expectedResetAfter time.Duration
+		expectedRetryAfter time.Duration
+	}{
+		{
+			name: "without quotas",
+		},
+		{
+			name: "the fewest remaining limits",
+			quotas: []*RuleQuota{
+				{Limit: 10, Remaining: 3, NextSlotAt: at(time.Hour)},
+				{Limit: 5, Remaining: 2, NextSlotAt: at(1500 * time.Millisecond)},
+			},
+			expectedLimit:      5,
+			expectedResetAfter: 2 * time.Second,
+		},
+		{
+			name: "the exhausted quota that frees last limits",
+			quotas: []*RuleQuota{
+				{Limit: 1, Remaining: 0, NextSlotAt: at(time.Second)},
+				{Limit: 2, Remaining: 0, NextSlotAt: at(time.Minute)},
+				{Limit: 3, Remaining: 0},
+			},
+			expectedLimit:      2,
+			expectedResetAfter: time.Minute,
+			expectedRetryAfter: time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decision := &Decision{Timestamp: now, Quotas: tc.quotas}
+
+			if limiting := decision.LimitingQuota(); tc.expectedLimit == 0 {
+				assert.Nil(t, limiting)
+			} else {
+				assert.Equal(t, tc.expectedLimit, limiting.Limit)
+			}
+			assert.Equal(t, tc.expectedResetAfter, decision.ResetAfter())
+			assert.Equal(t, tc.expectedRetryAfter, decision.RetryAfter())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Digest collects the notifications of a user and type over the limit of a OnExceedDigest rule,
+// which are sent together at DeliverAt if the rules allow it then, or at the end of the window of
+// the rule that rejects it.
+type Digest struct {
+	UserID           string `json:"userId"`
+	NotificationType string `json:"notificationType"`
+	Timezone         string `json:"timezone,omitempty"`
+	// Count is how many notifications the digest has, with or without payload.
+	Count     int               `json:"count"`
+	Payloads  []json.RawMessage `json:"payloads"`
+	CreatedAt time.Time         `json:"createdAt"`
+	DeliverAt time.Time         `json:"deliverAt"`
+	// Attempts counts the times the digest was retried after an error.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// NewDigest returns the digest with the notification, to be sent at deliverAt.
+func NewDigest(notification *DelayedNotification, deliverAt time.Time) *Digest {
+	digest := &Digest{
+		UserID:           notification.UserID,
+		NotificationType: notification.NotificationType,
+		Timezone:         notification.Timezone,
+		Count:            1,
+		Payloads:         []json.RawMessage{},
+		CreatedAt:        notification.CreatedAt,
+		DeliverAt:        deliverAt,
+	}
+	if len(notification.Payload) > 0 {
+		digest.Payloads = append(digest.Payloads, notification.Payload)
+	}
+	return digest
+}
+
+// DigestDeliverAt returns when the digest of a notification rejected by the decision is sent: when
+// its quiet hours end, or when the window of the blocking rule ends, so the digest takes a single
+// notification of the next window instead of being retried every time the rule frees a slot.
+func (d *Decision) DigestDeliverAt() (time.Time, error) {
+	if d.QuietUntil != nil {
+		return *d.QuietUntil, nil
+	}
+	_, windowEnd, err := d.BlockingRule.FixedWindow(d.Timestamp, d.Timezone)
+	return windowEnd, err
+}
+
+// DigestContent is the payload of the notification a digest is sent as.
+type DigestContent struct {
+	Count    int               `json:"count"`
+	Payloads []json.RawMessage `json:"payloads"`
+}
+
+// SendParams returns the params of the notification the digest is sent as, with the count and the
+// payloads of its notifications as payload.
+func (d *Digest) SendParams() (SendNotificationParams, error) {
+	payload, err := json.Marshal(DigestContent{Count: d.Count, Payloads: d.Payloads})
+	if err != nil {
+		return SendNotificationParams{}, err
+	}
+	return SendNotificationParams{
+		UserID:           d.UserID,
+		NotificationType: d.NotificationType,
+		Timezone:         d.Timezone,
+		Payload:          payload,
+	}, nil
+}
+
+// RetryBackoff returns how long to wait before sending the digest again when it couldn't be
+// checked.
+func (d *Digest) RetryBackoff() time.Duration {
+	return retryBackoff(d.Attempts)
+}
+
+// AttemptsExhausted tells if the digest was attempted MaxDeliveryAttempts times already.
+func (d *Digest) AttemptsExhausted() bool {
+	return d.Attempts >= MaxDeliveryAttempts
+}
+
+// Key identifies the digest of the user and type, which collects all their notifications until it
+// is sent.
+func (d *Digest) Key() string {
+	return d.NotificationType + ":" + d.UserID
+}
+
+// Merge adds the notifications of other to the digest, the oldest payloads first. The digest is sent
+// at the earliest DeliverAt of both, and keeps the most attempts.
+func (d *Digest) Merge(other *Digest) {
+	d.Count += other.Count
+	d.Attempts = max(d.Attempts, other.Attempts)
+	if other.CreatedAt.Before(d.CreatedAt) {
+		d.Payloads = append(append([]json.RawMessage{}, other.Payloads...), d.Payloads...)
+		d.CreatedAt = other.CreatedAt
+	} else {
+		d.Payloads = append(d.Payloads, other.Payloads...)
+	}
+	if other.DeliverAt.Before(d.DeliverAt) {
+		d.DeliverAt = other.DeliverAt
+	}
+}
@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigest_Merge(t *testing.T) {
+	now := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+	digest := NewDigest(&DelayedNotification{UserID: "user1", NotificationType: "status", Payload: json.RawMessage(`{"id":2}`), CreatedAt: now}, now.Add(time.Hour))
+
+	retried := NewDigest(&DelayedNotification{UserID: "user1", NotificationType: "status", CreatedAt: now.Add(time.Minute)}, now.Add(2*time.Hour))
+	retried.Attempts = 2
+	digest.Merge(retried)
+	digest.Merge(NewDigest(&DelayedNotification{UserID: "user1", NotificationType: "status", Payload: json.RawMessage(`{"id":1}`), CreatedAt: now.Add(-time.Minute)}, now.Add(30*time.Minute)))
+
+	assert.Equal(t, "status:user1", digest.Key())
+	assert.Equal(t, 3, digest.Count)
+	// The notifications without payload are only counted, and the oldest payloads go first.
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"id":1}`), json.RawMessage(`{"id":2}`)}, digest.Payloads)
+	assert.Equal(t, now.Add(-time.Minute), digest.CreatedAt)
+	assert.Equal(t, now.Add(30*time.Minute), digest.DeliverAt)
+	assert.Equal(t, 2, digest.Attempts)
+	assert.False(t, digest.AttemptsExhausted())
+
+	// The digest is sent as a single notification with the count and the payloads.
+	params, err := digest.SendParams()
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", params.UserID)
+	assert.JSONEq(t, `{"count":3,"payloads":[{"id":1},{"id":2}]}`, string(params.Payload))
+
+	digest.Attempts = MaxDeliveryAttempts
+	assert.True(t, digest.AttemptsExhausted())
+}
+
+func TestDecision_DigestDeliverAt(t *testing.T) {
+	now := time.Date(2024, 5, 14, 10, 20, 0, 0, time.UTC)
+	quietUntil := now.Add(3 * time.Hour)
+	testCases := []struct {
+		name              string
+		decision          *Decision
+		expectedDeliverAt time.Time
+	}{
+		{
+			name: "time interval",
+			decision: &Decision{
+				BlockingRule: &RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: Duration{Duration: time.Hour}},
+				Timestamp:    now,
+			},
+			expectedDeliverAt: time.Date(2024, 5, 14, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "calendar window in the timezone of the user",
+			decision: &Decision{
+				BlockingRule: &RateLimitRule{NotificationType: "status", MaxLimit: 2, Window: WindowDay},
+				Timestamp:    now,
+			},
+			expectedDeliverAt: time.Date(2024, 5, 14, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "quiet hours",
+			decision: &Decision{
+				BlockingRule: &RateLimitRule{NotificationType: "status", QuietHours: &QuietHours{Start: "08:00", End: "13:20"}},
+				Timestamp:    now,
+				QuietUntil:   &quietUntil,
+			},
+			expectedDeliverAt: quietUntil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.decision.Timezone = "Europe/Madrid"
+			deliverAt, err := tc.decision.DigestDeliverAt()
+
+			assert.NoError(t, err)
+			assert.True(t, tc.expectedDeliverAt.Equal(deliverAt), "%s", deliverAt)
+		})
+	}
+}
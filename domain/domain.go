@@ -2,14 +2,62 @@ package domain
 
 import (
 	"encoding/json"
+	"fmt"
 	"rate-limiter/utils"
 	"time"
 )
 
+const (
+	AlgorithmSlidingLog    = "sliding_log"
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmGCRA          = "gcra"
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+// NotificationTypeAll is the notification type of the global rules, which count the notifications of
+// every type.
+const NotificationTypeAll = "*"
+
+const (
+	ModeEnforce = "enforce"
+	ModeShadow  = "shadow"
+)
+
+const (
+	WindowDay   = "day"
+	WindowWeek  = "week"
+	WindowMonth = "month"
+)
+
 type RateLimitRule struct {
 	NotificationType string   `json:"notificationType"`
 	MaxLimit         int      `json:"maxLimit"`
 	TimeInterval     Duration `json:"timeInterval"`
+	// Algorithm defaults to AlgorithmSlidingLog when empty.
+	Algorithm string `json:"algorithm,omitempty"`
+	// Burst is the token bucket capacity, or the GCRA burst size. Defaults to MaxLimit.
+	Burst int `json:"burst,omitempty"`
+	// RefillRate is the number of tokens added per second. Defaults to MaxLimit per TimeInterval.
+	RefillRate float64 `json:"refillRate,omitempty"`
+	// Window aligns a sliding log rule to calendar days, weeks or months instead of TimeInterval.
+	Window string `json:"window,omitempty"`
+	// Timezone is the IANA timezone the calendar window is evaluated in, unless the user has its own.
+	Timezone string `json:"timezone,omitempty"`
+	// Mode defaults to ModeEnforce when empty. ModeShadow rules are checked, but never reject.
+	Mode string `json:"mode,omitempty"`
+	// Tier makes the rule apply only to the users of the tier, instead of the rules of its type
+	// without tier.
+	Tier string `json:"tier,omitempty"`
+	// Group makes the rule count the notifications of all its NotificationTypes together. The rules
+	// of a group are stored with the notification type returned by GroupNotificationType.
+	Group             string   `json:"group,omitempty"`
+	NotificationTypes []string `json:"notificationTypes,omitempty"`
+	// QuietHours makes the rule reject the notifications sent in its daily window instead of
+	// limiting how many are sent, so the rule has no limits.
+	QuietHours *QuietHours `json:"quietHours,omitempty"`
+	// OnExceed is what is done with the notifications the rule rejects. Defaults to OnExceedReject
+	// when empty.
+	OnExceed string `json:"onExceed,omitempty"`
 }
 type Duration struct {
 	time.Duration
@@ -24,6 +72,10 @@ type Notification struct {
 type SendNotificationParams struct {
 	UserID           string
 	NotificationType string
+	// Timezone is the IANA timezone of the user, if known.
+	Timezone string
+	// Payload is the optional json body of the notification.
+	Payload json.RawMessage
 }
 
 type GetNotificationParams struct {
@@ -32,6 +84,64 @@ type GetNotificationParams struct {
 	TimeInterval     time.Duration
 }
 
+type AddNotificationParams struct {
+	UserID           string
+	NotificationType string
+	Timezone         string
+	Timestamp        time.Time
+	// Rules are the rules the notification must conform to before being added.
+	Rules []*RateLimitRule
+}
+
+// AddNotificationResult is the outcome of checking the rules of a notification.
+type AddNotificationResult struct {
+	// RejectedBy is the first enforced rule that rejects the notification, nil if it was added.
+	RejectedBy *RateLimitRule
+	// ShadowRejections are the shadow rules that would have rejected the notification, which are
+	// checked whether it was added or not.
+	ShadowRejections []*RateLimitRule
+}
+
+// RuleState holds the per user state of the stateful algorithms.
+type RuleState struct {
+	Tokens     float64   `json:"tokens"`
+	LastRefill time.Time `json:"lastRefill"`
+	// TAT is the GCRA theoretical arrival time.
+	TAT time.Time `json:"tat"`
+	// Sliding window counters.
+	WindowStart   time.Time `json:"windowStart"`
+	CurrentCount  int       `json:"currentCount"`
+	PreviousCount int       `json:"previousCount"`
+}
+
+type RuleStateParams struct {
+	UserID           string
+	NotificationType string
+	RuleKey          string
+}
+
+func (r *RateLimitRule) IsGlobal() bool {
+	return r.NotificationType == NotificationTypeAll
+}
+
+func (r *RateLimitRule) IsShadow() bool {
+	return r.Mode == ModeShadow
+}
+
+// Key identifies the rule when storing its state, so a shadow rule doesn't share it with an
+// enforced rule with the same limits, nor the rules of different tiers or calendar windows.
+func (r *RateLimitRule) Key() string {
+	mode := ModeEnforce
+	if r.IsShadow() {
+		mode = ModeShadow
+	}
+	if r.IsQuietHours() {
+		return fmt.Sprintf("%s:%s:%s:quiet_hours:%s-%s", r.NotificationType, mode, r.Tier, r.QuietHours.Start, r.QuietHours.End)
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%d:%s:%s:%d:%g", r.NotificationType, mode, r.Tier, r.Algorithm, r.MaxLimit,
+		r.TimeInterval.Duration, r.Window, r.Burst, r.RefillRate)
+}
+
 func (d *Duration) UnmarshalJSON(b []byte) error {
 	var durationStr string
 	if err := json.Unmarshal(b, &durationStr); err != nil {
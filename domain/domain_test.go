@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitRule_Key(t *testing.T) {
+	rule := RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: Duration{Duration: time.Hour}}
+	quietHours := RateLimitRule{NotificationType: "status", QuietHours: &QuietHours{Start: "22:00", End: "08:00"}}
+	with := func(rule RateLimitRule, change func(*RateLimitRule)) *RateLimitRule {
+		change(&rule)
+		return &rule
+	}
+
+	testCases := []struct {
+		name  string
+		rule  *RateLimitRule
+		other *RateLimitRule
+		equal bool
+	}{
+		{
+			name:  "default mode is enforce",
+			rule:  &rule,
+			other: with(rule, func(r *RateLimitRule) { r.Mode = ModeEnforce }),
+			equal: true,
+		},
+		{
+			name:  "shadow rule",
+			rule:  &rule,
+			other: with(rule, func(r *RateLimitRule) { r.Mode = ModeShadow }),
+		},
+		{
+			name:  "tier",
+			rule:  &rule,
+			other: with(rule, func(r *RateLimitRule) { r.Tier = "premium" }),
+		},
+		{
+			name:  "window",
+			rule:  with(rule, func(r *RateLimitRule) { r.TimeInterval = Duration{}; r.Window = WindowDay }),
+			other: with(rule, func(r *RateLimitRule) { r.TimeInterval = Duration{}; r.Window = WindowWeek }),
+		},
+		{
+			name:  "shadow quiet hours",
+			rule:  &quietHours,
+			other: with(quietHours, func(r *RateLimitRule) { r.Mode = ModeShadow }),
+		},
+		{
+			name:  "quiet hours of a tier",
+			rule:  &quietHours,
+			other: with(quietHours, func(r *RateLimitRule) { r.Tier = "premium" }),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.equal, tc.rule.Key() == tc.other.Key(), "%s and %s", tc.rule.Key(), tc.other.Key())
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// FixedWindow returns the fixed window that contains now: the calendar window of the rule in the
+// timezone of the user, or the window of TimeInterval aligned to the unix epoch.
+func (r *RateLimitRule) FixedWindow(now time.Time, userTimezone string) (time.Time, time.Time, error) {
+	if r.Window == "" {
+		start := now.Truncate(r.TimeInterval.Duration)
+		return start, start.Add(r.TimeInterval.Duration), nil
+	}
+
+	location, err := r.Location(userTimezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start := r.WindowStart(now, location)
+	switch r.Window {
+	case WindowWeek:
+		return start, start.AddDate(0, 0, 7), nil
+	case WindowMonth:
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	return start, start.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitRule_FixedWindow(t *testing.T) {
+	now := time.Date(2024, 5, 15, 1, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		rule          RateLimitRule
+		timezone      string
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			name:          "interval",
+			rule:          RateLimitRule{TimeInterval: Duration{Duration: time.Hour}},
+			expectedStart: time.Date(2024, 5, 15, 1, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2024, 5, 15, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "day in the user timezone",
+			rule:          RateLimitRule{Window: WindowDay},
+			timezone:      "America/Argentina/Buenos_Aires",
+			expectedStart: time.Date(2024, 5, 14, 3, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2024, 5, 15, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "week",
+			rule:          RateLimitRule{Window: WindowWeek},
+			expectedStart: time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "month",
+			rule:          RateLimitRule{Window: WindowMonth},
+			expectedStart: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, err := tc.rule.FixedWindow(now, tc.timezone)
+			assert.NoError(t, err)
+			assert.True(t, tc.expectedStart.Equal(start), "start %s", start)
+			assert.True(t, tc.expectedEnd.Equal(end), "end %s", end)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// EmissionInterval returns the time between two notifications at the sustained rate of the rule.
+func (r *RateLimitRule) EmissionInterval() time.Duration {
+	rate := r.TokensPerSecond()
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// BurstTolerance returns how far ahead of now the theoretical arrival time can be.
+func (r *RateLimitRule) BurstTolerance() time.Duration {
+	return time.Duration(r.Capacity()-1) * r.EmissionInterval()
+}
+
+// AllowsGCRA reports whether a notification sent at now conforms to the rule.
+func (s *RuleState) AllowsGCRA(rule *RateLimitRule, now time.Time) bool {
+	if rule.TokensPerSecond() <= 0 || rule.Capacity() < 1 {
+		return false
+	}
+	return !s.TAT.After(now.Add(rule.BurstTolerance()))
+}
+
+// AdvanceGCRA registers a notification sent at now.
+func (s *RuleState) AdvanceGCRA(rule *RateLimitRule, now time.Time) {
+	if s.TAT.Before(now) {
+		s.TAT = now
+	}
+	s.TAT = s.TAT.Add(rule.EmissionInterval())
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"fmt"
+	"rate-limiter/errors"
+	"slices"
+	"strings"
+)
+
+// groupTypePrefix is the prefix of the notification type the rules of a group are stored with.
+const groupTypePrefix = "group:"
+
+// GroupNotificationType returns the notification type of the rules of the group, which count the
+// notifications of all the types of the group together.
+func GroupNotificationType(group string) string {
+	return groupTypePrefix + group
+}
+
+func IsGroupNotificationType(notificationType string) bool {
+	return strings.HasPrefix(notificationType, groupTypePrefix)
+}
+
+func (r *RateLimitRule) IsGroup() bool {
+	return r.Group != ""
+}
+
+// AppliesTo tells if the rule limits the notifications of the type.
+func (r *RateLimitRule) AppliesTo(notificationType string) bool {
+	if r.IsGroup() {
+		return slices.Contains(r.NotificationTypes, notificationType)
+	}
+	return r.IsGlobal() || r.NotificationType == notificationType
+}
+
+// Normalize lowercases the names of the rule, and sets the notification type of the group rules,
+// which is the group when the rule comes from the notification type of a group.
+func (r *RateLimitRule) Normalize() {
+	r.NotificationType = strings.ToLower(r.NotificationType)
+	r.Tier = strings.ToLower(r.Tier)
+	r.Group = strings.ToLower(r.Group)
+	if r.Group == "" && IsGroupNotificationType(r.NotificationType) {
+		r.Group = strings.TrimPrefix(r.NotificationType, groupTypePrefix)
+	}
+	if r.IsGroup() {
+		r.NotificationType = GroupNotificationType(r.Group)
+	}
+	for i, notificationType := range r.NotificationTypes {
+		r.NotificationTypes[i] = strings.ToLower(notificationType)
+	}
+}
+
+// ValidateGroup checks that all the rules of a group have the same notification types, since they
+// count the same notifications.
+func ValidateGroup(rules []*RateLimitRule) error {
+	var groupTypes []string
+	for _, rule := range rules {
+		if !rule.IsGroup() {
+			continue
+		}
+		notificationTypes := slices.Clone(rule.NotificationTypes)
+		slices.Sort(notificationTypes)
+		if groupTypes == nil {
+			groupTypes = notificationTypes
+		} else if !slices.Equal(groupTypes, notificationTypes) {
+			return fmt.Errorf("%w: the rules of group '%s' must have the same notificationTypes", errors.ErrInvalidRule, rule.Group)
+		}
+	}
+	return nil
+}
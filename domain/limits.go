@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// UsesHistory reports whether the rule counts the notifications history instead of keeping a state.
+func (r *RateLimitRule) UsesHistory() bool {
+	return r.Algorithm == "" || r.Algorithm == AlgorithmSlidingLog
+}
+
+// Since returns from when the notifications are counted by a history rule.
+func (r *RateLimitRule) Since(now time.Time, userTimezone string) (time.Time, error) {
+	if r.Window == "" {
+		return now.Add(-r.TimeInterval.Duration), nil
+	}
+
+	location, err := r.Location(userTimezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.WindowStart(now, location), nil
+}
+
+// Allows reports whether a notification sent at now conforms to the rule. sent is the number of
+// notifications counted by history rules, and state is the stored state of the other ones, nil if
+// there is none yet.
+func (r *RateLimitRule) Allows(sent int, state *RuleState, now time.Time) bool {
+	switch r.Algorithm {
+	case AlgorithmTokenBucket:
+		return r.refilledBucket(state, now).Tokens >= 1
+	case AlgorithmGCRA:
+		return copyState(state).AllowsGCRA(r, now)
+	case AlgorithmSlidingWindow:
+		return copyState(state).AllowsSlidingWindow(r, now)
+	}
+	return sent < r.MaxLimit
+}
+
+// Register returns the state of the rule after a notification is sent at now, nil for history rules.
+func (r *RateLimitRule) Register(state *RuleState, now time.Time) *RuleState {
+	switch r.Algorithm {
+	case AlgorithmTokenBucket:
+		bucket := r.refilledBucket(state, now)
+		bucket.Tokens = math.Max(0, bucket.Tokens-1)
+		return bucket
+	case AlgorithmGCRA:
+		next := copyState(state)
+		next.AdvanceGCRA(r, now)
+		return next
+	case AlgorithmSlidingWindow:
+		next := copyState(state)
+		next.CountSlidingWindow(r, now)
+		return next
+	}
+	return nil
+}
+
+func (r *RateLimitRule) refilledBucket(state *RuleState, now time.Time) *RuleState {
+	if state == nil {
+		return NewTokenBucket(r, now)
+	}
+	bucket := copyState(state)
+	bucket.Refill(r, now)
+	return bucket
+}
+
+func copyState(state *RuleState) *RuleState {
+	if state == nil {
+		return &RuleState{}
+	}
+	stateCopy := *state
+	return &stateCopy
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// OnExceedReject rejects the notifications over the limit, which is the default.
+	OnExceedReject = "reject"
+	// OnExceedDelay stores the notifications over the limit, and delivers them once the rules allow
+	// them.
+	OnExceedDelay = "delay"
+	// OnExceedDropSilently drops the notifications over the limit without telling the caller.
+	OnExceedDropSilently = "drop_silently"
+	// OnExceedDigest collects the notifications over the limit of each user and type, and sends
+	// them together when the window of the rule ends.
+	OnExceedDigest = "digest"
+)
+
+// MaxDeliveryAttempts is how many times a delayed notification is delayed again, or a delayed
+// notification or a digest is retried after an error, before it is dropped.
+const MaxDeliveryAttempts = 10
+
+// The delayed notifications the rules can't tell when to retry, and the ones and the digests that
+// can't be checked, are retried after a backoff, which doubles with every attempt from
+// minRetryBackoff up to maxRetryBackoff.
+const (
+	minRetryBackoff = 5 * time.Second
+	maxRetryBackoff = time.Hour
+)
+
+// DelayedNotification is a notification over the limit of a OnExceedDelay rule, which is delivered
+// at DeliverAt if the rules allow it then, or delayed again.
+type DelayedNotification struct {
+	ID               string          `json:"id"`
+	UserID           string          `json:"userId"`
+	NotificationType string          `json:"notificationType"`
+	Timezone         string          `json:"timezone,omitempty"`
+	Payload          json.RawMessage `json:"payload,omitempty"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	DeliverAt        time.Time       `json:"deliverAt"`
+	// Attempts counts the times the notification was delayed again after DeliverAt, or retried after
+	// an error.
+	Attempts int `json:"attempts"`
+}
+
+// NewDelayedNotification returns the delayed notification of the params sent at now.
+func NewDelayedNotification(params SendNotificationParams, now time.Time) *DelayedNotification {
+	return &DelayedNotification{
+		ID:               fmt.Sprintf("%d-%d", now.UnixNano(), rand.Int63()),
+		UserID:           params.UserID,
+		NotificationType: params.NotificationType,
+		Timezone:         params.Timezone,
+		Payload:          params.Payload,
+		CreatedAt:        now,
+	}
+}
+
+// RetryBackoff returns how long to wait before delivering the notification again when the rules
+// can't tell when they allow it, or it couldn't be checked.
+func (n *DelayedNotification) RetryBackoff() time.Duration {
+	return retryBackoff(n.Attempts)
+}
+
+// AttemptsExhausted tells if the notification was attempted MaxDeliveryAttempts times already.
+func (n *DelayedNotification) AttemptsExhausted() bool {
+	return n.Attempts >= MaxDeliveryAttempts
+}
+
+func retryBackoff(attempts int) time.Duration {
+	return min(minRetryBackoff<<min(attempts, 20), maxRetryBackoff)
+}
+
+func (n *DelayedNotification) SendParams() SendNotificationParams {
+	return SendNotificationParams{
+		UserID:           n.UserID,
+		NotificationType: n.NotificationType,
+		Timezone:         n.Timezone,
+		Payload:          n.Payload,
+	}
+}
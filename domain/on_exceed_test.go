@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayedNotification_RetryBackoff(t *testing.T) {
+	testCases := []struct {
+		attempts          int
+		expectedBackoff   time.Duration
+		expectedExhausted bool
+	}{
+		{attempts: 0, expectedBackoff: 5 * time.Second},
+		{attempts: 1, expectedBackoff: 10 * time.Second},
+		{attempts: 3, expectedBackoff: 40 * time.Second},
+		{attempts: 9, expectedBackoff: 2560 * time.Second},
+		{attempts: MaxDeliveryAttempts, expectedBackoff: time.Hour, expectedExhausted: true},
+		{attempts: 100, expectedBackoff: time.Hour, expectedExhausted: true},
+	}
+
+	for _, tc := range testCases {
+		notification := &DelayedNotification{Attempts: tc.attempts}
+
+		assert.Equal(t, tc.expectedBackoff, notification.RetryBackoff(), "attempts %d", tc.attempts)
+		assert.Equal(t, tc.expectedExhausted, notification.AttemptsExhausted(), "attempts %d", tc.attempts)
+	}
+}
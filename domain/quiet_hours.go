@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const clockLayout = "15:04"
+
+// QuietHours is a daily window, from Start to End as "15:04" local times, in which the notifications
+// are not sent. A window that ends before it starts spans midnight.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// UserQuietHours are the quiet hours settings of a user.
+type UserQuietHours struct {
+	UserID string `json:"userId"`
+	// Timezone is the IANA timezone the quiet hours of the user are evaluated in, when the request
+	// has none.
+	Timezone string `json:"timezone,omitempty"`
+	// Override replaces the quiet hours of all the rules for the user.
+	Override *QuietHours `json:"override,omitempty"`
+	// Disabled lets the notifications to the user through at any time of the day.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+func (r *RateLimitRule) IsQuietHours() bool {
+	return r.QuietHours != nil
+}
+
+// PartitionQuietHours splits the rules into the quiet hours rules and the ones that limit how many
+// notifications are sent.
+func PartitionQuietHours(rules []*RateLimitRule) (quietHours, limits []*RateLimitRule) {
+	quietHours = []*RateLimitRule{}
+	limits = []*RateLimitRule{}
+	for _, rule := range rules {
+		if rule.IsQuietHours() {
+			quietHours = append(quietHours, rule)
+		} else {
+			limits = append(limits, rule)
+		}
+	}
+	return quietHours, limits
+}
+
+// QuietUntil returns when the quiet hours that contain now end for the user, or nil if now is
+// outside them. The settings of the user, if any, override the quiet hours of the rule and, without
+// userTimezone, the timezone they are evaluated in.
+func (r *RateLimitRule) QuietUntil(now time.Time, userTimezone string, settings *UserQuietHours) (*time.Time, error) {
+	quietHours := r.QuietHours
+	if settings != nil {
+		if settings.Disabled {
+			return nil, nil
+		}
+		if settings.Override != nil {
+			quietHours = settings.Override
+		}
+		if userTimezone == "" {
+			userTimezone = settings.Timezone
+		}
+	}
+
+	location, err := r.Location(userTimezone)
+	if err != nil {
+		return nil, err
+	}
+	return quietHours.Until(now, location)
+}
+
+// Until returns when the quiet hours that contain now end in the location, or nil if now is outside
+// them.
+func (q *QuietHours) Until(now time.Time, location *time.Location) (*time.Time, error) {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return nil, err
+	}
+
+	localNow := now.In(location)
+	minute := localNow.Hour()*60 + localNow.Minute()
+	if start < end && (minute < start || minute >= end) || start > end && minute < start && minute >= end {
+		return nil, nil
+	}
+	year, month, day := localNow.Date()
+	until := time.Date(year, month, day, end/60, end%60, 0, 0, location)
+	if !until.After(localNow) {
+		until = time.Date(year, month, day+1, end/60, end%60, 0, 0, location)
+	}
+	return &until, nil
+}
+
+// Validate checks that the window has a start and an end, and that they are different.
+func (q *QuietHours) Validate() error {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return fmt.Errorf("start must be a time of the day as HH:MM")
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return fmt.Errorf("end must be a time of the day as HH:MM")
+	}
+	if start == end {
+		return fmt.Errorf("start and end can't be the same time")
+	}
+	return nil
+}
+
+// parseClock returns the minutes since midnight of the "15:04" time.
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitRule_QuietUntil(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	require.NoError(t, err)
+	nightRule := &RateLimitRule{NotificationType: "marketing", QuietHours: &QuietHours{Start: "22:00", End: "08:00"}}
+	lunchRule := &RateLimitRule{NotificationType: "marketing", QuietHours: &QuietHours{Start: "13:00", End: "15:30"}}
+
+	testCases := []struct {
+		name         string
+		rule         *RateLimitRule
+		now          time.Time
+		userTimezone string
+		settings     *UserQuietHours
+		expected     *time.Time
+	}{
+		{
+			name:     "before midnight",
+			rule:     nightRule,
+			now:      time.Date(2024, 5, 14, 23, 0, 0, 0, time.UTC),
+			expected: timePtr(time.Date(2024, 5, 15, 8, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:     "after midnight",
+			rule:     nightRule,
+			now:      time.Date(2024, 5, 15, 7, 59, 0, 0, time.UTC),
+			expected: timePtr(time.Date(2024, 5, 15, 8, 0, 0, 0, time.UTC)),
+		},
+		{
+			name: "end of the night",
+			rule: nightRule,
+			now:  time.Date(2024, 5, 15, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "window within the day",
+			rule:     lunchRule,
+			now:      time.Date(2024, 5, 15, 13, 0, 0, 0, time.UTC),
+			expected: timePtr(time.Date(2024, 5, 15, 15, 30, 0, 0, time.UTC)),
+		},
+		{
+			name: "outside the window within the day",
+			rule: lunchRule,
+			now:  time.Date(2024, 5, 15, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			// 21:30 in UTC is 23:30 in Madrid.
+			name:         "timezone of the request",
+			rule:         nightRule,
+			now:          time.Date(2024, 5, 14, 21, 30, 0, 0, time.UTC),
+			userTimezone: "Europe/Madrid",
+			expected:     timePtr(time.Date(2024, 5, 15, 8, 0, 0, 0, madrid)),
+		},
+		{
+			name:     "timezone of the user",
+			rule:     nightRule,
+			now:      time.Date(2024, 5, 14, 21, 30, 0, 0, time.UTC),
+			settings: &UserQuietHours{Timezone: "Europe/Madrid"},
+			expected: timePtr(time.Date(2024, 5, 15, 8, 0, 0, 0, madrid)),
+		},
+		{
+			name:         "timezone of the request before the one of the user",
+			rule:         nightRule,
+			now:          time.Date(2024, 5, 14, 21, 30, 0, 0, time.UTC),
+			userTimezone: "UTC",
+			settings:     &UserQuietHours{Timezone: "Europe/Madrid"},
+		},
+		{
+			name:     "override of the user",
+			rule:     nightRule,
+			now:      time.Date(2024, 5, 14, 21, 30, 0, 0, time.UTC),
+			settings: &UserQuietHours{Override: &QuietHours{Start: "21:00", End: "07:00"}},
+			expected: timePtr(time.Date(2024, 5, 15, 7, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:     "disabled by the user",
+			rule:     nightRule,
+			now:      time.Date(2024, 5, 14, 23, 0, 0, 0, time.UTC),
+			settings: &UserQuietHours{Disabled: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			until, err := tc.rule.QuietUntil(tc.now, tc.userTimezone, tc.settings)
+			require.NoError(t, err)
+			if tc.expected == nil {
+				assert.Nil(t, until)
+				return
+			}
+			require.NotNil(t, until)
+			assert.True(t, tc.expected.Equal(*until), "expected %s, got %s", tc.expected, until)
+		})
+	}
+}
+
+func TestQuietHours_Validate(t *testing.T) {
+	assert.NoError(t, (&QuietHours{Start: "22:00", End: "08:00"}).Validate())
+	assert.EqualError(t, (&QuietHours{Start: "10pm", End: "08:00"}).Validate(), "start must be a time of the day as HH:MM")
+	assert.EqualError(t, (&QuietHours{Start: "22:00"}).Validate(), "end must be a time of the day as HH:MM")
+	assert.EqualError(t, (&QuietHours{Start: "22:00", End: "22:00"}).Validate(), "start and end can't be the same time")
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
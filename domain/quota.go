@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// RuleQuota is how many notifications a rule lets a user send. NextSlotAt is when one more
+// notification is allowed, nil when the user has used none.
+type RuleQuota struct {
+	Rule       *RateLimitRule `json:"rule"`
+	Limit      int            `json:"limit"`
+	Used       int            `json:"used"`
+	Remaining  int            `json:"remaining"`
+	NextSlotAt *time.Time     `json:"nextSlotAt,omitempty"`
+}
+
+type Quota struct {
+	UserID           string       `json:"userId"`
+	NotificationType string       `json:"notificationType"`
+	Rules            []*RuleQuota `json:"rules"`
+}
+
+type GetQuotaParams struct {
+	UserID           string
+	NotificationType string
+	Timezone         string
+}
+
+// Quota returns the quota of the rule at now. history has the notifications counted by history
+// rules, and state is the stored state of the other ones, nil if there is none yet.
+func (r *RateLimitRule) Quota(history []*Notification, state *RuleState, now time.Time, userTimezone string) (*RuleQuota, error) {
+	switch r.Algorithm {
+	case AlgorithmTokenBucket:
+		return r.tokenBucketQuota(state, now), nil
+	case AlgorithmGCRA:
+		return r.gcraQuota(state, now), nil
+	case AlgorithmSlidingWindow:
+		return r.slidingWindowQuota(state, now), nil
+	}
+	return r.slidingLogQuota(history, now, userTimezone)
+}
+
+// slidingLogQuota frees a slot when the oldest notification leaves the interval, or all of them when
+// the calendar window ends.
+func (r *RateLimitRule) slidingLogQuota(history []*Notification, now time.Time, userTimezone string) (*RuleQuota, error) {
+	since, err := r.Since(now, userTimezone)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldest *time.Time
+	used := 0
+	for _, notification := range history {
+		if !notification.Timestamp.After(since) {
+			continue
+		}
+		used++
+		if oldest == nil || notification.Timestamp.Before(*oldest) {
+			oldest = &notification.Timestamp
+		}
+	}
+
+	quota := newRuleQuota(r, r.MaxLimit, used)
+	if oldest == nil {
+		return quota, nil
+	}
+	if r.Window == "" {
+		nextSlotAt := oldest.Add(r.TimeInterval.Duration)
+		quota.NextSlotAt = &nextSlotAt
+		return quota, nil
+	}
+	_, windowEnd, err := r.FixedWindow(now, userTimezone)
+	if err != nil {
+		return nil, err
+	}
+	quota.NextSlotAt = &windowEnd
+	return quota, nil
+}
+
+// tokenBucketQuota frees a slot when the bucket earns its next whole token.
+func (r *RateLimitRule) tokenBucketQuota(state *RuleState, now time.Time) *RuleQuota {
+	bucket := r.refilledBucket(state, now)
+	capacity := int(r.Capacity())
+	quota := newRuleQuota(r, capacity, capacity-int(math.Floor(bucket.Tokens)))
+	if quota.Used == 0 || r.TokensPerSecond() <= 0 {
+		return quota
+	}
+
+	missing := math.Floor(bucket.Tokens) + 1 - bucket.Tokens
+	nextSlotAt := now.Add(time.Duration(missing / r.TokensPerSecond() * float64(time.Second)))
+	quota.NextSlotAt = &nextSlotAt
+	return quota
+}
+
+// gcraQuota counts how many emission intervals fit between the theoretical arrival time and the
+// burst tolerance. A slot frees every emission interval.
+func (r *RateLimitRule) gcraQuota(state *RuleState, now time.Time) *RuleQuota {
+	capacity := int(r.Capacity())
+	emission := r.EmissionInterval()
+	if state == nil || !state.TAT.After(now) || emission <= 0 {
+		return newRuleQuota(r, capacity, 0)
+	}
+
+	remaining := 0
+	ahead := state.TAT.Sub(now)
+	if ahead <= r.BurstTolerance() {
+		remaining = int((r.BurstTolerance()-ahead)/emission) + 1
+	}
+	quota := newRuleQuota(r, capacity, capacity-remaining)
+	nextSlotAt := state.TAT.Add(-r.BurstTolerance()).Add(time.Duration(quota.Remaining) * emission)
+	quota.NextSlotAt = &nextSlotAt
+	return quota
+}
+
+// slidingWindowQuota frees a slot when the weight of the previous window, or of the current one
+// once it becomes the previous, decreases enough.
+func (r *RateLimitRule) slidingWindowQuota(state *RuleState, now time.Time) *RuleQuota {
+	if state == nil || r.TimeInterval.Duration <= 0 {
+		return newRuleQuota(r, r.MaxLimit, 0)
+	}
+
+	rolled := *state
+	rolled.rollWindow(r, now)
+	estimated := rolled.EstimatedCount(r, now)
+	remaining := max(int(math.Ceil(float64(r.MaxLimit)-estimated)), 0)
+	quota := newRuleQuota(r, r.MaxLimit, r.MaxLimit-remaining)
+	if quota.Used == 0 {
+		return quota
+	}
+
+	// The next slot frees when the estimated count drops to the used slots.
+	interval := float64(r.TimeInterval.Duration)
+	target := float64(quota.Used)
+	current := float64(rolled.CurrentCount)
+	previous := float64(rolled.PreviousCount)
+	var nextSlotAt time.Time
+	if previous > 0 && target >= current {
+		nextSlotAt = rolled.WindowStart.Add(time.Duration(interval * (1 - (target-current)/previous)))
+	} else {
+		nextWindowStart := rolled.WindowStart.Add(r.TimeInterval.Duration)
+		nextSlotAt = nextWindowStart.Add(time.Duration(interval * (1 - target/current)))
+	}
+	quota.NextSlotAt = &nextSlotAt
+	return quota
+}
+
+func newRuleQuota(rule *RateLimitRule, limit, used int) *RuleQuota {
+	used = min(max(used, 0), limit)
+	return &RuleQuota{
+		Rule:      rule,
+		Limit:     limit,
+		Used:      used,
+		Remaining: limit - used,
+	}
+}
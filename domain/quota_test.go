@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitRule_Quota(t *testing.T) {
+	now := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		t := now.Add(offset)
+		return &t
+	}
+	notifications := func(offsets ...time.Duration) []*Notification {
+		history := []*Notification{}
+		for _, offset := range offsets {
+			history = append(history, &Notification{Timestamp: now.Add(offset)})
+		}
+		return history
+	}
+
+	testCases := []struct {
+		name               string
+		rule               RateLimitRule
+		history            []*Notification
+		state              *RuleState
+		expectedUsed       int
+		expectedRemaining  int
+		expectedNextSlotAt *time.Time
+	}{
+		{
+			name:              "sliding log without notifications",
+			rule:              RateLimitRule{MaxLimit: 2, TimeInterval: Duration{Duration: time.Minute}},
+			expectedRemaining: 2,
+		},
+		{
+			name:               "sliding log frees the oldest notification",
+			rule:               RateLimitRule{MaxLimit: 2, TimeInterval: Duration{Duration: time.Minute}},
+			history:            notifications(-2*time.Minute, -40*time.Second, -10*time.Second),
+			expectedUsed:       2,
+			expectedNextSlotAt: at(20 * time.Second),
+		},
+		{
+			name:               "calendar day frees all the slots at midnight",
+			rule:               RateLimitRule{MaxLimit: 3, Window: WindowDay},
+			history:            notifications(-11*time.Hour, -time.Hour),
+			expectedUsed:       1,
+			expectedRemaining:  2,
+			expectedNextSlotAt: at(14 * time.Hour),
+		},
+		{
+			name:              "token bucket without state",
+			rule:              RateLimitRule{MaxLimit: 1, TimeInterval: Duration{Duration: time.Minute}, Algorithm: AlgorithmTokenBucket, Burst: 3},
+			expectedRemaining: 3,
+		},
+		{
+			name:               "token bucket earns the next token",
+			rule:               RateLimitRule{MaxLimit: 1, TimeInterval: Duration{Duration: time.Minute}, Algorithm: AlgorithmTokenBucket, Burst: 3},
+			state:              &RuleState{Tokens: 0.5, LastRefill: now},
+			expectedUsed:       3,
+			expectedNextSlotAt: at(30 * time.Second),
+		},
+		{
+			name:               "gcra with one slot left",
+			rule:               RateLimitRule{MaxLimit: 2, TimeInterval: Duration{Duration: time.Minute}, Algorithm: AlgorithmGCRA},
+			state:              &RuleState{TAT: now.Add(30 * time.Second)},
+			expectedUsed:       1,
+			expectedRemaining:  1,
+			expectedNextSlotAt: at(30 * time.Second),
+		},
+		{
+			name:               "gcra without slots",
+			rule:               RateLimitRule{MaxLimit: 2, TimeInterval: Duration{Duration: time.Minute}, Algorithm: AlgorithmGCRA},
+			state:              &RuleState{TAT: now.Add(time.Minute)},
+			expectedUsed:       2,
+			expectedNextSlotAt: at(30 * time.Second),
+		},
+		{
+			name:               "sliding window frees a slot as the previous window weighs less",
+			rule:               RateLimitRule{MaxLimit: 4, TimeInterval: Duration{Duration: time.Minute}, Algorithm: AlgorithmSlidingWindow},
+			state:              &RuleState{WindowStart: now, PreviousCount: 4, CurrentCount: 1},
+			expectedUsed:       4,
+			expectedNextSlotAt: at(15 * time.Second),
+		},
+		{
+			name:               "sliding window frees a slot in the next window",
+			rule:               RateLimitRule{MaxLimit: 4, TimeInterval: Duration{Duration: time.Minute}, Algorithm: AlgorithmSlidingWindow},
+			state:              &RuleState{WindowStart: now, CurrentCount: 2},
+			expectedUsed:       2,
+			expectedRemaining:  2,
+			expectedNextSlotAt: at(time.Minute),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			quota, err := tc.rule.Quota(tc.history, tc.state, now, "")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedUsed, quota.Used)
+			assert.Equal(t, tc.expectedRemaining, quota.Remaining)
+			assert.Equal(t, quota.Limit, quota.Used+quota.Remaining)
+			if tc.expectedNextSlotAt == nil {
+				assert.Nil(t, quota.NextSlotAt)
+			} else if assert.NotNil(t, quota.NextSlotAt) {
+				assert.True(t, tc.expectedNextSlotAt.Equal(*quota.NextSlotAt), "next slot at %s", quota.NextSlotAt)
+			}
+		})
+	}
+}
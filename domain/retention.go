@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Retention returns for how long the notifications or the state of the rule are relevant after a
+// notification is sent. Calendar windows are a bit longer than their period to cover DST changes.
+func (r *RateLimitRule) Retention() time.Duration {
+	switch r.Algorithm {
+	case AlgorithmTokenBucket, AlgorithmGCRA:
+		return time.Duration(r.Capacity()) * r.EmissionInterval()
+	case AlgorithmSlidingWindow:
+		return 2 * r.TimeInterval.Duration
+	}
+
+	switch r.Window {
+	case WindowDay:
+		return 25 * time.Hour
+	case WindowWeek:
+		return 7*24*time.Hour + time.Hour
+	case WindowMonth:
+		return 31*24*time.Hour + time.Hour
+	}
+	return r.TimeInterval.Duration
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// ShadowRejections counts the notifications a shadow rule would have rejected if it was enforced.
+type ShadowRejections struct {
+	Rule           *RateLimitRule `json:"rule"`
+	Rejections     int64          `json:"rejections"`
+	LastRejectedAt time.Time      `json:"lastRejectedAt"`
+}
+
+// EnforcedRules returns the rules that can reject notifications.
+func EnforcedRules(rules []*RateLimitRule) []*RateLimitRule {
+	enforced := []*RateLimitRule{}
+	for _, rule := range rules {
+		if !rule.IsShadow() {
+			enforced = append(enforced, rule)
+		}
+	}
+	return enforced
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// EstimatedCount returns the number of notifications sent in the last TimeInterval, assuming the
+// notifications of the previous fixed window were evenly distributed.
+func (s *RuleState) EstimatedCount(rule *RateLimitRule, now time.Time) float64 {
+	interval := rule.TimeInterval.Duration
+	if interval <= 0 {
+		return 0
+	}
+
+	state := *s
+	state.rollWindow(rule, now)
+	previousWeight := 1 - float64(now.Sub(state.WindowStart))/float64(interval)
+	return float64(state.PreviousCount)*previousWeight + float64(state.CurrentCount)
+}
+
+// AllowsSlidingWindow reports whether a notification sent at now conforms to the rule.
+func (s *RuleState) AllowsSlidingWindow(rule *RateLimitRule, now time.Time) bool {
+	return s.EstimatedCount(rule, now) < float64(rule.MaxLimit)
+}
+
+// CountSlidingWindow registers a notification sent at now.
+func (s *RuleState) CountSlidingWindow(rule *RateLimitRule, now time.Time) {
+	s.rollWindow(rule, now)
+	s.CurrentCount++
+}
+
+// rollWindow moves the counters to the fixed window that contains now.
+func (s *RuleState) rollWindow(rule *RateLimitRule, now time.Time) {
+	interval := rule.TimeInterval.Duration
+	windowStart := now.Truncate(interval)
+	if windowStart.Equal(s.WindowStart) {
+		return
+	}
+
+	if windowStart.Equal(s.WindowStart.Add(interval)) {
+		s.PreviousCount = s.CurrentCount
+	} else {
+		s.PreviousCount = 0
+	}
+	s.CurrentCount = 0
+	s.WindowStart = windowStart
+}
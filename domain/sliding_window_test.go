@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// trimBefore drops the timestamps that are not after since, and returns how many are left.
+func trimBefore(timestamps *[]time.Time, since time.Time) int {
+	for len(*timestamps) > 0 && !(*timestamps)[0].After(since) {
+		*timestamps = (*timestamps)[1:]
+	}
+	return len(*timestamps)
+}
+
+func TestRuleState_SlidingWindow_ErrorBound(t *testing.T) {
+	rule := &RateLimitRule{
+		NotificationType: "marketing",
+		MaxLimit:         10,
+		TimeInterval:     Duration{Duration: time.Minute},
+		Algorithm:        AlgorithmSlidingWindow,
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	random := rand.New(rand.NewSource(1))
+
+	state := &RuleState{}
+	exact := []time.Time{}
+	approximated := []time.Time{}
+	exactTotal, approximatedTotal := 0, 0
+	for now := start; now.Before(start.Add(24 * time.Hour)); now = now.Add(time.Duration(random.Int63n(int64(10 * time.Second)))) {
+		windowStart := now.Add(-rule.TimeInterval.Duration)
+		if trimBefore(&exact, windowStart) < rule.MaxLimit {
+			exact = append(exact, now)
+			exactTotal++
+		}
+		if state.AllowsSlidingWindow(rule, now) {
+			state.CountSlidingWindow(rule, now)
+			approximated = append(approximated, now)
+			approximatedTotal++
+		}
+
+		// the approximation never lets through more than twice the limit in any rolling interval
+		assert.LessOrEqual(t, trimBefore(&approximated, windowStart), 2*rule.MaxLimit)
+	}
+
+	// and over the whole period it accepts about as many notifications as the exact sliding log
+	assert.InEpsilon(t, exactTotal, approximatedTotal, 0.1)
+}
+
+func TestRuleState_SlidingWindow_BurstAtWindowBoundary(t *testing.T) {
+	rule := &RateLimitRule{
+		NotificationType: "marketing",
+		MaxLimit:         10,
+		TimeInterval:     Duration{Duration: time.Minute},
+		Algorithm:        AlgorithmSlidingWindow,
+	}
+	windowStart := time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)
+
+	state := &RuleState{}
+	for i := 0; i < rule.MaxLimit; i++ {
+		now := windowStart.Add(-time.Second)
+		assert.True(t, state.AllowsSlidingWindow(rule, now))
+		state.CountSlidingWindow(rule, now)
+	}
+	assert.False(t, state.AllowsSlidingWindow(rule, windowStart.Add(-time.Second)))
+
+	// half way through the next window the previous one is weighted at 50%, so 5 more notifications are
+	// accepted while the exact sliding log would still reject them
+	now := windowStart.Add(30 * time.Second)
+	accepted := 0
+	for state.AllowsSlidingWindow(rule, now) {
+		state.CountSlidingWindow(rule, now)
+		accepted++
+	}
+	assert.Equal(t, 5, accepted)
+	assert.InDelta(t, 10, state.EstimatedCount(rule, now), 0.001)
+
+	// two windows later the counters are reset
+	assert.Zero(t, state.EstimatedCount(rule, windowStart.Add(2*time.Minute)))
+}
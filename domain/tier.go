@@ -0,0 +1,44 @@
+package domain
+
+// TierUnlimited is the tier of the users that no rule limits, such as test accounts.
+const TierUnlimited = "unlimited"
+
+type UserTier struct {
+	UserID string `json:"userId"`
+	Tier   string `json:"tier"`
+}
+
+// TierRules returns the rules of a notification type that apply to the users of the tier: the rules
+// of the tier, or the default rules of the type if the tier has none.
+func TierRules(rules []*RateLimitRule, tier string) []*RateLimitRule {
+	if tier == TierUnlimited {
+		return []*RateLimitRule{}
+	}
+
+	defaultRules := []*RateLimitRule{}
+	tierRules := []*RateLimitRule{}
+	for _, rule := range rules {
+		switch rule.Tier {
+		case "":
+			defaultRules = append(defaultRules, rule)
+		case tier:
+			tierRules = append(tierRules, rule)
+		}
+	}
+	if len(tierRules) > 0 {
+		return tierRules
+	}
+	return defaultRules
+}
+
+// HasTier tells if any of the rules belongs to the tier.
+func HasTier(rules map[string][]*RateLimitRule, tier string) bool {
+	for _, typeRules := range rules {
+		for _, rule := range typeRules {
+			if rule.Tier == tier {
+				return true
+			}
+		}
+	}
+	return false
+}
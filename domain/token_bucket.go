@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// Capacity returns the maximum number of tokens the bucket of the rule can hold.
+func (r *RateLimitRule) Capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.MaxLimit)
+}
+
+// TokensPerSecond returns how fast the bucket of the rule is refilled.
+func (r *RateLimitRule) TokensPerSecond() float64 {
+	if r.RefillRate > 0 {
+		return r.RefillRate
+	}
+	if r.TimeInterval.Duration <= 0 {
+		return 0
+	}
+	return float64(r.MaxLimit) / r.TimeInterval.Seconds()
+}
+
+func NewTokenBucket(rule *RateLimitRule, now time.Time) *RuleState {
+	return &RuleState{
+		Tokens:     rule.Capacity(),
+		LastRefill: now,
+	}
+}
+
+// Refill adds the tokens earned since the last refill, without exceeding the capacity.
+func (s *RuleState) Refill(rule *RateLimitRule, now time.Time) {
+	elapsed := now.Sub(s.LastRefill)
+	if elapsed <= 0 {
+		return
+	}
+	s.Tokens = math.Min(rule.Capacity(), s.Tokens+elapsed.Seconds()*rule.TokensPerSecond())
+	s.LastRefill = now
+}
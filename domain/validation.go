@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"fmt"
+	"rate-limiter/errors"
+)
+
+// Validate returns an errors.ErrInvalidRule error describing the first invalid field of the rule.
+func (r *RateLimitRule) Validate() error {
+	if r.NotificationType == "" {
+		return fmt.Errorf("%w: notificationType is mandatory", errors.ErrInvalidRule)
+	}
+	if r.IsQuietHours() {
+		if err := r.QuietHours.Validate(); err != nil {
+			return fmt.Errorf("%w: quietHours %s", errors.ErrInvalidRule, err)
+		}
+		if r.MaxLimit != 0 || r.TimeInterval.Duration != 0 || r.Algorithm != "" || r.Window != "" || r.Burst != 0 || r.RefillRate != 0 {
+			return fmt.Errorf("%w: quietHours rules can't have limits", errors.ErrInvalidRule)
+		}
+	} else if err := r.validateLimits(); err != nil {
+		return err
+	}
+
+	if r.Timezone != "" {
+		if _, err := LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone '%s'", errors.ErrInvalidRule, r.Timezone)
+		}
+	}
+	switch r.Mode {
+	case "", ModeEnforce, ModeShadow:
+	default:
+		return fmt.Errorf("%w: unknown mode '%s'", errors.ErrInvalidRule, r.Mode)
+	}
+	switch r.OnExceed {
+	case "", OnExceedReject, OnExceedDelay, OnExceedDropSilently, OnExceedDigest:
+	default:
+		return fmt.Errorf("%w: unknown onExceed '%s'", errors.ErrInvalidRule, r.OnExceed)
+	}
+	if r.IsGroup() {
+		if len(r.NotificationTypes) == 0 {
+			return fmt.Errorf("%w: notificationTypes is mandatory in group rules", errors.ErrInvalidRule)
+		}
+		for _, notificationType := range r.NotificationTypes {
+			if notificationType == "" || notificationType == NotificationTypeAll || IsGroupNotificationType(notificationType) {
+				return fmt.Errorf("%w: invalid notification type '%s' in group '%s'", errors.ErrInvalidRule, notificationType, r.Group)
+			}
+		}
+	} else if len(r.NotificationTypes) > 0 {
+		return fmt.Errorf("%w: notificationTypes is only supported by group rules", errors.ErrInvalidRule)
+	}
+	if r.Tier == TierUnlimited {
+		return fmt.Errorf("%w: the %s tier can't have rules", errors.ErrInvalidRule, TierUnlimited)
+	}
+	return nil
+}
+
+// validateLimits validates the fields of the rules that limit how many notifications are sent.
+func (r *RateLimitRule) validateLimits() error {
+	if r.MaxLimit <= 0 {
+		return fmt.Errorf("%w: maxLimit must be positive", errors.ErrInvalidRule)
+	}
+
+	switch r.Algorithm {
+	case "", AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmGCRA, AlgorithmSlidingWindow:
+	default:
+		return fmt.Errorf("%w: unknown algorithm '%s'", errors.ErrInvalidRule, r.Algorithm)
+	}
+
+	switch r.Window {
+	case "":
+		if r.TimeInterval.Duration <= 0 {
+			return fmt.Errorf("%w: timeInterval must be positive", errors.ErrInvalidRule)
+		}
+	case WindowDay, WindowWeek, WindowMonth:
+		if !r.UsesHistory() {
+			return fmt.Errorf("%w: window is only supported by %s rules", errors.ErrInvalidRule, AlgorithmSlidingLog)
+		}
+		if r.TimeInterval.Duration != 0 {
+			return fmt.Errorf("%w: timeInterval can't be set with a calendar window", errors.ErrInvalidRule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown window '%s'", errors.ErrInvalidRule, r.Window)
+	}
+
+	if r.Burst < 0 {
+		return fmt.Errorf("%w: burst can't be negative", errors.ErrInvalidRule)
+	}
+	if r.RefillRate < 0 {
+		return fmt.Errorf("%w: refillRate can't be negative", errors.ErrInvalidRule)
+	}
+	return nil
+}
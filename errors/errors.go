@@ -6,6 +6,8 @@ type ApiError struct {
 	Message  string `json:"message"`
 	ErrorStr string `json:"error"`
 	Status   int    `json:"status"`
+	// Decision is the decision of the rate limit rules, when they rejected the request.
+	Decision interface{} `json:"decision,omitempty"`
 }
 
 func (e ApiError) Error() string {
@@ -14,7 +16,43 @@ func (e ApiError) Error() string {
 
 var ErrRateLimitExceeded = errors.New("rate limit exceeded")
 var ErrGetRateLimitRule = errors.New("error getting rate limit rule for notification type")
+var ErrInvalidRule = errors.New("invalid rule")
+var ErrRuleNotFound = errors.New("rule not found")
+var ErrNotSupported = errors.New("operation not supported by the storage")
+var ErrInvalidTier = errors.New("invalid tier")
+var ErrUserTierNotFound = errors.New("user tier not found")
+var ErrQuietHours = errors.New("notification type is in quiet hours")
+var ErrInvalidQuietHours = errors.New("invalid quiet hours")
+var ErrUserQuietHoursNotFound = errors.New("user quiet hours not found")
 
 func IsTooManyRequestsError(err error) bool {
 	return errors.Is(err, ErrRateLimitExceeded)
 }
+
+func IsInvalidRuleError(err error) bool {
+	return errors.Is(err, ErrInvalidRule)
+}
+
+func IsNotFoundError(err error) bool {
+	return errors.Is(err, ErrRuleNotFound)
+}
+
+func IsNotSupportedError(err error) bool {
+	return errors.Is(err, ErrNotSupported)
+}
+
+func IsInvalidTierError(err error) bool {
+	return errors.Is(err, ErrInvalidTier)
+}
+
+func IsUserTierNotFoundError(err error) bool {
+	return errors.Is(err, ErrUserTierNotFound)
+}
+
+func IsInvalidQuietHoursError(err error) bool {
+	return errors.Is(err, ErrInvalidQuietHours)
+}
+
+func IsUserQuietHoursNotFoundError(err error) bool {
+	return errors.Is(err, ErrUserQuietHoursNotFound)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"rate-limiter/server"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -13,6 +14,12 @@ func main() {
 		fmt.Println("Fixed port to 5000")
 	}
 
+	router, err := server.New()
+	if err != nil {
+		fmt.Println("Error starting the application:", err)
+		os.Exit(1)
+	}
+
 	fmt.Println("Listening port: " + port)
-	server.New().Run(":" + port)
+	router.Run(":" + port)
 }
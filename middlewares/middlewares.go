@@ -1,7 +1,15 @@
 package middlewares
 
-import "github.com/gin-gonic/gin"
+import (
+	stderrors "errors"
+	"rate-limiter/errors"
 
+	"github.com/gin-gonic/gin"
+)
+
+// AdaptHandler runs the validation as a gin handler, and stops the request when it fails. An
+// errors.ApiError is answered with its status and the error as json, and any other error aborts the
+// request without a body.
 func AdaptHandler(handler func(c *gin.Context) error) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -9,6 +17,11 @@ func AdaptHandler(handler func(c *gin.Context) error) gin.HandlerFunc {
 
 		if err != nil {
 			c.Error(err)
+			var apiErr *errors.ApiError
+			if stderrors.As(err, &apiErr) {
+				c.AbortWithStatusJSON(apiErr.Status, apiErr)
+				return
+			}
 			c.Abort()
 		}
 	}
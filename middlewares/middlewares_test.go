@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"rate-limiter/controllers"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptHandler(t *testing.T) {
+	testCases := []struct {
+		name             string
+		path             string
+		expectedCode     int
+		expectedResponse string
+	}{
+		{
+			name:             "valid request",
+			path:             "/notifications/news/users/user1?timezone=Europe/Madrid",
+			expectedCode:     http.StatusOK,
+			expectedResponse: "sent",
+		},
+		{
+			name:             "reserved notification type",
+			path:             "/notifications/*/users/user1",
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: `{"message":"notification type is reserved for the global and group rules","error":"invalid_type","status":400}`,
+		},
+		{
+			name:             "invalid timezone",
+			path:             "/notifications/news/users/user1?timezone=Invalid/Timezone",
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: `{"message":"timezone is not a valid IANA timezone","error":"invalid_timezone","status":400}`,
+		},
+		{
+			name:         "error that is not an api error",
+			path:         "/notifications/news/users/blocked",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	controller := controllers.NotificationController{}
+	router := gin.New()
+	router.POST("/notifications/:type/users/:user_id",
+		AdaptHandler(controller.ValidateNotificationType),
+		AdaptHandler(controller.ValidateUserID),
+		AdaptHandler(controller.ValidateTimezone),
+		AdaptHandler(func(c *gin.Context) error {
+			if c.GetString("userID") == "blocked" {
+				return fmt.Errorf("blocked user")
+			}
+			return nil
+		}),
+		func(c *gin.Context) {
+			c.String(http.StatusOK, "sent")
+		})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tc.path, nil))
+
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			assert.Equal(t, tc.expectedResponse, recorder.Body.String())
+		})
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func bootstrap(router *gin.Engine) {
+func bootstrap(router *gin.Engine) error {
 	fmt.Println("Bootstrap - Starting app...")
 
-	application := resolveNotificationController()
+	application, err := resolveApplication()
+	if err != nil {
+		return err
+	}
 	mapUrlsToControllers(router, application)
 
 	fmt.Println("Bootstrap - Application is up")
+	return nil
 }
@@ -6,14 +6,42 @@ import (
 	"rate-limiter/services"
 )
 
-func resolveNotificationController() *controllers.NotificationController {
-	controller := &controllers.NotificationController{
-		RateLimitService: services.NewRateLimitService(
-			dao.NewNotificationContainer(),
-			services.NewRulesService(
-				dao.NewRulesContainer(),
-			),
-		),
+type application struct {
+	notificationController *controllers.NotificationController
+	rulesController        *controllers.RulesController
+}
+
+func resolveApplication() (*application, error) {
+	rulesContainer, err := dao.NewRulesContainer()
+	if err != nil {
+		return nil, err
 	}
-	return controller
+	rulesService := services.NewRulesService(rulesContainer)
+
+	notificationsContainer, err := dao.NewNotificationContainer(rulesService.GetRules)
+	if err != nil {
+		return nil, err
+	}
+
+	delayedContainer, err := dao.NewDelayedNotificationsContainer()
+	if err != nil {
+		return nil, err
+	}
+	digestsContainer, err := dao.NewDigestsContainer()
+	if err != nil {
+		return nil, err
+	}
+	rateLimitService := services.NewRateLimitService(notificationsContainer, rulesService, delayedContainer, digestsContainer)
+	if err := dao.StartScheduler(delayedContainer, digestsContainer, rateLimitService); err != nil {
+		return nil, err
+	}
+
+	return &application{
+		notificationController: &controllers.NotificationController{
+			RateLimitService: rateLimitService,
+		},
+		rulesController: &controllers.RulesController{
+			RulesService: rulesService,
+		},
+	}, nil
 }
@@ -2,12 +2,14 @@ package server
 
 import "github.com/gin-gonic/gin"
 
-func New() *gin.Engine {
+func New() (*gin.Engine, error) {
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
-	bootstrap(router)
+	if err := bootstrap(router); err != nil {
+		return nil, err
+	}
 
-	return router
+	return router, nil
 }
@@ -1,17 +1,41 @@
 package server
 
 import (
-	"rate-limiter/controllers"
 	"rate-limiter/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
-func mapUrlsToControllers(router *gin.Engine, notificationController *controllers.NotificationController) {
+func mapUrlsToControllers(router *gin.Engine, application *application) {
+	notificationController := application.notificationController
+	rulesController := application.rulesController
 
 	router.GET("/ping", notificationController.Pong)
 	router.POST("notifications/:type/users/:user_id",
 		middlewares.AdaptHandler(notificationController.ValidateNotificationType),
 		middlewares.AdaptHandler(notificationController.ValidateUserID),
+		middlewares.AdaptHandler(notificationController.ValidateTimezone),
+		middlewares.AdaptHandler(notificationController.ValidatePayload),
 		notificationController.SendNotification)
+	router.GET("notifications/:type/users/:user_id/quota",
+		middlewares.AdaptHandler(notificationController.ValidateNotificationType),
+		middlewares.AdaptHandler(notificationController.ValidateUserID),
+		middlewares.AdaptHandler(notificationController.ValidateTimezone),
+		notificationController.GetQuota)
+
+	router.GET("/rules", rulesController.GetRules)
+	router.POST("/rules", rulesController.CreateRule)
+	router.GET("/rules/:type", rulesController.GetRulesByType)
+	router.PUT("/rules/:type", rulesController.UpdateRulesByType)
+	router.DELETE("/rules/:type", rulesController.DeleteRulesByType)
+
+	router.GET("/admin/shadow-rejections", notificationController.GetShadowRejections)
+	router.GET("/admin/delayed-notifications", notificationController.GetDelayedNotifications)
+	router.GET("/admin/digests", notificationController.GetDigests)
+	router.GET("/admin/users/:user_id/tier", rulesController.GetUserTier)
+	router.PUT("/admin/users/:user_id/tier", rulesController.SetUserTier)
+	router.DELETE("/admin/users/:user_id/tier", rulesController.DeleteUserTier)
+	router.GET("/admin/users/:user_id/quiet-hours", rulesController.GetUserQuietHours)
+	router.PUT("/admin/users/:user_id/quiet-hours", rulesController.SetUserQuietHours)
+	router.DELETE("/admin/users/:user_id/quiet-hours", rulesController.DeleteUserQuietHours)
 }
@@ -0,0 +1,81 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package services
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Ensure, that CommunicationClientMock does implement CommunicationClient.
+// If this is not the case, regenerate this file with moq.
+var _ CommunicationClient = &CommunicationClientMock{}
+
+// CommunicationClientMock is a mock implementation of CommunicationClient.
+//
+//	func TestSomethingThatUsesCommunicationClient(t *testing.T) {
+//
+//		// make and configure a mocked CommunicationClient
+//		mockedCommunicationClient := &CommunicationClientMock{
+//			SendFunc: func(userID string, payload json.RawMessage) error {
+//				panic("mock out the Send method")
+//			},
+//		}
+//
+//		// use mockedCommunicationClient in code that requires CommunicationClient
+//		// and then make assertions.
+//
+//	}
+type CommunicationClientMock struct {
+	// SendFunc mocks the Send method.
+	SendFunc func(userID string, payload json.RawMessage) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Send holds details about calls to the Send method.
+		Send []struct {
+			// UserID is the userID argument value.
+			UserID string
+			// Payload is the payload argument value.
+			Payload json.RawMessage
+		}
+	}
+	lockSend sync.RWMutex
+}
+
+// Send calls SendFunc.
+func (mock *CommunicationClientMock) Send(userID string, payload json.RawMessage) error {
+	if mock.SendFunc == nil {
+		panic("CommunicationClientMock.SendFunc: method is nil but CommunicationClient.Send was just called")
+	}
+	callInfo := struct {
+		UserID  string
+		Payload json.RawMessage
+	}{
+		UserID:  userID,
+		Payload: payload,
+	}
+	mock.lockSend.Lock()
+	mock.calls.Send = append(mock.calls.Send, callInfo)
+	mock.lockSend.Unlock()
+	return mock.SendFunc(userID, payload)
+}
+
+// SendCalls gets all the calls that were made to Send.
+// Check the length with:
+//
+//	len(mockedCommunicationClient.SendCalls())
+func (mock *CommunicationClientMock) SendCalls() []struct {
+	UserID  string
+	Payload json.RawMessage
+} {
+	var calls []struct {
+		UserID  string
+		Payload json.RawMessage
+	}
+	mock.lockSend.RLock()
+	calls = mock.calls.Send
+	mock.lockSend.RUnlock()
+	return calls
+}
@@ -0,0 +1,163 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package services
+
+import (
+	"rate-limiter/domain"
+	"sync"
+	"time"
+)
+
+// Ensure, that DelayedNotificationsContainerMock does implement DelayedNotificationsContainer.
+// If this is not the case, regenerate this file with moq.
+var _ DelayedNotificationsContainer = &DelayedNotificationsContainerMock{}
+
+// DelayedNotificationsContainerMock is a mock implementation of DelayedNotificationsContainer.
+//
+//	func TestSomethingThatUsesDelayedNotificationsContainer(t *testing.T) {
+//
+//		// make and configure a mocked DelayedNotificationsContainer
+//		mockedDelayedNotificationsContainer := &DelayedNotificationsContainerMock{
+//			AddDelayedNotificationFunc: func(notification *domain.DelayedNotification) error {
+//				panic("mock out the AddDelayedNotification method")
+//			},
+//			GetDelayedNotificationsFunc: func() ([]*domain.DelayedNotification, error) {
+//				panic("mock out the GetDelayedNotifications method")
+//			},
+//			PopDueNotificationsFunc: func(now time.Time, limit int) ([]*domain.DelayedNotification, error) {
+//				panic("mock out the PopDueNotifications method")
+//			},
+//		}
+//
+//		// use mockedDelayedNotificationsContainer in code that requires DelayedNotificationsContainer
+//		// and then make assertions.
+//
+//	}
+type DelayedNotificationsContainerMock struct {
+	// AddDelayedNotificationFunc mocks the AddDelayedNotification method.
+	AddDelayedNotificationFunc func(notification *domain.DelayedNotification) error
+
+	// GetDelayedNotificationsFunc mocks the GetDelayedNotifications method.
+	GetDelayedNotificationsFunc func() ([]*domain.DelayedNotification, error)
+
+	// PopDueNotificationsFunc mocks the PopDueNotifications method.
+	PopDueNotificationsFunc func(now time.Time, limit int) ([]*domain.DelayedNotification, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AddDelayedNotification holds details about calls to the AddDelayedNotification method.
+		AddDelayedNotification []struct {
+			// Notification is the notification argument value.
+			Notification *domain.DelayedNotification
+		}
+		// GetDelayedNotifications holds details about calls to the GetDelayedNotifications method.
+		GetDelayedNotifications []struct {
+		}
+		// PopDueNotifications holds details about calls to the PopDueNotifications method.
+		PopDueNotifications []struct {
+			// Now is the now argument value.
+			Now time.Time
+			// Limit is the limit argument value.
+			Limit int
+		}
+	}
+	lockAddDelayedNotification  sync.RWMutex
+	lockGetDelayedNotifications sync.RWMutex
+	lockPopDueNotifications     sync.RWMutex
+}
+
+// AddDelayedNotification calls AddDelayedNotificationFunc.
+func (mock *DelayedNotificationsContainerMock) AddDelayedNotification(notification *domain.DelayedNotification) error {
+	if mock.AddDelayedNotificationFunc == nil {
+		panic("DelayedNotificationsContainerMock.AddDelayedNotificationFunc: method is nil but DelayedNotificationsContainer.AddDelayedNotification was just called")
+	}
+	callInfo := struct {
+		Notification *domain.DelayedNotification
+	}{
+		Notification: notification,
+	}
+	mock.lockAddDelayedNotification.Lock()
+	mock.calls.AddDelayedNotification = append(mock.calls.AddDelayedNotification, callInfo)
+	mock.lockAddDelayedNotification.Unlock()
+	return mock.AddDelayedNotificationFunc(notification)
+}
+
+// AddDelayedNotificationCalls gets all the calls that were made to AddDelayedNotification.
+// Check the length with:
+//
+//	len(mockedDelayedNotificationsContainer.AddDelayedNotificationCalls())
+func (mock *DelayedNotificationsContainerMock) AddDelayedNotificationCalls() []struct {
+	Notification *domain.DelayedNotification
+} {
+	var calls []struct {
+		Notification *domain.DelayedNotification
+	}
+	mock.lockAddDelayedNotification.RLock()
+	calls = mock.calls.AddDelayedNotification
+	mock.lockAddDelayedNotification.RUnlock()
+	return calls
+}
+
+// GetDelayedNotifications calls GetDelayedNotificationsFunc.
+func (mock *DelayedNotificationsContainerMock) GetDelayedNotifications() ([]*domain.DelayedNotification, error) {
+	if mock.GetDelayedNotificationsFunc == nil {
+		panic("DelayedNotificationsContainerMock.GetDelayedNotificationsFunc: method is nil but DelayedNotificationsContainer.GetDelayedNotifications was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetDelayedNotifications.Lock()
+	mock.calls.GetDelayedNotifications = append(mock.calls.GetDelayedNotifications, callInfo)
+	mock.lockGetDelayedNotifications.Unlock()
+	return mock.GetDelayedNotificationsFunc()
+}
+
+// GetDelayedNotificationsCalls gets all the calls that were made to GetDelayedNotifications.
+// Check the length with:
+//
+//	len(mockedDelayedNotificationsContainer.GetDelayedNotificationsCalls())
+func (mock *DelayedNotificationsContainerMock) GetDelayedNotificationsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetDelayedNotifications.RLock()
+	calls = mock.calls.GetDelayedNotifications
+	mock.lockGetDelayedNotifications.RUnlock()
+	return calls
+}
+
+// PopDueNotifications calls PopDueNotificationsFunc.
+func (mock *DelayedNotificationsContainerMock) PopDueNotifications(now time.Time, limit int) ([]*domain.DelayedNotification, error) {
+	if mock.PopDueNotificationsFunc == nil {
+		panic("DelayedNotificationsContainerMock.PopDueNotificationsFunc: method is nil but DelayedNotificationsContainer.PopDueNotifications was just called")
+	}
+	callInfo := struct {
+		Now   time.Time
+		Limit int
+	}{
+		Now:   now,
+		Limit: limit,
+	}
+	mock.lockPopDueNotifications.Lock()
+	mock.calls.PopDueNotifications = append(mock.calls.PopDueNotifications, callInfo)
+	mock.lockPopDueNotifications.Unlock()
+	return mock.PopDueNotificationsFunc(now, limit)
+}
+
+// PopDueNotificationsCalls gets all the calls that were made to PopDueNotifications.
+// Check the length with:
+//
+//	len(mockedDelayedNotificationsContainer.PopDueNotificationsCalls())
+func (mock *DelayedNotificationsContainerMock) PopDueNotificationsCalls() []struct {
+	Now   time.Time
+	Limit int
+} {
+	var calls []struct {
+		Now   time.Time
+		Limit int
+	}
+	mock.lockPopDueNotifications.RLock()
+	calls = mock.calls.PopDueNotifications
+	mock.lockPopDueNotifications.RUnlock()
+	return calls
+}
@@ -0,0 +1,163 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package services
+
+import (
+	"rate-limiter/domain"
+	"sync"
+	"time"
+)
+
+// Ensure, that DigestsContainerMock does implement DigestsContainer.
+// If this is not the case, regenerate this file with moq.
+var _ DigestsContainer = &DigestsContainerMock{}
+
+// DigestsContainerMock is a mock implementation of DigestsContainer.
+//
+//	func TestSomethingThatUsesDigestsContainer(t *testing.T) {
+//
+//		// make and configure a mocked DigestsContainer
+//		mockedDigestsContainer := &DigestsContainerMock{
+//			AddToDigestFunc: func(digest *domain.Digest) (*domain.Digest, error) {
+//				panic("mock out the AddToDigest method")
+//			},
+//			GetDigestsFunc: func() ([]*domain.Digest, error) {
+//				panic("mock out the GetDigests method")
+//			},
+//			PopDueDigestsFunc: func(now time.Time, limit int) ([]*domain.Digest, error) {
+//				panic("mock out the PopDueDigests method")
+//			},
+//		}
+//
+//		// use mockedDigestsContainer in code that requires DigestsContainer
+//		// and then make assertions.
+//
+//	}
+type DigestsContainerMock struct {
+	// AddToDigestFunc mocks the AddToDigest method.
+	AddToDigestFunc func(digest *domain.Digest) (*domain.Digest, error)
+
+	// GetDigestsFunc mocks the GetDigests method.
+	GetDigestsFunc func() ([]*domain.Digest, error)
+
+	// PopDueDigestsFunc mocks the PopDueDigests method.
+	PopDueDigestsFunc func(now time.Time, limit int) ([]*domain.Digest, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AddToDigest holds details about calls to the AddToDigest method.
+		AddToDigest []struct {
+			// Digest is the digest argument value.
+			Digest *domain.Digest
+		}
+		// GetDigests holds details about calls to the GetDigests method.
+		GetDigests []struct {
+		}
+		// PopDueDigests holds details about calls to the PopDueDigests method.
+		PopDueDigests []struct {
+			// Now is the now argument value.
+			Now time.Time
+			// Limit is the limit argument value.
+			Limit int
+		}
+	}
+	lockAddToDigest   sync.RWMutex
+	lockGetDigests    sync.RWMutex
+	lockPopDueDigests sync.RWMutex
+}
+
+// AddToDigest calls AddToDigestFunc.
+func (mock *DigestsContainerMock) AddToDigest(digest *domain.Digest) (*domain.Digest, error) {
+	if mock.AddToDigestFunc == nil {
+		panic("DigestsContainerMock.AddToDigestFunc: method is nil but DigestsContainer.AddToDigest was just called")
+	}
+	callInfo := struct {
+		Digest *domain.Digest
+	}{
+		Digest: digest,
+	}
+	mock.lockAddToDigest.Lock()
+	mock.calls.AddToDigest = append(mock.calls.AddToDigest, callInfo)
+	mock.lockAddToDigest.Unlock()
+	return mock.AddToDigestFunc(digest)
+}
+
+// AddToDigestCalls gets all the calls that were made to AddToDigest.
+// Check the length with:
+//
+//	len(mockedDigestsContainer.AddToDigestCalls())
+func (mock *DigestsContainerMock) AddToDigestCalls() []struct {
+	Digest *domain.Digest
+} {
+	var calls []struct {
+		Digest *domain.Digest
+	}
+	mock.lockAddToDigest.RLock()
+	calls = mock.calls.AddToDigest
+	mock.lockAddToDigest.RUnlock()
+	return calls
+}
+
+// GetDigests calls GetDigestsFunc.
+func (mock *DigestsContainerMock) GetDigests() ([]*domain.Digest, error) {
+	if mock.GetDigestsFunc == nil {
+		panic("DigestsContainerMock.GetDigestsFunc: method is nil but DigestsContainer.GetDigests was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetDigests.Lock()
+	mock.calls.GetDigests = append(mock.calls.GetDigests, callInfo)
+	mock.lockGetDigests.Unlock()
+	return mock.GetDigestsFunc()
+}
+
+// GetDigestsCalls gets all the calls that were made to GetDigests.
+// Check the length with:
+//
+//	len(mockedDigestsContainer.GetDigestsCalls())
+func (mock *DigestsContainerMock) GetDigestsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetDigests.RLock()
+	calls = mock.calls.GetDigests
+	mock.lockGetDigests.RUnlock()
+	return calls
+}
+
+// PopDueDigests calls PopDueDigestsFunc.
+func (mock *DigestsContainerMock) PopDueDigests(now time.Time, limit int) ([]*domain.Digest, error) {
+	if mock.PopDueDigestsFunc == nil {
+		panic("DigestsContainerMock.PopDueDigestsFunc: method is nil but DigestsContainer.PopDueDigests was just called")
+	}
+	callInfo := struct {
+		Now   time.Time
+		Limit int
+	}{
+		Now:   now,
+		Limit: limit,
+	}
+	mock.lockPopDueDigests.Lock()
+	mock.calls.PopDueDigests = append(mock.calls.PopDueDigests, callInfo)
+	mock.lockPopDueDigests.Unlock()
+	return mock.PopDueDigestsFunc(now, limit)
+}
+
+// PopDueDigestsCalls gets all the calls that were made to PopDueDigests.
+// Check the length with:
+//
+//	len(mockedDigestsContainer.PopDueDigestsCalls())
+func (mock *DigestsContainerMock) PopDueDigestsCalls() []struct {
+	Now   time.Time
+	Limit int
+} {
+	var calls []struct {
+		Now   time.Time
+		Limit int
+	}
+	mock.lockPopDueDigests.RLock()
+	calls = mock.calls.PopDueDigests
+	mock.lockPopDueDigests.RUnlock()
+	return calls
+}
@@ -18,12 +18,15 @@ var _ NotificationsContainer = &NotificationsContainerMock{}
 //
 //		// make and configure a mocked NotificationsContainer
 //		mockedNotificationsContainer := &NotificationsContainerMock{
-//			AddNotificationFunc: func(userID string, notificationType string) error {
-//				panic("mock out the AddNotification method")
+//			AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+//				panic("mock out the AddNotificationIfAllowed method")
 //			},
 //			GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
 //				panic("mock out the GetNotificationsByUser method")
 //			},
+//			GetRuleStateFunc: func(params domain.RuleStateParams) (*domain.RuleState, error) {
+//				panic("mock out the GetRuleState method")
+//			},
 //		}
 //
 //		// use mockedNotificationsContainer in code that requires NotificationsContainer
@@ -31,64 +34,67 @@ var _ NotificationsContainer = &NotificationsContainerMock{}
 //
 //	}
 type NotificationsContainerMock struct {
-	// AddNotificationFunc mocks the AddNotification method.
-	AddNotificationFunc func(userID string, notificationType string) error
+	// AddNotificationIfAllowedFunc mocks the AddNotificationIfAllowed method.
+	AddNotificationIfAllowedFunc func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error)
 
 	// GetNotificationsByUserFunc mocks the GetNotificationsByUser method.
 	GetNotificationsByUserFunc func(params domain.GetNotificationParams) ([]*domain.Notification, error)
 
+	// GetRuleStateFunc mocks the GetRuleState method.
+	GetRuleStateFunc func(params domain.RuleStateParams) (*domain.RuleState, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
-		// AddNotification holds details about calls to the AddNotification method.
-		AddNotification []struct {
-			// UserID is the userID argument value.
-			UserID string
-			// NotificationType is the notificationType argument value.
-			NotificationType string
+		// AddNotificationIfAllowed holds details about calls to the AddNotificationIfAllowed method.
+		AddNotificationIfAllowed []struct {
+			// Params is the params argument value.
+			Params domain.AddNotificationParams
 		}
 		// GetNotificationsByUser holds details about calls to the GetNotificationsByUser method.
 		GetNotificationsByUser []struct {
 			// Params is the params argument value.
 			Params domain.GetNotificationParams
 		}
+		// GetRuleState holds details about calls to the GetRuleState method.
+		GetRuleState []struct {
+			// Params is the params argument value.
+			Params domain.RuleStateParams
+		}
 	}
-	lockAddNotification        sync.RWMutex
-	lockGetNotificationsByUser sync.RWMutex
+	lockAddNotificationIfAllowed sync.RWMutex
+	lockGetNotificationsByUser   sync.RWMutex
+	lockGetRuleState             sync.RWMutex
 }
 
-// AddNotification calls AddNotificationFunc.
-func (mock *NotificationsContainerMock) AddNotification(userID string, notificationType string) error {
-	if mock.AddNotificationFunc == nil {
-		panic("NotificationsContainerMock.AddNotificationFunc: method is nil but NotificationsContainer.AddNotification was just called")
+// AddNotificationIfAllowed calls AddNotificationIfAllowedFunc.
+func (mock *NotificationsContainerMock) AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+	if mock.AddNotificationIfAllowedFunc == nil {
+		panic("NotificationsContainerMock.AddNotificationIfAllowedFunc: method is nil but NotificationsContainer.AddNotificationIfAllowed was just called")
 	}
 	callInfo := struct {
-		UserID           string
-		NotificationType string
+		Params domain.AddNotificationParams
 	}{
-		UserID:           userID,
-		NotificationType: notificationType,
+		Params: params,
 	}
-	mock.lockAddNotification.Lock()
-	mock.calls.AddNotification = append(mock.calls.AddNotification, callInfo)
-	mock.lockAddNotification.Unlock()
-	return mock.AddNotificationFunc(userID, notificationType)
+	mock.lockAddNotificationIfAllowed.Lock()
+	mock.calls.AddNotificationIfAllowed = append(mock.calls.AddNotificationIfAllowed, callInfo)
+	mock.lockAddNotificationIfAllowed.Unlock()
+	return mock.AddNotificationIfAllowedFunc(params)
 }
 
-// AddNotificationCalls gets all the calls that were made to AddNotification.
+// AddNotificationIfAllowedCalls gets all the calls that were made to AddNotificationIfAllowed.
 // Check the length with:
 //
-//	len(mockedNotificationsContainer.AddNotificationCalls())
-func (mock *NotificationsContainerMock) AddNotificationCalls() []struct {
-	UserID           string
-	NotificationType string
+//	len(mockedNotificationsContainer.AddNotificationIfAllowedCalls())
+func (mock *NotificationsContainerMock) AddNotificationIfAllowedCalls() []struct {
+	Params domain.AddNotificationParams
 } {
 	var calls []struct {
-		UserID           string
-		NotificationType string
+		Params domain.AddNotificationParams
 	}
-	mock.lockAddNotification.RLock()
-	calls = mock.calls.AddNotification
-	mock.lockAddNotification.RUnlock()
+	mock.lockAddNotificationIfAllowed.RLock()
+	calls = mock.calls.AddNotificationIfAllowed
+	mock.lockAddNotificationIfAllowed.RUnlock()
 	return calls
 }
 
@@ -123,3 +129,35 @@ func (mock *NotificationsContainerMock) GetNotificationsByUserCalls() []struct {
 	mock.lockGetNotificationsByUser.RUnlock()
 	return calls
 }
+
+// GetRuleState calls GetRuleStateFunc.
+func (mock *NotificationsContainerMock) GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error) {
+	if mock.GetRuleStateFunc == nil {
+		panic("NotificationsContainerMock.GetRuleStateFunc: method is nil but NotificationsContainer.GetRuleState was just called")
+	}
+	callInfo := struct {
+		Params domain.RuleStateParams
+	}{
+		Params: params,
+	}
+	mock.lockGetRuleState.Lock()
+	mock.calls.GetRuleState = append(mock.calls.GetRuleState, callInfo)
+	mock.lockGetRuleState.Unlock()
+	return mock.GetRuleStateFunc(params)
+}
+
+// GetRuleStateCalls gets all the calls that were made to GetRuleState.
+// Check the length with:
+//
+//	len(mockedNotificationsContainer.GetRuleStateCalls())
+func (mock *NotificationsContainerMock) GetRuleStateCalls() []struct {
+	Params domain.RuleStateParams
+} {
+	var calls []struct {
+		Params domain.RuleStateParams
+	}
+	mock.lockGetRuleState.RLock()
+	calls = mock.calls.GetRuleState
+	mock.lockGetRuleState.RUnlock()
+	return calls
+}
@@ -18,12 +18,39 @@ var _ RulesContainer = &RulesContainerMock{}
 //
 //		// make and configure a mocked RulesContainer
 //		mockedRulesContainer := &RulesContainerMock{
+//			AddRuleFunc: func(rule *domain.RateLimitRule) error {
+//				panic("mock out the AddRule method")
+//			},
+//			DeleteRulesByTypeFunc: func(notificationType string) error {
+//				panic("mock out the DeleteRulesByType method")
+//			},
+//			DeleteUserQuietHoursFunc: func(userID string) error {
+//				panic("mock out the DeleteUserQuietHours method")
+//			},
+//			DeleteUserTierFunc: func(userID string) error {
+//				panic("mock out the DeleteUserTier method")
+//			},
 //			GetRuleByTypeFunc: func(s string) ([]*domain.RateLimitRule, error) {
 //				panic("mock out the GetRuleByType method")
 //			},
 //			GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
 //				panic("mock out the GetRules method")
 //			},
+//			GetUserQuietHoursFunc: func(userID string) (*domain.UserQuietHours, error) {
+//				panic("mock out the GetUserQuietHours method")
+//			},
+//			GetUserTierFunc: func(userID string) (string, error) {
+//				panic("mock out the GetUserTier method")
+//			},
+//			SetUserQuietHoursFunc: func(userQuietHours *domain.UserQuietHours) error {
+//				panic("mock out the SetUserQuietHours method")
+//			},
+//			SetUserTierFunc: func(userID string, tier string) error {
+//				panic("mock out the SetUserTier method")
+//			},
+//			UpdateRulesByTypeFunc: func(notificationType string, rules []*domain.RateLimitRule) error {
+//				panic("mock out the UpdateRulesByType method")
+//			},
 //		}
 //
 //		// use mockedRulesContainer in code that requires RulesContainer
@@ -31,14 +58,61 @@ var _ RulesContainer = &RulesContainerMock{}
 //
 //	}
 type RulesContainerMock struct {
+	// AddRuleFunc mocks the AddRule method.
+	AddRuleFunc func(rule *domain.RateLimitRule) error
+
+	// DeleteRulesByTypeFunc mocks the DeleteRulesByType method.
+	DeleteRulesByTypeFunc func(notificationType string) error
+
+	// DeleteUserQuietHoursFunc mocks the DeleteUserQuietHours method.
+	DeleteUserQuietHoursFunc func(userID string) error
+
+	// DeleteUserTierFunc mocks the DeleteUserTier method.
+	DeleteUserTierFunc func(userID string) error
+
 	// GetRuleByTypeFunc mocks the GetRuleByType method.
 	GetRuleByTypeFunc func(s string) ([]*domain.RateLimitRule, error)
 
 	// GetRulesFunc mocks the GetRules method.
 	GetRulesFunc func() (map[string][]*domain.RateLimitRule, error)
 
+	// GetUserQuietHoursFunc mocks the GetUserQuietHours method.
+	GetUserQuietHoursFunc func(userID string) (*domain.UserQuietHours, error)
+
+	// GetUserTierFunc mocks the GetUserTier method.
+	GetUserTierFunc func(userID string) (string, error)
+
+	// SetUserQuietHoursFunc mocks the SetUserQuietHours method.
+	SetUserQuietHoursFunc func(userQuietHours *domain.UserQuietHours) error
+
+	// SetUserTierFunc mocks the SetUserTier method.
+	SetUserTierFunc func(userID string, tier string) error
+
+	// UpdateRulesByTypeFunc mocks the UpdateRulesByType method.
+	UpdateRulesByTypeFunc func(notificationType string, rules []*domain.RateLimitRule) error
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// AddRule holds details about calls to the AddRule method.
+		AddRule []struct {
+			// Rule is the rule argument value.
+			Rule *domain.RateLimitRule
+		}
+		// DeleteRulesByType holds details about calls to the DeleteRulesByType method.
+		DeleteRulesByType []struct {
+			// NotificationType is the notificationType argument value.
+			NotificationType string
+		}
+		// DeleteUserQuietHours holds details about calls to the DeleteUserQuietHours method.
+		DeleteUserQuietHours []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// DeleteUserTier holds details about calls to the DeleteUserTier method.
+		DeleteUserTier []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
 		// GetRuleByType holds details about calls to the GetRuleByType method.
 		GetRuleByType []struct {
 			// S is the s argument value.
@@ -47,9 +121,175 @@ type RulesContainerMock struct {
 		// GetRules holds details about calls to the GetRules method.
 		GetRules []struct {
 		}
+		// GetUserQuietHours holds details about calls to the GetUserQuietHours method.
+		GetUserQuietHours []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// GetUserTier holds details about calls to the GetUserTier method.
+		GetUserTier []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// SetUserQuietHours holds details about calls to the SetUserQuietHours method.
+		SetUserQuietHours []struct {
+			// UserQuietHours is the userQuietHours argument value.
+			UserQuietHours *domain.UserQuietHours
+		}
+		// SetUserTier holds details about calls to the SetUserTier method.
+		SetUserTier []struct {
+			// UserID is the userID argument value.
+			UserID string
+			// Tier is the tier argument value.
+			Tier string
+		}
+		// UpdateRulesByType holds details about calls to the UpdateRulesByType method.
+		UpdateRulesByType []struct {
+			// NotificationType is the notificationType argument value.
+			NotificationType string
+			// Rules is the rules argument value.
+			Rules []*domain.RateLimitRule
+		}
 	}
-	lockGetRuleByType sync.RWMutex
-	lockGetRules      sync.RWMutex
+	lockAddRule              sync.RWMutex
+	lockDeleteRulesByType    sync.RWMutex
+	lockDeleteUserQuietHours sync.RWMutex
+	lockDeleteUserTier       sync.RWMutex
+	lockGetRuleByType        sync.RWMutex
+	lockGetRules             sync.RWMutex
+	lockGetUserQuietHours    sync.RWMutex
+	lockGetUserTier          sync.RWMutex
+	lockSetUserQuietHours    sync.RWMutex
+	lockSetUserTier          sync.RWMutex
+	lockUpdateRulesByType    sync.RWMutex
+}
+
+// AddRule calls AddRuleFunc.
+func (mock *RulesContainerMock) AddRule(rule *domain.RateLimitRule) error {
+	if mock.AddRuleFunc == nil {
+		panic("RulesContainerMock.AddRuleFunc: method is nil but RulesContainer.AddRule was just called")
+	}
+	callInfo := struct {
+		Rule *domain.RateLimitRule
+	}{
+		Rule: rule,
+	}
+	mock.lockAddRule.Lock()
+	mock.calls.AddRule = append(mock.calls.AddRule, callInfo)
+	mock.lockAddRule.Unlock()
+	return mock.AddRuleFunc(rule)
+}
+
+// AddRuleCalls gets all the calls that were made to AddRule.
+// Check the length with:
+//
+//	len(mockedRulesContainer.AddRuleCalls())
+func (mock *RulesContainerMock) AddRuleCalls() []struct {
+	Rule *domain.RateLimitRule
+} {
+	var calls []struct {
+		Rule *domain.RateLimitRule
+	}
+	mock.lockAddRule.RLock()
+	calls = mock.calls.AddRule
+	mock.lockAddRule.RUnlock()
+	return calls
+}
+
+// DeleteRulesByType calls DeleteRulesByTypeFunc.
+func (mock *RulesContainerMock) DeleteRulesByType(notificationType string) error {
+	if mock.DeleteRulesByTypeFunc == nil {
+		panic("RulesContainerMock.DeleteRulesByTypeFunc: method is nil but RulesContainer.DeleteRulesByType was just called")
+	}
+	callInfo := struct {
+		NotificationType string
+	}{
+		NotificationType: notificationType,
+	}
+	mock.lockDeleteRulesByType.Lock()
+	mock.calls.DeleteRulesByType = append(mock.calls.DeleteRulesByType, callInfo)
+	mock.lockDeleteRulesByType.Unlock()
+	return mock.DeleteRulesByTypeFunc(notificationType)
+}
+
+// DeleteRulesByTypeCalls gets all the calls that were made to DeleteRulesByType.
+// Check the length with:
+//
+//	len(mockedRulesContainer.DeleteRulesByTypeCalls())
+func (mock *RulesContainerMock) DeleteRulesByTypeCalls() []struct {
+	NotificationType string
+} {
+	var calls []struct {
+		NotificationType string
+	}
+	mock.lockDeleteRulesByType.RLock()
+	calls = mock.calls.DeleteRulesByType
+	mock.lockDeleteRulesByType.RUnlock()
+	return calls
+}
+
+// DeleteUserQuietHours calls DeleteUserQuietHoursFunc.
+func (mock *RulesContainerMock) DeleteUserQuietHours(userID string) error {
+	if mock.DeleteUserQuietHoursFunc == nil {
+		panic("RulesContainerMock.DeleteUserQuietHoursFunc: method is nil but RulesContainer.DeleteUserQuietHours was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockDeleteUserQuietHours.Lock()
+	mock.calls.DeleteUserQuietHours = append(mock.calls.DeleteUserQuietHours, callInfo)
+	mock.lockDeleteUserQuietHours.Unlock()
+	return mock.DeleteUserQuietHoursFunc(userID)
+}
+
+// DeleteUserQuietHoursCalls gets all the calls that were made to DeleteUserQuietHours.
+// Check the length with:
+//
+//	len(mockedRulesContainer.DeleteUserQuietHoursCalls())
+func (mock *RulesContainerMock) DeleteUserQuietHoursCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockDeleteUserQuietHours.RLock()
+	calls = mock.calls.DeleteUserQuietHours
+	mock.lockDeleteUserQuietHours.RUnlock()
+	return calls
+}
+
+// DeleteUserTier calls DeleteUserTierFunc.
+func (mock *RulesContainerMock) DeleteUserTier(userID string) error {
+	if mock.DeleteUserTierFunc == nil {
+		panic("RulesContainerMock.DeleteUserTierFunc: method is nil but RulesContainer.DeleteUserTier was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockDeleteUserTier.Lock()
+	mock.calls.DeleteUserTier = append(mock.calls.DeleteUserTier, callInfo)
+	mock.lockDeleteUserTier.Unlock()
+	return mock.DeleteUserTierFunc(userID)
+}
+
+// DeleteUserTierCalls gets all the calls that were made to DeleteUserTier.
+// Check the length with:
+//
+//	len(mockedRulesContainer.DeleteUserTierCalls())
+func (mock *RulesContainerMock) DeleteUserTierCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockDeleteUserTier.RLock()
+	calls = mock.calls.DeleteUserTier
+	mock.lockDeleteUserTier.RUnlock()
+	return calls
 }
 
 // GetRuleByType calls GetRuleByTypeFunc.
@@ -110,3 +350,171 @@ func (mock *RulesContainerMock) GetRulesCalls() []struct {
 	mock.lockGetRules.RUnlock()
 	return calls
 }
+
+// GetUserQuietHours calls GetUserQuietHoursFunc.
+func (mock *RulesContainerMock) GetUserQuietHours(userID string) (*domain.UserQuietHours, error) {
+	if mock.GetUserQuietHoursFunc == nil {
+		panic("RulesContainerMock.GetUserQuietHoursFunc: method is nil but RulesContainer.GetUserQuietHours was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockGetUserQuietHours.Lock()
+	mock.calls.GetUserQuietHours = append(mock.calls.GetUserQuietHours, callInfo)
+	mock.lockGetUserQuietHours.Unlock()
+	return mock.GetUserQuietHoursFunc(userID)
+}
+
+// GetUserQuietHoursCalls gets all the calls that were made to GetUserQuietHours.
+// Check the length with:
+//
+//	len(mockedRulesContainer.GetUserQuietHoursCalls())
+func (mock *RulesContainerMock) GetUserQuietHoursCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockGetUserQuietHours.RLock()
+	calls = mock.calls.GetUserQuietHours
+	mock.lockGetUserQuietHours.RUnlock()
+	return calls
+}
+
+// GetUserTier calls GetUserTierFunc.
+func (mock *RulesContainerMock) GetUserTier(userID string) (string, error) {
+	if mock.GetUserTierFunc == nil {
+		panic("RulesContainerMock.GetUserTierFunc: method is nil but RulesContainer.GetUserTier was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockGetUserTier.Lock()
+	mock.calls.GetUserTier = append(mock.calls.GetUserTier, callInfo)
+	mock.lockGetUserTier.Unlock()
+	return mock.GetUserTierFunc(userID)
+}
+
+// GetUserTierCalls gets all the calls that were made to GetUserTier.
+// Check the length with:
+//
+//	len(mockedRulesContainer.GetUserTierCalls())
+func (mock *RulesContainerMock) GetUserTierCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockGetUserTier.RLock()
+	calls = mock.calls.GetUserTier
+	mock.lockGetUserTier.RUnlock()
+	return calls
+}
+
+// SetUserQuietHours calls SetUserQuietHoursFunc.
+func (mock *RulesContainerMock) SetUserQuietHours(userQuietHours *domain.UserQuietHours) error {
+	if mock.SetUserQuietHoursFunc == nil {
+		panic("RulesContainerMock.SetUserQuietHoursFunc: method is nil but RulesContainer.SetUserQuietHours was just called")
+	}
+	callInfo := struct {
+		UserQuietHours *domain.UserQuietHours
+	}{
+		UserQuietHours: userQuietHours,
+	}
+	mock.lockSetUserQuietHours.Lock()
+	mock.calls.SetUserQuietHours = append(mock.calls.SetUserQuietHours, callInfo)
+	mock.lockSetUserQuietHours.Unlock()
+	return mock.SetUserQuietHoursFunc(userQuietHours)
+}
+
+// SetUserQuietHoursCalls gets all the calls that were made to SetUserQuietHours.
+// Check the length with:
+//
+//	len(mockedRulesContainer.SetUserQuietHoursCalls())
+func (mock *RulesContainerMock) SetUserQuietHoursCalls() []struct {
+	UserQuietHours *domain.UserQuietHours
+} {
+	var calls []struct {
+		UserQuietHours *domain.UserQuietHours
+	}
+	mock.lockSetUserQuietHours.RLock()
+	calls = mock.calls.SetUserQuietHours
+	mock.lockSetUserQuietHours.RUnlock()
+	return calls
+}
+
+// SetUserTier calls SetUserTierFunc.
+func (mock *RulesContainerMock) SetUserTier(userID string, tier string) error {
+	if mock.SetUserTierFunc == nil {
+		panic("RulesContainerMock.SetUserTierFunc: method is nil but RulesContainer.SetUserTier was just called")
+	}
+	callInfo := struct {
+		UserID string
+		Tier   string
+	}{
+		UserID: userID,
+		Tier:   tier,
+	}
+	mock.lockSetUserTier.Lock()
+	mock.calls.SetUserTier = append(mock.calls.SetUserTier, callInfo)
+	mock.lockSetUserTier.Unlock()
+	return mock.SetUserTierFunc(userID, tier)
+}
+
+// SetUserTierCalls gets all the calls that were made to SetUserTier.
+// Check the length with:
+//
+//	len(mockedRulesContainer.SetUserTierCalls())
+func (mock *RulesContainerMock) SetUserTierCalls() []struct {
+	UserID string
+	Tier   string
+} {
+	var calls []struct {
+		UserID string
+		Tier   string
+	}
+	mock.lockSetUserTier.RLock()
+	calls = mock.calls.SetUserTier
+	mock.lockSetUserTier.RUnlock()
+	return calls
+}
+
+// UpdateRulesByType calls UpdateRulesByTypeFunc.
+func (mock *RulesContainerMock) UpdateRulesByType(notificationType string, rules []*domain.RateLimitRule) error {
+	if mock.UpdateRulesByTypeFunc == nil {
+		panic("RulesContainerMock.UpdateRulesByTypeFunc: method is nil but RulesContainer.UpdateRulesByType was just called")
+	}
+	callInfo := struct {
+		NotificationType string
+		Rules            []*domain.RateLimitRule
+	}{
+		NotificationType: notificationType,
+		Rules:            rules,
+	}
+	mock.lockUpdateRulesByType.Lock()
+	mock.calls.UpdateRulesByType = append(mock.calls.UpdateRulesByType, callInfo)
+	mock.lockUpdateRulesByType.Unlock()
+	return mock.UpdateRulesByTypeFunc(notificationType, rules)
+}
+
+// UpdateRulesByTypeCalls gets all the calls that were made to UpdateRulesByType.
+// Check the length with:
+//
+//	len(mockedRulesContainer.UpdateRulesByTypeCalls())
+func (mock *RulesContainerMock) UpdateRulesByTypeCalls() []struct {
+	NotificationType string
+	Rules            []*domain.RateLimitRule
+} {
+	var calls []struct {
+		NotificationType string
+		Rules            []*domain.RateLimitRule
+	}
+	mock.lockUpdateRulesByType.RLock()
+	calls = mock.calls.UpdateRulesByType
+	mock.lockUpdateRulesByType.RUnlock()
+	return calls
+}
@@ -1,83 +1,416 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
 	"rate-limiter/domain"
 	"rate-limiter/errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 )
 
 type NotificationsContainer interface {
-	AddNotification(userID, notificationType string) error
+	// AddNotificationIfAllowed checks all the rules and adds the notification only if the enforced
+	// ones allow it, as a single atomic operation. Shadow rules that don't allow the notification
+	// don't register it, but don't reject it either.
+	AddNotificationIfAllowed(params domain.AddNotificationParams) (*domain.AddNotificationResult, error)
 	GetNotificationsByUser(params domain.GetNotificationParams) ([]*domain.Notification, error)
+	GetRuleState(params domain.RuleStateParams) (*domain.RuleState, error)
+}
+
+type DelayedNotificationsContainer interface {
+	AddDelayedNotification(notification *domain.DelayedNotification) error
+	// PopDueNotifications removes and returns up to limit notifications due at now, the earliest
+	// first, so each one is delivered once.
+	PopDueNotifications(now time.Time, limit int) ([]*domain.DelayedNotification, error)
+	GetDelayedNotifications() ([]*domain.DelayedNotification, error)
+}
+
+type DigestsContainer interface {
+	// AddToDigest merges the digest into the one of the same user and type, or stores it if there is
+	// none, and returns the stored digest.
+	AddToDigest(digest *domain.Digest) (*domain.Digest, error)
+	// PopDueDigests removes and returns up to limit digests due at now, the earliest first, so each
+	// one is sent once.
+	PopDueDigests(now time.Time, limit int) ([]*domain.Digest, error)
+	GetDigests() ([]*domain.Digest, error)
 }
 
 type CommunicationClient interface {
-	Send(string) error
+	Send(userID string, payload json.RawMessage) error
 }
 
 type RateLimitService struct {
 	notificationsContainer NotificationsContainer
 	rulesService           *RulesService
+	delayedContainer       DelayedNotificationsContainer
+	digestsContainer       DigestsContainer
+	// communicationClient sends the emails, which are only logged when it is nil.
+	communicationClient CommunicationClient
+
+	shadowMutex sync.Mutex
+	// shadowRejections are counted by rule key since the service started.
+	shadowRejections map[string]*domain.ShadowRejections
 }
 
-func NewRateLimitService(notificationsContainer NotificationsContainer, rulesService *RulesService) *RateLimitService {
+func NewRateLimitService(notificationsContainer NotificationsContainer, rulesService *RulesService, delayedContainer DelayedNotificationsContainer, digestsContainer DigestsContainer) *RateLimitService {
 	return &RateLimitService{
 		notificationsContainer: notificationsContainer,
 		rulesService:           rulesService,
+		delayedContainer:       delayedContainer,
+		digestsContainer:       digestsContainer,
+		shadowRejections:       map[string]*domain.ShadowRejections{},
 	}
 }
 
-func (ns *RateLimitService) SendNotification(params domain.SendNotificationParams) error {
-	rules, err := ns.rulesService.GetRuleByType(params.NotificationType)
-	if err != nil {
-		return errors.ErrGetRateLimitRule
+// SendNotification returns the decision of the rules of the type, and only returns an error when
+// the rules can't be checked. A rejected notification is delayed, dropped or added to a digest
+// according to the OnExceed policy of the blocking rule.
+func (ns *RateLimitService) SendNotification(params domain.SendNotificationParams) (*domain.Decision, error) {
+	decision, err := ns.sendNotification(params)
+	if err != nil || decision.Allowed {
+		return decision, err
 	}
+	return decision, ns.onExceed(decision, domain.NewDelayedNotification(params, decision.Timestamp))
+}
 
-	if len(rules) == 0 {
-		ns.sendEmail(params.UserID)
-		return nil
+// SendDelayedNotification sends a notification delayed by SendNotification, and delays it again if
+// the rules still don't allow it, up to domain.MaxDeliveryAttempts times.
+func (ns *RateLimitService) SendDelayedNotification(notification *domain.DelayedNotification) (*domain.Decision, error) {
+	decision, err := ns.sendNotification(notification.SendParams())
+	if err != nil || decision.Allowed {
+		return decision, err
 	}
-	for _, rule := range rules {
-		allow, err := ns.checkRateLimit(params.UserID, rule)
+	notification.Attempts++
+	if notification.AttemptsExhausted() {
+		fmt.Printf("Delayed notification %s to %s dropped after %d attempts\n", notification.ID, notification.UserID, notification.Attempts)
+		return decision, nil
+	}
+	return decision, ns.onExceed(decision, notification)
+}
+
+// onExceed applies the OnExceed policy of the rule that rejected the notification. Delayed
+// notifications are delivered when the rules allow them again, which is unknown when the
+// notifications storage can't tell the quotas, so they are retried after a backoff then. Digests
+// are sent when the window of the rule ends.
+func (ns *RateLimitService) onExceed(decision *domain.Decision, notification *domain.DelayedNotification) error {
+	switch decision.OnExceed() {
+	case domain.OnExceedDelay:
+		deliverAt := decision.Timestamp.Add(decision.RetryAfter())
+		if !decision.HasRetryAfter() {
+			deliverAt = decision.Timestamp.Add(notification.RetryBackoff())
+		}
+		notification.DeliverAt = deliverAt
+		if err := ns.delayedContainer.AddDelayedNotification(notification); err != nil {
+			return err
+		}
+		decision.DeliverAt = &deliverAt
+		fmt.Printf("Notification to %s delayed until %s\n", notification.UserID, deliverAt.Format(time.RFC3339))
+	case domain.OnExceedDropSilently:
+		fmt.Printf("Notification to %s dropped\n", notification.UserID)
+	case domain.OnExceedDigest:
+		deliverAt, err := decision.DigestDeliverAt()
 		if err != nil {
 			return err
 		}
-
-		if !allow {
-			return errors.ErrRateLimitExceeded
+		digest, err := ns.digestsContainer.AddToDigest(domain.NewDigest(notification, deliverAt))
+		if err != nil {
+			return err
 		}
+		decision.DeliverAt = &digest.DeliverAt
+		fmt.Printf("Notification to %s added to the %s digest, sent at %s\n", notification.UserID, notification.NotificationType, digest.DeliverAt.Format(time.RFC3339))
 	}
+	return nil
+}
 
-	err = ns.sendEmail(params.UserID)
+// SendDigest sends the notifications of the digest as a single one, which is checked by the rules
+// like any other notification. The digest the rules reject is stored again, to be sent when the
+// window of the blocking rule ends.
+func (ns *RateLimitService) SendDigest(digest *domain.Digest) (*domain.Decision, error) {
+	params, err := digest.SendParams()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = ns.notificationsContainer.AddNotification(params.UserID, params.NotificationType)
+	decision, err := ns.sendNotification(params)
 	if err != nil {
-		fmt.Println("Error registering notification")
+		return nil, err
 	}
-	return nil
+	if decision.Allowed {
+		fmt.Printf("Digest of %d %s notifications sent to %s\n", digest.Count, digest.NotificationType, digest.UserID)
+		return decision, nil
+	}
+
+	deliverAt, err := decision.DigestDeliverAt()
+	if err != nil {
+		return nil, err
+	}
+	digest.DeliverAt = deliverAt
+	stored, err := ns.digestsContainer.AddToDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	decision.DeliverAt = &stored.DeliverAt
+	fmt.Printf("Digest of %d %s notifications to %s delayed until %s\n", digest.Count, digest.NotificationType, digest.UserID, stored.DeliverAt.Format(time.RFC3339))
+	return decision, nil
+}
+
+// GetDigests returns the digests waiting to be sent, the earliest first.
+func (ns *RateLimitService) GetDigests() ([]*domain.Digest, error) {
+	return ns.digestsContainer.GetDigests()
+}
+
+// GetDelayedNotifications returns the notifications waiting to be delivered, the earliest first.
+func (ns *RateLimitService) GetDelayedNotifications() ([]*domain.DelayedNotification, error) {
+	return ns.delayedContainer.GetDelayedNotifications()
+}
+
+func (ns *RateLimitService) sendNotification(params domain.SendNotificationParams) (*domain.Decision, error) {
+	rules, err := ns.rulesService.GetApplicableRules(params.NotificationType, params.UserID)
+	if err != nil {
+		return nil, errors.ErrGetRateLimitRule
+	}
+
+	timezone, settings, err := ns.userSettings(params.UserID, params.Timezone, rules)
+	if err != nil {
+		return nil, err
+	}
+	params.Timezone = timezone
+
+	now := time.Now()
+	decision := &domain.Decision{Allowed: true, Timestamp: now, Timezone: timezone}
+	quietHoursRules, rules := domain.PartitionQuietHours(rules)
+	quietHours, err := ns.checkQuietHours(params, settings, quietHoursRules, now)
+	if err != nil {
+		return nil, err
+	}
+	if quietHours.RejectedBy != nil {
+		ns.countShadowRejections(params.UserID, quietHours.ShadowRejections, now)
+		decision.Allowed = false
+		decision.BlockingRule = quietHours.RejectedBy
+		decision.QuietUntil = quietHours.Until
+		return decision, nil
+	}
+
+	if len(rules) == 0 {
+		ns.countShadowRejections(params.UserID, quietHours.ShadowRejections, now)
+		return decision, ns.sendEmail(params)
+	}
+
+	result, err := ns.checkRateLimit(params, rules, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// The shadow rules are counted whether the enforced ones allow the notification or not.
+	ns.countShadowRejections(params.UserID, append(quietHours.ShadowRejections, result.ShadowRejections...), now)
+	decision.Allowed = result.RejectedBy == nil
+	decision.BlockingRule = result.RejectedBy
+	decision.Quotas = ns.decisionQuotas(params, domain.EnforcedRules(rules), now)
+	if !decision.Allowed {
+		return decision, nil
+	}
+
+	// The notification is registered before it is sent, so it counts towards the limits even when
+	// sending it fails: retrying it takes another slot.
+	return decision, ns.sendEmail(params)
+}
+
+// quietHoursResult is the outcome of checking the quiet hours rules of a notification, like
+// domain.AddNotificationResult. Until is when the quiet hours of the enforced rules that reject it
+// end.
+type quietHoursResult struct {
+	RejectedBy       *domain.RateLimitRule
+	Until            *time.Time
+	ShadowRejections []*domain.RateLimitRule
 }
 
-func (ns *RateLimitService) checkRateLimit(userID string, rule *domain.RateLimitRule) (bool, error) {
-	notifications, err := ns.notificationsContainer.GetNotificationsByUser(domain.GetNotificationParams{
-		UserID:           userID,
-		NotificationType: rule.NotificationType,
-		TimeInterval:     rule.TimeInterval.Duration,
+// userSettings returns the timezone the rules of the user are evaluated in, and the quiet hours
+// settings of the user. The timezone is the one of the request or, without one, the one stored for
+// the user, so the calendar windows and the quiet hours use the same one; the rules fall back to
+// their own timezone when neither is set. The settings are only read when the rules need them.
+func (ns *RateLimitService) userSettings(userID, timezone string, rules []*domain.RateLimitRule) (string, *domain.UserQuietHours, error) {
+	needed := slices.ContainsFunc(rules, func(rule *domain.RateLimitRule) bool {
+		return rule.IsQuietHours() || timezone == "" && rule.Window != ""
 	})
+	if !needed {
+		return timezone, nil, nil
+	}
+	settings, err := ns.rulesService.GetUserQuietHours(userID)
+	if errors.IsUserQuietHoursNotFoundError(err) {
+		return timezone, nil, nil
+	}
 	if err != nil {
-		return false, err
+		return "", nil, errors.ErrGetRateLimitRule
+	}
+	if timezone == "" {
+		timezone = settings.Timezone
 	}
+	return timezone, settings, nil
+}
 
-	if len(notifications) >= rule.MaxLimit {
+// checkQuietHours checks the quiet hours rules in the timezone resolved by userSettings, with the
+// settings of the user.
+func (ns *RateLimitService) checkQuietHours(params domain.SendNotificationParams, settings *domain.UserQuietHours, rules []*domain.RateLimitRule, now time.Time) (*quietHoursResult, error) {
+	result := &quietHoursResult{}
+	for _, rule := range rules {
+		until, err := rule.QuietUntil(now, params.Timezone, settings)
+		if err != nil {
+			return nil, err
+		}
+		if until == nil {
+			continue
+		}
+		if rule.IsShadow() {
+			result.ShadowRejections = append(result.ShadowRejections, rule)
+			continue
+		}
+		if result.RejectedBy == nil {
+			result.RejectedBy = rule
+		}
+		if result.Until == nil || until.After(*result.Until) {
+			result.Until = until
+		}
+	}
+	return result, nil
+}
+
+// checkRateLimit registers the notification if all the enforced rules allow it. The check and the
+// registration are done atomically by the container, so concurrent requests can't both pass the
+// limit.
+func (ns *RateLimitService) checkRateLimit(params domain.SendNotificationParams, rules []*domain.RateLimitRule, now time.Time) (*domain.AddNotificationResult, error) {
+	result, err := ns.notificationsContainer.AddNotificationIfAllowed(domain.AddNotificationParams{
+		UserID:           params.UserID,
+		NotificationType: params.NotificationType,
+		Timezone:         params.Timezone,
+		Timestamp:        now,
+		Rules:            rules,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.RejectedBy != nil {
 		fmt.Println("Within interval. max exceeded")
-		return false, nil
 	}
+	return result, nil
+}
+
+func (ns *RateLimitService) countShadowRejections(userID string, rules []*domain.RateLimitRule, now time.Time) {
+	ns.shadowMutex.Lock()
+	defer ns.shadowMutex.Unlock()
 
-	return true, nil
+	for _, rule := range rules {
+		fmt.Printf("Shadow rule %s would have rejected the notification to %s\n", rule.Key(), userID)
+		rejections, ok := ns.shadowRejections[rule.Key()]
+		if !ok {
+			rejections = &domain.ShadowRejections{}
+			ns.shadowRejections[rule.Key()] = rejections
+		}
+		rejections.Rule = rule
+		rejections.Rejections++
+		rejections.LastRejectedAt = now
+	}
 }
 
-func (ns *RateLimitService) sendEmail(userID string) error {
-	fmt.Printf("Email sent to %s\n", userID)
+// GetShadowRejections returns how many notifications each shadow rule would have rejected since
+// the service started, sorted by rule.
+func (ns *RateLimitService) GetShadowRejections() []*domain.ShadowRejections {
+	ns.shadowMutex.Lock()
+	defer ns.shadowMutex.Unlock()
+
+	shadowRejections := []*domain.ShadowRejections{}
+	for _, rejections := range ns.shadowRejections {
+		rejectionsCopy := *rejections
+		shadowRejections = append(shadowRejections, &rejectionsCopy)
+	}
+	sort.Slice(shadowRejections, func(i, j int) bool {
+		return shadowRejections[i].Rule.Key() < shadowRejections[j].Rule.Key()
+	})
+	return shadowRejections
+}
+
+// GetQuota returns how many notifications each enforced rule of the type lets the user send, from the same
+// notifications and rule states the rules are checked with.
+func (ns *RateLimitService) GetQuota(params domain.GetQuotaParams) (*domain.Quota, error) {
+	rules, err := ns.rulesService.GetApplicableRules(params.NotificationType, params.UserID)
+	if err != nil {
+		return nil, errors.ErrGetRateLimitRule
+	}
+
+	_, rules = domain.PartitionQuietHours(rules)
+	timezone, _, err := ns.userSettings(params.UserID, params.Timezone, rules)
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := ns.ruleQuotas(params.UserID, timezone, domain.EnforcedRules(rules), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Quota{
+		UserID:           params.UserID,
+		NotificationType: params.NotificationType,
+		Rules:            quotas,
+	}, nil
+}
+
+// decisionQuotas returns the quotas after the notification was checked. They are read after the
+// check, so they are left out when they can't be read instead of failing the notification.
+func (ns *RateLimitService) decisionQuotas(params domain.SendNotificationParams, rules []*domain.RateLimitRule, now time.Time) []*domain.RuleQuota {
+	quotas, err := ns.ruleQuotas(params.UserID, params.Timezone, rules, now)
+	if err != nil {
+		if !errors.IsNotSupportedError(err) {
+			fmt.Println("error getting quotas:", err)
+		}
+		return nil
+	}
+	return quotas
+}
+
+func (ns *RateLimitService) ruleQuotas(userID, timezone string, rules []*domain.RateLimitRule, now time.Time) ([]*domain.RuleQuota, error) {
+	quotas := []*domain.RuleQuota{}
+	for _, rule := range rules {
+		var history []*domain.Notification
+		var state *domain.RuleState
+		if rule.UsesHistory() {
+			since, err := rule.Since(now, timezone)
+			if err != nil {
+				return nil, err
+			}
+			history, err = ns.notificationsContainer.GetNotificationsByUser(domain.GetNotificationParams{
+				UserID:           userID,
+				NotificationType: rule.NotificationType,
+				TimeInterval:     now.Sub(since),
+			})
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			var err error
+			state, err = ns.notificationsContainer.GetRuleState(domain.RuleStateParams{
+				UserID:           userID,
+				NotificationType: rule.NotificationType,
+				RuleKey:          rule.Key(),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		quota, err := rule.Quota(history, state, now, timezone)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+func (ns *RateLimitService) sendEmail(params domain.SendNotificationParams) error {
+	if ns.communicationClient != nil {
+		return ns.communicationClient.Send(params.UserID, params.Payload)
+	}
+	fmt.Printf("Email sent to %s\n", params.UserID)
 	return nil
 }
@@ -1,27 +1,32 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"rate-limiter/dao/notifications"
 	"rate-limiter/domain"
 	"rate-limiter/errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-var userIDTest = "userID_test"
-var notificationTypeTest = "type_test"
-
 func TestRateLimitService_SendNotification_ErrorGetRules(t *testing.T) {
 	mockRulesContainer := &RulesContainerMock{
-		GetRuleByTypeFunc: func(s string) ([]*domain.RateLimitRule, error) {
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
 			return nil, fmt.Errorf("some error")
 		},
 	}
 
-	rateLimitService := NewRateLimitService(&NotificationsContainerMock{}, NewRulesService(mockRulesContainer))
-	err := rateLimitService.SendNotification(domain.SendNotificationParams{
+	rateLimitService := NewRateLimitService(&NotificationsContainerMock{}, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	_, err := rateLimitService.SendNotification(domain.SendNotificationParams{
 		UserID:           "user1",
 		NotificationType: "email",
 	})
@@ -30,171 +35,730 @@ func TestRateLimitService_SendNotification_ErrorGetRules(t *testing.T) {
 }
 
 func TestRateLimitService_SendNotification_Success_RuleNotExists(t *testing.T) {
-	mockNotificationsContainer := &NotificationsContainerMock{
-		AddNotificationFunc: func(userID string, notificationType string) error {
-			return nil
-		},
-		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
-			return []*domain.Notification{}, nil
-		},
-	}
+	mockNotificationsContainer := &NotificationsContainerMock{}
 	mockRulesContainer := &RulesContainerMock{
-		GetRuleByTypeFunc: func(s string) ([]*domain.RateLimitRule, error) {
-			return nil, nil
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{}, nil
 		},
 	}
 
-	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer))
-	err := rateLimitService.SendNotification(domain.SendNotificationParams{
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	_, err := rateLimitService.SendNotification(domain.SendNotificationParams{
 		UserID:           "user1",
 		NotificationType: "email",
 	})
 
 	assert.NoError(t, err)
+	assert.Empty(t, mockNotificationsContainer.AddNotificationIfAllowedCalls())
 }
 
-func TestRateLimitService_SendNotification_ErrorGetNotifications(t *testing.T) {
+func TestRateLimitService_SendNotification_ErrorAddNotification(t *testing.T) {
 	mockNotificationsContainer := &NotificationsContainerMock{
-		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
-			return nil, fmt.Errorf("error getting rate limit rule for notification type")
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			return nil, fmt.Errorf("some error")
 		},
 	}
 	mockRulesContainer := &RulesContainerMock{
-		GetRuleByTypeFunc: func(s string) ([]*domain.RateLimitRule, error) {
-			return []*domain.RateLimitRule{
-				{
-					NotificationType: "news",
-					MaxLimit:         3,
-					TimeInterval:     domain.Duration{Duration: time.Second * 60},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"email": {
+					{
+						NotificationType: "news",
+						MaxLimit:         3,
+						TimeInterval:     domain.Duration{Duration: time.Second * 60},
+					},
 				},
 			}, nil
 		},
 	}
+	mockCommunicationClient := &CommunicationClientMock{}
 
-	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer))
-	err := rateLimitService.SendNotification(domain.SendNotificationParams{
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	rateLimitService.communicationClient = mockCommunicationClient
+	_, err := rateLimitService.SendNotification(domain.SendNotificationParams{
 		UserID:           "user1",
 		NotificationType: "email",
 	})
 
-	expectedError := fmt.Errorf("error getting rate limit rule for notification type")
-	assert.Equal(t, expectedError, err)
+	// The storage error surfaces, and the notification is not sent.
+	assert.Equal(t, fmt.Errorf("some error"), err)
+	assert.Empty(t, mockCommunicationClient.SendCalls())
+}
+
+func TestRateLimitService_SendNotification_ErrorSendEmail(t *testing.T) {
+	testCases := []struct {
+		name               string
+		rules              map[string][]*domain.RateLimitRule
+		expectedRegistered bool
+	}{
+		{
+			name:  "type without rules",
+			rules: map[string][]*domain.RateLimitRule{},
+		},
+		{
+			name: "type with rules",
+			rules: map[string][]*domain.RateLimitRule{
+				"email": {{NotificationType: "email", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Minute}}},
+			},
+			expectedRegistered: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockNotificationsContainer := &NotificationsContainerMock{
+				AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+					return &domain.AddNotificationResult{}, nil
+				},
+				GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+					return []*domain.Notification{}, nil
+				},
+			}
+			mockRulesContainer := &RulesContainerMock{
+				GetUserTierFunc: func(userID string) (string, error) {
+					return "", nil
+				},
+				GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+					return tc.rules, nil
+				},
+			}
+			mockCommunicationClient := &CommunicationClientMock{
+				SendFunc: func(userID string, payload json.RawMessage) error {
+					return fmt.Errorf("smtp unavailable")
+				},
+			}
+
+			rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+			rateLimitService.communicationClient = mockCommunicationClient
+			_, err := rateLimitService.SendNotification(domain.SendNotificationParams{
+				UserID:           "user1",
+				NotificationType: "email",
+			})
+
+			assert.Equal(t, fmt.Errorf("smtp unavailable"), err)
+			assert.Len(t, mockCommunicationClient.SendCalls(), 1)
+			// The notification was registered before sending it, so it still counts towards the limits.
+			assert.Equal(t, tc.expectedRegistered, len(mockNotificationsContainer.AddNotificationIfAllowedCalls()) == 1)
+		})
+	}
 }
 
 func TestRateLimitService_SendNotification_LimitExceeded(t *testing.T) {
 	mockNotificationsContainer := &NotificationsContainerMock{
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			return &domain.AddNotificationResult{RejectedBy: params.Rules[0]}, nil
+		},
 		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
-			return []*domain.Notification{
-				{
-					Timestamp: time.Now().Add(-time.Second * 10),
-					UserID:    userIDTest,
-					Type:      notificationTypeTest,
-				},
-				{
-					Timestamp: time.Now().Add(-time.Second * 5),
-					UserID:    userIDTest,
-					Type:      notificationTypeTest,
-				},
-			}, nil
+			return []*domain.Notification{{Timestamp: time.Now().Add(-time.Second)}, {Timestamp: time.Now().Add(-time.Second)}}, nil
 		},
 	}
 	mockRulesContainer := &RulesContainerMock{
-		GetRuleByTypeFunc: func(s string) ([]*domain.RateLimitRule, error) {
-			return []*domain.RateLimitRule{
-				{
-					NotificationType: "news",
-					MaxLimit:         2,
-					TimeInterval:     domain.Duration{Duration: time.Second * 60},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"email": {
+					{
+						NotificationType: "news",
+						MaxLimit:         2,
+						TimeInterval:     domain.Duration{Duration: time.Second * 60},
+					},
 				},
 			}, nil
 		},
 	}
-	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer))
-	err := rateLimitService.SendNotification(domain.SendNotificationParams{
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	decision, err := rateLimitService.SendNotification(domain.SendNotificationParams{
 		UserID:           "user1",
 		NotificationType: "email",
 	})
 
-	assert.Equal(t, errors.ErrRateLimitExceeded, err)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 2, decision.BlockingRule.MaxLimit)
+	assert.Len(t, decision.Quotas, 1)
+	assert.Equal(t, 0, decision.Quotas[0].Remaining)
+	assert.Equal(t, time.Minute, decision.RetryAfter())
 }
 
-func TestRateLimitService_SendNotification_ErrorAddNotification(t *testing.T) {
+func TestRateLimitService_SendNotification_Success_WithinInterval_LimitNotExceeded(t *testing.T) {
 	mockNotificationsContainer := &NotificationsContainerMock{
-		AddNotificationFunc: func(userID string, notificationType string) error {
-			return fmt.Errorf("some error")
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			return &domain.AddNotificationResult{}, nil
 		},
 		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
-			return []*domain.Notification{
-				{
-					Timestamp: time.Now().Add(-time.Second * 10),
-					UserID:    userIDTest,
-					Type:      notificationTypeTest,
-				},
-				{
-					Timestamp: time.Now().Add(-time.Second * 5),
-					UserID:    userIDTest,
-					Type:      notificationTypeTest,
-				},
-			}, nil
+			return nil, errors.ErrNotSupported
+		},
+	}
+	rules := []*domain.RateLimitRule{
+		{
+			NotificationType: "news",
+			MaxLimit:         3,
+			TimeInterval:     domain.Duration{Duration: time.Second * 60},
+		},
+		{
+			NotificationType: "news",
+			MaxLimit:         1,
+			Window:           domain.WindowDay,
 		},
 	}
 	mockRulesContainer := &RulesContainerMock{
-		GetRuleByTypeFunc: func(s string) ([]*domain.RateLimitRule, error) {
-			return []*domain.RateLimitRule{
-				{
-					NotificationType: "news",
-					MaxLimit:         3,
-					TimeInterval:     domain.Duration{Duration: time.Second * 60},
-				},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"news": rules,
 			}, nil
 		},
 	}
-	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer))
-	err := rateLimitService.SendNotification(domain.SendNotificationParams{
+
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	decision, err := rateLimitService.SendNotification(domain.SendNotificationParams{
 		UserID:           "user1",
-		NotificationType: "email",
+		NotificationType: "news",
+		Timezone:         "Asia/Tokyo",
 	})
 
 	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	// The storage can't tell the quotas, so the decision has none.
+	assert.Empty(t, decision.Quotas)
+	calls := mockNotificationsContainer.AddNotificationIfAllowedCalls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "user1", calls[0].Params.UserID)
+	assert.Equal(t, "news", calls[0].Params.NotificationType)
+	assert.Equal(t, "Asia/Tokyo", calls[0].Params.Timezone)
+	assert.Equal(t, rules, calls[0].Params.Rules)
+	assert.WithinDuration(t, time.Now(), calls[0].Params.Timestamp, time.Second)
 }
 
-func TestRateLimitService_SendNotification_Success_WithinInterval_LimitNotExceeded(t *testing.T) {
+func TestRateLimitService_SendNotification_ShadowRules(t *testing.T) {
+	enforced := &domain.RateLimitRule{NotificationType: "news", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Minute}}
+	shadow := &domain.RateLimitRule{NotificationType: "news", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, Mode: domain.ModeShadow}
+	var rejectedBy *domain.RateLimitRule
 	mockNotificationsContainer := &NotificationsContainerMock{
-		AddNotificationFunc: func(userID string, notificationType string) error {
-			return nil
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			return &domain.AddNotificationResult{RejectedBy: rejectedBy, ShadowRejections: []*domain.RateLimitRule{shadow}}, nil
 		},
 		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
-			return []*domain.Notification{
-				{
-					Timestamp: time.Now().Add(-time.Second * 10),
-					UserID:    userIDTest,
-					Type:      notificationTypeTest,
+			return []*domain.Notification{{Timestamp: time.Now().Add(-time.Second)}}, nil
+		},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"news": {enforced, shadow},
+			}, nil
+		},
+	}
+
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	// The shadow rejections are counted whether the enforced rule allows the notification or not.
+	for _, rejectedBy = range []*domain.RateLimitRule{nil, enforced} {
+		decision, err := rateLimitService.SendNotification(domain.SendNotificationParams{
+			UserID:           "user1",
+			NotificationType: "news",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, rejectedBy == nil, decision.Allowed)
+		// Only the enforced rule has a quota.
+		assert.Len(t, decision.Quotas, 1)
+		assert.Equal(t, enforced, decision.Quotas[0].Rule)
+	}
+
+	assert.Equal(t, []*domain.RateLimitRule{enforced, shadow}, mockNotificationsContainer.AddNotificationIfAllowedCalls()[0].Params.Rules)
+	shadowRejections := rateLimitService.GetShadowRejections()
+	assert.Len(t, shadowRejections, 1)
+	assert.Equal(t, shadow, shadowRejections[0].Rule)
+	assert.Equal(t, int64(2), shadowRejections[0].Rejections)
+	assert.WithinDuration(t, time.Now(), shadowRejections[0].LastRejectedAt, time.Second)
+}
+
+func TestRateLimitService_SendNotification_GlobalRules(t *testing.T) {
+	typeRule := &domain.RateLimitRule{NotificationType: "news", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Hour}}
+	globalRule := &domain.RateLimitRule{NotificationType: domain.NotificationTypeAll, MaxLimit: 10, Window: domain.WindowDay}
+	mockNotificationsContainer := &NotificationsContainerMock{
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			return &domain.AddNotificationResult{RejectedBy: params.Rules[1]}, nil
+		},
+		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+			notifications := []*domain.Notification{}
+			if params.NotificationType == domain.NotificationTypeAll {
+				for i := 0; i < 10; i++ {
+					notifications = append(notifications, &domain.Notification{Timestamp: time.Now().Add(-time.Second)})
+				}
+			}
+			return notifications, nil
+		},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"news":                     {typeRule},
+				domain.NotificationTypeAll: {globalRule},
+			}, nil
+		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetUserQuietHoursFunc: func(userID string) (*domain.UserQuietHours, error) {
+			return nil, nil
+		},
+	}
+
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	decision, err := rateLimitService.SendNotification(domain.SendNotificationParams{
+		UserID:           "user1",
+		NotificationType: "news",
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, globalRule, decision.BlockingRule)
+	assert.Equal(t, []*domain.RateLimitRule{typeRule, globalRule}, mockNotificationsContainer.AddNotificationIfAllowedCalls()[0].Params.Rules)
+	// The global rule counts the notifications of every type.
+	assert.Len(t, decision.Quotas, 2)
+	assert.Equal(t, 2, decision.Quotas[0].Remaining)
+	assert.Equal(t, 0, decision.Quotas[1].Remaining)
+}
+
+func TestRateLimitService_SendNotification_UserTimezone(t *testing.T) {
+	dayRule := &domain.RateLimitRule{NotificationType: "news", MaxLimit: 2, Window: domain.WindowDay}
+	hourRule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Hour}}
+	userQuietHours := map[string]*domain.UserQuietHours{"user1": {UserID: "user1", Timezone: "America/New_York"}}
+	testCases := []struct {
+		name                 string
+		userID               string
+		notificationType     string
+		timezone             string
+		expectedTimezone     string
+		expectedSettingsRead bool
+	}{
+		{name: "timezone of the request", userID: "user1", notificationType: "news", timezone: "Europe/Madrid", expectedTimezone: "Europe/Madrid"},
+		{name: "timezone of the user", userID: "user1", notificationType: "news", expectedTimezone: "America/New_York", expectedSettingsRead: true},
+		{name: "user without timezone", userID: "user2", notificationType: "news", expectedSettingsRead: true},
+		{name: "rules without calendar windows", userID: "user1", notificationType: "status"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockNotificationsContainer := &NotificationsContainerMock{
+				AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+					return &domain.AddNotificationResult{}, nil
+				},
+				GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+					return []*domain.Notification{}, nil
+				},
+			}
+			mockRulesContainer := &RulesContainerMock{
+				GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+					return map[string][]*domain.RateLimitRule{"news": {dayRule}, "status": {hourRule}}, nil
 				},
-				{
-					Timestamp: time.Now().Add(-time.Second * 5),
-					UserID:    userIDTest,
-					Type:      notificationTypeTest,
+				GetUserTierFunc: func(userID string) (string, error) {
+					return "", nil
 				},
+				GetUserQuietHoursFunc: func(userID string) (*domain.UserQuietHours, error) {
+					return userQuietHours[userID], nil
+				},
+			}
+
+			rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+			decision, err := rateLimitService.SendNotification(domain.SendNotificationParams{
+				UserID:           tc.userID,
+				NotificationType: tc.notificationType,
+				Timezone:         tc.timezone,
+			})
+			assert.NoError(t, err)
+			assert.True(t, decision.Allowed)
+			// The calendar windows are checked in the same timezone as the quiet hours.
+			assert.Equal(t, tc.expectedTimezone, mockNotificationsContainer.AddNotificationIfAllowedCalls()[0].Params.Timezone)
+			assert.Equal(t, tc.expectedTimezone, decision.Timezone)
+
+			_, err = rateLimitService.GetQuota(domain.GetQuotaParams{
+				UserID:           tc.userID,
+				NotificationType: tc.notificationType,
+				Timezone:         tc.timezone,
+			})
+			assert.NoError(t, err)
+			expectedReads := 0
+			if tc.expectedSettingsRead {
+				expectedReads = 2
+			}
+			assert.Len(t, mockRulesContainer.GetUserQuietHoursCalls(), expectedReads)
+		})
+	}
+}
+
+func TestRateLimitService_SendNotification_QuietHours(t *testing.T) {
+	now := time.Now().UTC()
+	quietHours := &domain.QuietHours{Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04")}
+	limitRule := &domain.RateLimitRule{NotificationType: "marketing", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Hour}}
+	quietHoursRule := &domain.RateLimitRule{NotificationType: "marketing", QuietHours: quietHours}
+	shadowQuietHoursRule := &domain.RateLimitRule{NotificationType: "news", QuietHours: quietHours, Mode: domain.ModeShadow}
+	shadowMarketingQuietHoursRule := &domain.RateLimitRule{NotificationType: "marketing", QuietHours: quietHours, Mode: domain.ModeShadow}
+	userQuietHours := map[string]*domain.UserQuietHours{"night_owl": {UserID: "night_owl", Disabled: true}}
+	mockRulesContainer := &RulesContainerMock{
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"marketing": {limitRule, quietHoursRule, shadowMarketingQuietHoursRule},
+				"news":      {shadowQuietHoursRule},
 			}, nil
 		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetUserQuietHoursFunc: func(userID string) (*domain.UserQuietHours, error) {
+			return userQuietHours[userID], nil
+		},
+	}
+
+	testCases := []struct {
+		name                  string
+		userID                string
+		notificationType      string
+		expectedAllowed       bool
+		expectedChecks        int
+		expectedShadowRejects int
+	}{
+		{name: "rejected in quiet hours", userID: "user1", notificationType: "marketing", expectedAllowed: false, expectedShadowRejects: 1},
+		{name: "quiet hours disabled by the user", userID: "night_owl", notificationType: "marketing", expectedAllowed: true, expectedChecks: 1},
+		{name: "shadow quiet hours", userID: "user1", notificationType: "news", expectedAllowed: true, expectedShadowRejects: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockNotificationsContainer := &NotificationsContainerMock{
+				AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+					return &domain.AddNotificationResult{}, nil
+				},
+				GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+					return []*domain.Notification{}, nil
+				},
+			}
+			rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+			decision, err := rateLimitService.SendNotification(domain.SendNotificationParams{
+				UserID:           tc.userID,
+				NotificationType: tc.notificationType,
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAllowed, decision.Allowed)
+			calls := mockNotificationsContainer.AddNotificationIfAllowedCalls()
+			assert.Len(t, calls, tc.expectedChecks)
+			if tc.expectedChecks > 0 {
+				// The quiet hours rules are checked by the service, not by the storage.
+				assert.Equal(t, []*domain.RateLimitRule{limitRule}, calls[0].Params.Rules)
+			}
+			assert.Len(t, rateLimitService.GetShadowRejections(), tc.expectedShadowRejects)
+			if tc.expectedAllowed {
+				assert.Nil(t, decision.QuietUntil)
+				return
+			}
+			assert.Equal(t, quietHoursRule, decision.BlockingRule)
+			assert.NotNil(t, decision.QuietUntil)
+			assert.LessOrEqual(t, decision.RetryAfter(), time.Hour)
+		})
+	}
+}
+
+func TestRateLimitService_SendNotification_OnExceed(t *testing.T) {
+	testCases := []struct {
+		name             string
+		onExceed         string
+		expectedDelayed  bool
+		expectedDigested bool
+	}{
+		{name: "reject", onExceed: domain.OnExceedReject},
+		{name: "delay", onExceed: domain.OnExceedDelay, expectedDelayed: true},
+		{name: "drop silently", onExceed: domain.OnExceedDropSilently},
+		{name: "digest", onExceed: domain.OnExceedDigest, expectedDigested: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: tc.onExceed}
+			mockNotificationsContainer := &NotificationsContainerMock{
+				AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+					return &domain.AddNotificationResult{RejectedBy: rule}, nil
+				},
+				GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+					return []*domain.Notification{{Timestamp: time.Now().Add(-30 * time.Second)}}, nil
+				},
+			}
+			mockRulesContainer := &RulesContainerMock{
+				GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+					return map[string][]*domain.RateLimitRule{"status": {rule}}, nil
+				},
+				GetUserTierFunc: func(userID string) (string, error) {
+					return "", nil
+				},
+			}
+			mockDelayedContainer := &DelayedNotificationsContainerMock{
+				AddDelayedNotificationFunc: func(notification *domain.DelayedNotification) error {
+					return nil
+				},
+			}
+
+			mockDigestsContainer := &DigestsContainerMock{
+				AddToDigestFunc: func(digest *domain.Digest) (*domain.Digest, error) {
+					return digest, nil
+				},
+			}
+
+			rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), mockDelayedContainer, mockDigestsContainer)
+			params := domain.SendNotificationParams{
+				UserID:           "user1",
+				NotificationType: "status",
+				Timezone:         "Europe/Madrid",
+				Payload:          json.RawMessage(`{"text":"online"}`),
+			}
+			decision, err := rateLimitService.SendNotification(params)
+
+			assert.NoError(t, err)
+			assert.False(t, decision.Allowed)
+			assert.Equal(t, tc.onExceed, decision.OnExceed())
+			digestCalls := mockDigestsContainer.AddToDigestCalls()
+			if tc.expectedDigested {
+				assert.Len(t, digestCalls, 1)
+				digest := digestCalls[0].Digest
+				assert.Equal(t, "status:user1", digest.Key())
+				assert.Equal(t, 1, digest.Count)
+				assert.Equal(t, []json.RawMessage{params.Payload}, digest.Payloads)
+				// The digest is sent when the window of the rule ends.
+				assert.Equal(t, decision.Timestamp.Truncate(time.Minute).Add(time.Minute), digest.DeliverAt)
+				assert.Equal(t, digest.DeliverAt, *decision.DeliverAt)
+			} else {
+				assert.Empty(t, digestCalls)
+			}
+			calls := mockDelayedContainer.AddDelayedNotificationCalls()
+			if !tc.expectedDelayed {
+				assert.Empty(t, calls)
+				if !tc.expectedDigested {
+					assert.Nil(t, decision.DeliverAt)
+				}
+				return
+			}
+			assert.Len(t, calls, 1)
+			delayed := calls[0].Notification
+			assert.NotEmpty(t, delayed.ID)
+			assert.Equal(t, params, delayed.SendParams())
+			// The notification is delivered when the rule frees a slot.
+			assert.WithinDuration(t, decision.Timestamp.Add(30*time.Second), delayed.DeliverAt, time.Second)
+			assert.Equal(t, delayed.DeliverAt, *decision.DeliverAt)
+		})
+	}
+}
+
+func TestRateLimitService_SendDelayedNotification(t *testing.T) {
+	testCases := []struct {
+		name             string
+		attempts         int
+		historyErr       error
+		expectedDelayed  bool
+		expectedRetryIn  time.Duration
+		expectedAttempts int
+	}{
+		{name: "delayed until the rule frees a slot", attempts: 2, expectedDelayed: true, expectedRetryIn: 30 * time.Second, expectedAttempts: 3},
+		// The storage can't tell when the rule frees a slot, so it is retried after a backoff.
+		{name: "delayed after a backoff without quotas", attempts: 2, historyErr: errors.ErrNotSupported, expectedDelayed: true, expectedRetryIn: 40 * time.Second, expectedAttempts: 3},
+		{name: "dropped after the max attempts", attempts: domain.MaxDeliveryAttempts - 1, historyErr: errors.ErrNotSupported, expectedAttempts: domain.MaxDeliveryAttempts},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: domain.OnExceedDelay}
+			mockNotificationsContainer := &NotificationsContainerMock{
+				AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+					return &domain.AddNotificationResult{RejectedBy: rule}, nil
+				},
+				GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+					return []*domain.Notification{{Timestamp: time.Now().Add(-30 * time.Second)}}, tc.historyErr
+				},
+			}
+			mockRulesContainer := &RulesContainerMock{
+				GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+					return map[string][]*domain.RateLimitRule{"status": {rule}}, nil
+				},
+				GetUserTierFunc: func(userID string) (string, error) {
+					return "", nil
+				},
+			}
+			mockDelayedContainer := &DelayedNotificationsContainerMock{
+				AddDelayedNotificationFunc: func(notification *domain.DelayedNotification) error {
+					return nil
+				},
+			}
+
+			rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), mockDelayedContainer, &DigestsContainerMock{})
+			notification := &domain.DelayedNotification{ID: "1", UserID: "user1", NotificationType: "status", Attempts: tc.attempts}
+			decision, err := rateLimitService.SendDelayedNotification(notification)
+
+			assert.NoError(t, err)
+			assert.False(t, decision.Allowed)
+			assert.Equal(t, tc.expectedAttempts, notification.Attempts)
+			calls := mockDelayedContainer.AddDelayedNotificationCalls()
+			if !tc.expectedDelayed {
+				assert.Empty(t, calls)
+				assert.Nil(t, decision.DeliverAt)
+				return
+			}
+			assert.Len(t, calls, 1)
+			assert.WithinDuration(t, decision.Timestamp.Add(tc.expectedRetryIn), calls[0].Notification.DeliverAt, time.Second)
+			assert.Equal(t, calls[0].Notification.DeliverAt, *decision.DeliverAt)
+		})
+	}
+}
+
+// Meant to be run with -race: the limits of each user, with their tier, must hold when many requests
+// for the same users arrive concurrently.
+func TestRateLimitService_SendNotification_ConcurrentRequests(t *testing.T) {
+	rules := map[string][]*domain.RateLimitRule{
+		"status": {
+			{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Hour}},
+			{NotificationType: "status", MaxLimit: 8, TimeInterval: domain.Duration{Duration: time.Hour}, Tier: "premium"},
+		},
+		"news": {
+			{NotificationType: "news", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmGCRA},
+		},
+		"marketing": {
+			{NotificationType: "marketing", MaxLimit: 4, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmTokenBucket},
+			{NotificationType: "marketing", MaxLimit: 2, Window: domain.WindowDay},
+		},
+		"promotions": {
+			{NotificationType: "promotions", MaxLimit: 6, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmSlidingWindow},
+		},
+	}
+	tiers := map[string]string{"user3": "premium", "user4": domain.TierUnlimited}
+	expectedSent := map[string]map[string]int64{
+		"user1": {"status": 5, "news": 3, "marketing": 2, "promotions": 6},
+		"user2": {"status": 5, "news": 3, "marketing": 2, "promotions": 6},
+		// The premium tier only has its own status rule, and the default rules of the other types.
+		"user3": {"status": 8, "news": 3, "marketing": 2, "promotions": 6},
+		"user4": {"status": 50, "news": 50, "marketing": 50, "promotions": 50},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetUserTierFunc: func(userID string) (string, error) {
+			return tiers[userID], nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return rules, nil
+		},
+		GetUserQuietHoursFunc: func(userID string) (*domain.UserQuietHours, error) {
+			return nil, nil
+		},
+	}
+	rateLimitService := NewRateLimitService(notifications.NewInMemoryNotificationsContainer(), NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+
+	sent := map[string]*int64{}
+	for userID, userSent := range expectedSent {
+		for notificationType := range userSent {
+			sent[userID+":"+notificationType] = new(int64)
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for userID, userSent := range expectedSent {
+				for notificationType := range userSent {
+					decision, err := rateLimitService.SendNotification(domain.SendNotificationParams{
+						UserID:           userID,
+						NotificationType: notificationType,
+					})
+					require.NoError(t, err)
+					if decision.Allowed {
+						atomic.AddInt64(sent[userID+":"+notificationType], 1)
+					} else {
+						assert.NotNil(t, decision.BlockingRule)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for userID, userSent := range expectedSent {
+		for notificationType, expected := range userSent {
+			assert.Equal(t, expected, *sent[userID+":"+notificationType], "%s %s", userID, notificationType)
+		}
+	}
+}
+
+func TestRateLimitService_GetQuota(t *testing.T) {
+	now := time.Now()
+	mockNotificationsContainer := &NotificationsContainerMock{
+		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+			return []*domain.Notification{{Timestamp: now.Add(-10 * time.Second), UserID: params.UserID, Type: params.NotificationType}}, nil
+		},
+		GetRuleStateFunc: func(params domain.RuleStateParams) (*domain.RuleState, error) {
+			return &domain.RuleState{Tokens: 0, LastRefill: now}, nil
+		},
 	}
 	mockRulesContainer := &RulesContainerMock{
-		GetRuleByTypeFunc: func(s string) ([]*domain.RateLimitRule, error) {
-			return []*domain.RateLimitRule{
-				{
-					NotificationType: "news",
-					MaxLimit:         3,
-					TimeInterval:     domain.Duration{Duration: time.Second * 60},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"status": {
+					{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+					{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Hour}, Algorithm: domain.AlgorithmTokenBucket},
 				},
 			}, nil
 		},
 	}
 
-	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer))
-	err := rateLimitService.SendNotification(domain.SendNotificationParams{
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	quota, err := rateLimitService.GetQuota(domain.GetQuotaParams{
 		UserID:           "user1",
-		NotificationType: "email",
+		NotificationType: "status",
 	})
 
 	assert.NoError(t, err)
+	assert.Len(t, quota.Rules, 2)
+	assert.Equal(t, 1, quota.Rules[0].Remaining)
+	assert.Equal(t, 0, quota.Rules[1].Remaining)
+	assert.Len(t, mockNotificationsContainer.GetNotificationsByUserCalls(), 1)
+	assert.Equal(t, "status", mockNotificationsContainer.GetRuleStateCalls()[0].Params.NotificationType)
+}
+
+func TestRateLimitService_GetQuota_ErrorGetNotifications(t *testing.T) {
+	mockNotificationsContainer := &NotificationsContainerMock{
+		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+			return nil, errors.ErrNotSupported
+		},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"status": {
+					{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+				},
+			}, nil
+		},
+	}
+
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), &DelayedNotificationsContainerMock{}, &DigestsContainerMock{})
+	_, err := rateLimitService.GetQuota(domain.GetQuotaParams{
+		UserID:           "user1",
+		NotificationType: "status",
+	})
+
+	assert.ErrorIs(t, err, errors.ErrNotSupported)
 }
@@ -1,12 +1,29 @@
 package services
 
 import (
+	"fmt"
 	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"sort"
+	"strings"
 )
 
 type RulesContainer interface {
 	GetRules() (map[string][]*domain.RateLimitRule, error)
 	GetRuleByType(string) ([]*domain.RateLimitRule, error)
+	AddRule(rule *domain.RateLimitRule) error
+	UpdateRulesByType(notificationType string, rules []*domain.RateLimitRule) error
+	DeleteRulesByType(notificationType string) error
+	// GetUserTier returns the tier of the user, empty if it has none.
+	GetUserTier(userID string) (string, error)
+	SetUserTier(userID, tier string) error
+	// DeleteUserTier returns errors.ErrUserTierNotFound if the user has no tier.
+	DeleteUserTier(userID string) error
+	// GetUserQuietHours returns the quiet hours settings of the user, nil if it has none.
+	GetUserQuietHours(userID string) (*domain.UserQuietHours, error)
+	SetUserQuietHours(userQuietHours *domain.UserQuietHours) error
+	// DeleteUserQuietHours returns errors.ErrUserQuietHoursNotFound if the user has no settings.
+	DeleteUserQuietHours(userID string) error
 }
 
 type RulesService struct {
@@ -23,6 +40,164 @@ func (rs *RulesService) GetRules() (map[string][]*domain.RateLimitRule, error) {
 	return rs.rulesContainer.GetRules()
 }
 
-func (rs *RulesService) GetRuleByType(notificationType string) ([]*domain.RateLimitRule, error) {
-	return rs.rulesContainer.GetRuleByType(notificationType)
+// GetRuleByType returns the rules of the notification type that apply to the user, according to its
+// tier, or all the rules of the type when userID is empty.
+func (rs *RulesService) GetRuleByType(notificationType, userID string) ([]*domain.RateLimitRule, error) {
+	rules, err := rs.rulesContainer.GetRuleByType(notificationType)
+	if err != nil || userID == "" || len(rules) == 0 {
+		return rules, err
+	}
+
+	tier, err := rs.rulesContainer.GetUserTier(userID)
+	if err != nil {
+		return nil, err
+	}
+	return domain.TierRules(rules, tier), nil
+}
+
+// GetApplicableRules returns all the rules that limit the notifications of the type sent to the user:
+// the rules of the type, the ones of the groups the type belongs to and the global ones.
+func (rs *RulesService) GetApplicableRules(notificationType, userID string) ([]*domain.RateLimitRule, error) {
+	allRules, err := rs.rulesContainer.GetRules()
+	if err != nil {
+		return nil, err
+	}
+
+	groupTypes := []string{}
+	for ruleType, typeRules := range allRules {
+		if domain.IsGroupNotificationType(ruleType) && len(typeRules) > 0 && typeRules[0].AppliesTo(notificationType) {
+			groupTypes = append(groupTypes, ruleType)
+		}
+	}
+	sort.Strings(groupTypes)
+
+	ruleTypes := append([]string{notificationType}, groupTypes...)
+	ruleTypes = append(ruleTypes, domain.NotificationTypeAll)
+	rules := []*domain.RateLimitRule{}
+	for _, ruleType := range ruleTypes {
+		rules = append(rules, allRules[ruleType]...)
+	}
+	if len(rules) == 0 {
+		return rules, nil
+	}
+
+	tier, err := rs.rulesContainer.GetUserTier(userID)
+	if err != nil {
+		return nil, err
+	}
+	rules = []*domain.RateLimitRule{}
+	for _, ruleType := range ruleTypes {
+		rules = append(rules, domain.TierRules(allRules[ruleType], tier)...)
+	}
+	return rules, nil
+}
+
+func (rs *RulesService) AddRule(rule *domain.RateLimitRule) error {
+	rule.Normalize()
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if rule.IsGroup() {
+		groupRules, err := rs.rulesContainer.GetRuleByType(rule.NotificationType)
+		if err != nil {
+			return err
+		}
+		if err := domain.ValidateGroup(append(groupRules[:len(groupRules):len(groupRules)], rule)); err != nil {
+			return err
+		}
+	}
+	return rs.rulesContainer.AddRule(rule)
+}
+
+// UpdateRulesByType replaces all the rules of the notification type.
+func (rs *RulesService) UpdateRulesByType(notificationType string, rules []*domain.RateLimitRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("%w: at least one rule is mandatory", errors.ErrInvalidRule)
+	}
+
+	notificationType = strings.ToLower(notificationType)
+	for _, rule := range rules {
+		if rule == nil {
+			return fmt.Errorf("%w: rules can't be null", errors.ErrInvalidRule)
+		}
+		// The group of the rules is the one of the notification type.
+		rule.NotificationType = notificationType
+		rule.Group = ""
+		rule.Normalize()
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := domain.ValidateGroup(rules); err != nil {
+		return err
+	}
+	return rs.rulesContainer.UpdateRulesByType(notificationType, rules)
+}
+
+func (rs *RulesService) DeleteRulesByType(notificationType string) error {
+	return rs.rulesContainer.DeleteRulesByType(strings.ToLower(notificationType))
+}
+
+func (rs *RulesService) GetUserTier(userID string) (*domain.UserTier, error) {
+	tier, err := rs.rulesContainer.GetUserTier(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tier == "" {
+		return nil, errors.ErrUserTierNotFound
+	}
+	return &domain.UserTier{UserID: userID, Tier: tier}, nil
+}
+
+// SetUserTier assigns the user to a tier, which must have rules or be domain.TierUnlimited.
+func (rs *RulesService) SetUserTier(userTier *domain.UserTier) error {
+	userTier.Tier = strings.ToLower(userTier.Tier)
+	if userTier.Tier == "" {
+		return fmt.Errorf("%w: tier is mandatory", errors.ErrInvalidTier)
+	}
+	if userTier.Tier != domain.TierUnlimited {
+		rules, err := rs.rulesContainer.GetRules()
+		if err != nil {
+			return err
+		}
+		if !domain.HasTier(rules, userTier.Tier) {
+			return fmt.Errorf("%w: tier '%s' has no rules", errors.ErrInvalidTier, userTier.Tier)
+		}
+	}
+	return rs.rulesContainer.SetUserTier(userTier.UserID, userTier.Tier)
+}
+
+func (rs *RulesService) DeleteUserTier(userID string) error {
+	return rs.rulesContainer.DeleteUserTier(userID)
+}
+
+func (rs *RulesService) GetUserQuietHours(userID string) (*domain.UserQuietHours, error) {
+	userQuietHours, err := rs.rulesContainer.GetUserQuietHours(userID)
+	if err != nil {
+		return nil, err
+	}
+	if userQuietHours == nil {
+		return nil, errors.ErrUserQuietHoursNotFound
+	}
+	return userQuietHours, nil
+}
+
+// SetUserQuietHours stores the timezone of the user and its override of the quiet hours of the
+// rules.
+func (rs *RulesService) SetUserQuietHours(userQuietHours *domain.UserQuietHours) error {
+	if userQuietHours.Timezone != "" {
+		if _, err := domain.LoadLocation(userQuietHours.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone '%s'", errors.ErrInvalidQuietHours, userQuietHours.Timezone)
+		}
+	}
+	if userQuietHours.Override != nil {
+		if err := userQuietHours.Override.Validate(); err != nil {
+			return fmt.Errorf("%w: override %s", errors.ErrInvalidQuietHours, err)
+		}
+	}
+	return rs.rulesContainer.SetUserQuietHours(userQuietHours)
+}
+
+func (rs *RulesService) DeleteUserQuietHours(userID string) error {
+	return rs.rulesContainer.DeleteUserQuietHours(userID)
 }
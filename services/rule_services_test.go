@@ -3,6 +3,8 @@ package services
 import (
 	"fmt"
 	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -131,9 +133,497 @@ func TestNotificationService_GetRulesByType(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			service := &RulesService{rulesContainer: tc.mockedRulesContainer}
-			rule, err := service.GetRuleByType(tc.ruleType)
+			rule, err := service.GetRuleByType(tc.ruleType, "")
 			assert.Equal(t, tc.expectedErr, err)
 			assert.Equal(t, tc.expected, rule)
 		})
 	}
 }
+
+func TestRulesService_AddRule(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rule        *domain.RateLimitRule
+		expectedErr string
+	}{
+		{
+			name: "success",
+			rule: &domain.RateLimitRule{
+				NotificationType: "Status",
+				MaxLimit:         2,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+			},
+		},
+		{
+			name: "success calendar window",
+			rule: &domain.RateLimitRule{
+				NotificationType: "news",
+				MaxLimit:         1,
+				Window:           domain.WindowDay,
+				Timezone:         "Europe/Madrid",
+			},
+		},
+		{
+			name: "missing notification type",
+			rule: &domain.RateLimitRule{
+				MaxLimit:     2,
+				TimeInterval: domain.Duration{Duration: time.Minute},
+			},
+			expectedErr: "invalid rule: notificationType is mandatory",
+		},
+		{
+			name: "max limit not positive",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         0,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+			},
+			expectedErr: "invalid rule: maxLimit must be positive",
+		},
+		{
+			name: "zero time interval",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         2,
+			},
+			expectedErr: "invalid rule: timeInterval must be positive",
+		},
+		{
+			name: "unknown algorithm",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         2,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+				Algorithm:        "leaky_bucket",
+			},
+			expectedErr: "invalid rule: unknown algorithm 'leaky_bucket'",
+		},
+		{
+			name: "calendar window with token bucket",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         2,
+				Window:           domain.WindowWeek,
+				Algorithm:        domain.AlgorithmTokenBucket,
+			},
+			expectedErr: "invalid rule: window is only supported by sliding_log rules",
+		},
+		{
+			name: "unknown timezone",
+			rule: &domain.RateLimitRule{
+				NotificationType: "news",
+				MaxLimit:         1,
+				Window:           domain.WindowDay,
+				Timezone:         "Mars/Olympus_Mons",
+			},
+			expectedErr: "invalid rule: unknown timezone 'Mars/Olympus_Mons'",
+		},
+		{
+			name: "success shadow mode",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         1,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+				Mode:             domain.ModeShadow,
+			},
+		},
+		{
+			name: "unknown mode",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         1,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+				Mode:             "dry_run",
+			},
+			expectedErr: "invalid rule: unknown mode 'dry_run'",
+		},
+		{
+			name: "rule of the unlimited tier",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         1,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+				Tier:             "Unlimited",
+			},
+			expectedErr: "invalid rule: the unlimited tier can't have rules",
+		},
+		{
+			name: "success group",
+			rule: &domain.RateLimitRule{
+				Group:             "Promotional",
+				NotificationTypes: []string{"newsletters", "Marketing"},
+				MaxLimit:          5,
+				TimeInterval:      domain.Duration{Duration: 24 * time.Hour},
+			},
+		},
+		{
+			name: "group without notification types",
+			rule: &domain.RateLimitRule{
+				Group:        "promotional",
+				MaxLimit:     5,
+				TimeInterval: domain.Duration{Duration: 24 * time.Hour},
+			},
+			expectedErr: "invalid rule: notificationTypes is mandatory in group rules",
+		},
+		{
+			name: "group with other notification types than its rules",
+			rule: &domain.RateLimitRule{
+				Group:             "promotional",
+				NotificationTypes: []string{"marketing"},
+				MaxLimit:          5,
+				TimeInterval:      domain.Duration{Duration: 24 * time.Hour},
+			},
+			expectedErr: "invalid rule: the rules of group 'promotional' must have the same notificationTypes",
+		},
+		{
+			name: "group with the global type",
+			rule: &domain.RateLimitRule{
+				Group:             "promotional",
+				NotificationTypes: []string{"marketing", domain.NotificationTypeAll},
+				MaxLimit:          5,
+				TimeInterval:      domain.Duration{Duration: 24 * time.Hour},
+			},
+			expectedErr: "invalid rule: invalid notification type '*' in group 'promotional'",
+		},
+		{
+			name: "success quiet hours",
+			rule: &domain.RateLimitRule{
+				NotificationType: "marketing",
+				QuietHours:       &domain.QuietHours{Start: "22:00", End: "08:00"},
+			},
+		},
+		{
+			name: "quiet hours with limits",
+			rule: &domain.RateLimitRule{
+				NotificationType: "marketing",
+				MaxLimit:         5,
+				QuietHours:       &domain.QuietHours{Start: "22:00", End: "08:00"},
+			},
+			expectedErr: "invalid rule: quietHours rules can't have limits",
+		},
+		{
+			name: "invalid quiet hours",
+			rule: &domain.RateLimitRule{
+				NotificationType: "marketing",
+				QuietHours:       &domain.QuietHours{Start: "22:00", End: "8am"},
+			},
+			expectedErr: "invalid rule: quietHours end must be a time of the day as HH:MM",
+		},
+		{
+			name: "success delay on exceed",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         1,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+				OnExceed:         domain.OnExceedDelay,
+			},
+		},
+		{
+			name: "unknown on exceed",
+			rule: &domain.RateLimitRule{
+				NotificationType: "status",
+				MaxLimit:         1,
+				TimeInterval:     domain.Duration{Duration: time.Minute},
+				OnExceed:         "retry",
+			},
+			expectedErr: "invalid rule: unknown onExceed 'retry'",
+		},
+		{
+			name: "time interval with window",
+			rule: &domain.RateLimitRule{
+				NotificationType: "news",
+				MaxLimit:         1,
+				TimeInterval:     domain.Duration{Duration: 24 * time.Hour},
+				Window:           domain.WindowDay,
+			},
+			expectedErr: "invalid rule: timeInterval can't be set with a calendar window",
+		},
+	}
+
+	groupRule := &domain.RateLimitRule{
+		NotificationType:  domain.GroupNotificationType("promotional"),
+		Group:             "promotional",
+		NotificationTypes: []string{"marketing", "newsletters"},
+		MaxLimit:          10,
+		TimeInterval:      domain.Duration{Duration: 7 * 24 * time.Hour},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockedRulesContainer := &RulesContainerMock{
+				AddRuleFunc: func(rule *domain.RateLimitRule) error {
+					return nil
+				},
+				GetRuleByTypeFunc: func(notificationType string) ([]*domain.RateLimitRule, error) {
+					if notificationType == groupRule.NotificationType {
+						return []*domain.RateLimitRule{groupRule}, nil
+					}
+					return nil, nil
+				},
+			}
+			service := NewRulesService(mockedRulesContainer)
+
+			err := service.AddRule(tc.rule)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.True(t, errors.IsInvalidRuleError(err))
+				assert.Empty(t, mockedRulesContainer.AddRuleCalls())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, mockedRulesContainer.AddRuleCalls(), 1)
+			assert.Equal(t, strings.ToLower(tc.rule.NotificationType), mockedRulesContainer.AddRuleCalls()[0].Rule.NotificationType)
+		})
+	}
+}
+
+func TestRulesService_UpdateRulesByType(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rules       []*domain.RateLimitRule
+		expectedErr string
+	}{
+		{
+			name: "success",
+			rules: []*domain.RateLimitRule{
+				{NotificationType: "other", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+				{MaxLimit: 10, TimeInterval: domain.Duration{Duration: time.Hour}},
+			},
+		},
+		{
+			name:        "no rules",
+			rules:       []*domain.RateLimitRule{},
+			expectedErr: "invalid rule: at least one rule is mandatory",
+		},
+		{
+			name: "invalid rule",
+			rules: []*domain.RateLimitRule{
+				{MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+				{MaxLimit: -1, TimeInterval: domain.Duration{Duration: time.Hour}},
+			},
+			expectedErr: "invalid rule: maxLimit must be positive",
+		},
+		{
+			name: "notification types of a type that is not a group",
+			rules: []*domain.RateLimitRule{
+				{NotificationTypes: []string{"marketing"}, MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}},
+			},
+			expectedErr: "invalid rule: notificationTypes is only supported by group rules",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockedRulesContainer := &RulesContainerMock{
+				UpdateRulesByTypeFunc: func(notificationType string, rules []*domain.RateLimitRule) error {
+					return nil
+				},
+			}
+			service := NewRulesService(mockedRulesContainer)
+
+			err := service.UpdateRulesByType("Status", tc.rules)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Empty(t, mockedRulesContainer.UpdateRulesByTypeCalls())
+				return
+			}
+			assert.NoError(t, err)
+			calls := mockedRulesContainer.UpdateRulesByTypeCalls()
+			assert.Len(t, calls, 1)
+			assert.Equal(t, "status", calls[0].NotificationType)
+			for _, rule := range calls[0].Rules {
+				assert.Equal(t, "status", rule.NotificationType)
+			}
+		})
+	}
+}
+
+func TestRulesService_DeleteRulesByType(t *testing.T) {
+	mockedRulesContainer := &RulesContainerMock{
+		DeleteRulesByTypeFunc: func(notificationType string) error {
+			if notificationType != "status" {
+				return errors.ErrRuleNotFound
+			}
+			return nil
+		},
+	}
+	service := NewRulesService(mockedRulesContainer)
+
+	assert.NoError(t, service.DeleteRulesByType("Status"))
+	assert.Equal(t, errors.ErrRuleNotFound, service.DeleteRulesByType("news"))
+}
+
+func TestRulesService_GetRuleByType_UserTier(t *testing.T) {
+	defaultRule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}
+	vipRule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Minute}, Tier: "vip"}
+	userTiers := map[string]string{"vip_user": "vip", "test_user": domain.TierUnlimited, "partner_user": "partner"}
+	mockedRulesContainer := &RulesContainerMock{
+		GetRuleByTypeFunc: func(notificationType string) ([]*domain.RateLimitRule, error) {
+			return []*domain.RateLimitRule{defaultRule, vipRule}, nil
+		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return userTiers[userID], nil
+		},
+	}
+	service := NewRulesService(mockedRulesContainer)
+
+	testCases := []struct {
+		name     string
+		userID   string
+		expected []*domain.RateLimitRule
+	}{
+		{name: "all the rules without user", userID: "", expected: []*domain.RateLimitRule{defaultRule, vipRule}},
+		{name: "user without tier", userID: "user1", expected: []*domain.RateLimitRule{defaultRule}},
+		{name: "user with tier", userID: "vip_user", expected: []*domain.RateLimitRule{vipRule}},
+		{name: "tier without rules of the type", userID: "partner_user", expected: []*domain.RateLimitRule{defaultRule}},
+		{name: "unlimited tier", userID: "test_user", expected: []*domain.RateLimitRule{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := service.GetRuleByType("status", tc.userID)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, rules)
+		})
+	}
+}
+
+func TestRulesService_GetApplicableRules(t *testing.T) {
+	statusRule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 2, TimeInterval: domain.Duration{Duration: time.Minute}}
+	marketingRule := &domain.RateLimitRule{NotificationType: "marketing", MaxLimit: 3, TimeInterval: domain.Duration{Duration: time.Hour}}
+	promotionalRule := &domain.RateLimitRule{
+		NotificationType:  domain.GroupNotificationType("promotional"),
+		Group:             "promotional",
+		NotificationTypes: []string{"marketing", "newsletters"},
+		MaxLimit:          5,
+		TimeInterval:      domain.Duration{Duration: 24 * time.Hour},
+	}
+	vipPromotionalRule := &domain.RateLimitRule{
+		NotificationType:  domain.GroupNotificationType("promotional"),
+		Group:             "promotional",
+		NotificationTypes: []string{"marketing", "newsletters"},
+		MaxLimit:          20,
+		TimeInterval:      domain.Duration{Duration: 24 * time.Hour},
+		Tier:              "vip",
+	}
+	globalRule := &domain.RateLimitRule{NotificationType: domain.NotificationTypeAll, MaxLimit: 50, TimeInterval: domain.Duration{Duration: 24 * time.Hour}}
+	mockedRulesContainer := &RulesContainerMock{
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{
+				"status":                         {statusRule},
+				"marketing":                      {marketingRule},
+				promotionalRule.NotificationType: {promotionalRule, vipPromotionalRule},
+				domain.NotificationTypeAll:       {globalRule},
+			}, nil
+		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			if userID == "vip_user" {
+				return "vip", nil
+			}
+			return "", nil
+		},
+	}
+	service := NewRulesService(mockedRulesContainer)
+
+	testCases := []struct {
+		name             string
+		notificationType string
+		userID           string
+		expected         []*domain.RateLimitRule
+	}{
+		{name: "type outside the groups", notificationType: "status", userID: "user1", expected: []*domain.RateLimitRule{statusRule, globalRule}},
+		{name: "type of a group", notificationType: "marketing", userID: "user1", expected: []*domain.RateLimitRule{marketingRule, promotionalRule, globalRule}},
+		{name: "type of a group without rules", notificationType: "newsletters", userID: "user1", expected: []*domain.RateLimitRule{promotionalRule, globalRule}},
+		{name: "tier rules of the group", notificationType: "newsletters", userID: "vip_user", expected: []*domain.RateLimitRule{vipPromotionalRule, globalRule}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := service.GetApplicableRules(tc.notificationType, tc.userID)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, rules)
+		})
+	}
+}
+
+func TestRulesService_SetUserTier(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tier        string
+		expectedErr string
+	}{
+		{name: "success", tier: "VIP"},
+		{name: "unlimited tier", tier: domain.TierUnlimited},
+		{name: "missing tier", tier: "", expectedErr: "invalid tier: tier is mandatory"},
+		{name: "tier without rules", tier: "gold", expectedErr: "invalid tier: tier 'gold' has no rules"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockedRulesContainer := &RulesContainerMock{
+				GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+					return map[string][]*domain.RateLimitRule{
+						"status": {{NotificationType: "status", MaxLimit: 5, TimeInterval: domain.Duration{Duration: time.Minute}, Tier: "vip"}},
+					}, nil
+				},
+				SetUserTierFunc: func(userID, tier string) error {
+					return nil
+				},
+			}
+			service := NewRulesService(mockedRulesContainer)
+
+			err := service.SetUserTier(&domain.UserTier{UserID: "user1", Tier: tc.tier})
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.True(t, errors.IsInvalidTierError(err))
+				assert.Empty(t, mockedRulesContainer.SetUserTierCalls())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, strings.ToLower(tc.tier), mockedRulesContainer.SetUserTierCalls()[0].Tier)
+		})
+	}
+}
+
+func TestRulesService_SetUserQuietHours(t *testing.T) {
+	testCases := []struct {
+		name           string
+		userQuietHours *domain.UserQuietHours
+		expectedErr    string
+	}{
+		{
+			name:           "success",
+			userQuietHours: &domain.UserQuietHours{UserID: "user1", Timezone: "Europe/Madrid", Override: &domain.QuietHours{Start: "23:00", End: "09:00"}},
+		},
+		{
+			name:           "unknown timezone",
+			userQuietHours: &domain.UserQuietHours{UserID: "user1", Timezone: "Mars/Olympus_Mons"},
+			expectedErr:    "invalid quiet hours: unknown timezone 'Mars/Olympus_Mons'",
+		},
+		{
+			name:           "invalid override",
+			userQuietHours: &domain.UserQuietHours{UserID: "user1", Override: &domain.QuietHours{Start: "23:00", End: "23:00"}},
+			expectedErr:    "invalid quiet hours: override start and end can't be the same time",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockedRulesContainer := &RulesContainerMock{
+				SetUserQuietHoursFunc: func(userQuietHours *domain.UserQuietHours) error {
+					return nil
+				},
+			}
+			service := NewRulesService(mockedRulesContainer)
+
+			err := service.SetUserQuietHours(tc.userQuietHours)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.True(t, errors.IsInvalidQuietHoursError(err))
+				assert.Empty(t, mockedRulesContainer.SetUserQuietHoursCalls())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, mockedRulesContainer.SetUserQuietHoursCalls(), 1)
+		})
+	}
+}
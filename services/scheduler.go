@@ -0,0 +1,163 @@
+package services
+
+import (
+	"fmt"
+	"rate-limiter/domain"
+	"sync"
+	"time"
+)
+
+// schedulerBatchSize is how many delayed notifications or digests are taken from the storage at once.
+const schedulerBatchSize = 100
+
+// Scheduler delivers the delayed notifications through the rate limit service once they are due,
+// so they are checked by the rules again, and sends the digests due.
+//
+// The notifications and digests are removed from the storage before they are sent, so the ones
+// taken by a replica that stops before sending them are lost.
+type Scheduler struct {
+	delayedContainer DelayedNotificationsContainer
+	digestsContainer DigestsContainer
+	rateLimitService *RateLimitService
+	stop             chan struct{}
+	stopOnce         sync.Once
+}
+
+func NewScheduler(delayedContainer DelayedNotificationsContainer, digestsContainer DigestsContainer, rateLimitService *RateLimitService) *Scheduler {
+	return &Scheduler{
+		delayedContainer: delayedContainer,
+		digestsContainer: digestsContainer,
+		rateLimitService: rateLimitService,
+		stop:             make(chan struct{}),
+	}
+}
+
+// DeliverDue sends the notifications and the digests due at now, and returns how many were
+// delivered. The notifications the rules still don't allow are delayed again by the rate limit
+// service.
+func (s *Scheduler) DeliverDue(now time.Time) (int, error) {
+	delivered, err := s.deliverDueNotifications(now)
+	if err != nil {
+		return delivered, err
+	}
+	digests, err := s.deliverDueDigests(now)
+	return delivered + digests, err
+}
+
+func (s *Scheduler) deliverDueNotifications(now time.Time) (int, error) {
+	delivered := 0
+	for {
+		notifications, err := s.delayedContainer.PopDueNotifications(now, schedulerBatchSize)
+		if err != nil {
+			return delivered, err
+		}
+		// The notifications of the batch are already removed from the storage, so an error doesn't
+		// stop the delivery of the rest.
+		for _, notification := range notifications {
+			sent, err := s.deliver(notification)
+			if err != nil {
+				fmt.Printf("Scheduler - error delaying notification %s again: %s\n", notification.ID, err)
+				continue
+			}
+			if sent {
+				delivered++
+			}
+		}
+		if len(notifications) < schedulerBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// deliver sends the notification, and stores it again to be retried after a backoff when it can't
+// be checked, up to domain.MaxDeliveryAttempts times. The notifications delayed again are due after
+// the now of the current run, so a run doesn't take them again.
+func (s *Scheduler) deliver(notification *domain.DelayedNotification) (bool, error) {
+	decision, err := s.rateLimitService.SendDelayedNotification(notification)
+	if err != nil {
+		fmt.Printf("Scheduler - error sending delayed notification %s: %s\n", notification.ID, err)
+		notification.Attempts++
+		if notification.AttemptsExhausted() {
+			fmt.Printf("Scheduler - delayed notification %s dropped after %d attempts\n", notification.ID, notification.Attempts)
+			return false, nil
+		}
+		notification.DeliverAt = time.Now().Add(notification.RetryBackoff())
+		return false, s.delayedContainer.AddDelayedNotification(notification)
+	}
+	if !decision.Allowed && decision.DeliverAt == nil {
+		fmt.Printf("Scheduler - delayed notification %s rejected by rule %s\n", notification.ID, decision.BlockingRule.Key())
+	}
+	return decision.Allowed, nil
+}
+
+func (s *Scheduler) deliverDueDigests(now time.Time) (int, error) {
+	delivered := 0
+	for {
+		digests, err := s.digestsContainer.PopDueDigests(now, schedulerBatchSize)
+		if err != nil {
+			return delivered, err
+		}
+		// Like the notifications, the digests of the batch are already removed from the storage.
+		for _, digest := range digests {
+			sent, err := s.deliverDigest(digest)
+			if err != nil {
+				fmt.Printf("Scheduler - error storing digest %s again: %s\n", digest.Key(), err)
+				continue
+			}
+			if sent {
+				delivered++
+			}
+		}
+		if len(digests) < schedulerBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// deliverDigest sends the digest, and stores it again to be retried after a backoff when it can't
+// be checked, up to domain.MaxDeliveryAttempts times. The digests the rules reject are stored again
+// by the rate limit service.
+func (s *Scheduler) deliverDigest(digest *domain.Digest) (bool, error) {
+	decision, err := s.rateLimitService.SendDigest(digest)
+	if err != nil {
+		fmt.Printf("Scheduler - error sending digest %s: %s\n", digest.Key(), err)
+		digest.Attempts++
+		if digest.AttemptsExhausted() {
+			fmt.Printf("Scheduler - digest %s of %d notifications dropped after %d attempts\n", digest.Key(), digest.Count, digest.Attempts)
+			return false, nil
+		}
+		digest.DeliverAt = time.Now().Add(digest.RetryBackoff())
+		_, err := s.digestsContainer.AddToDigest(digest)
+		return false, err
+	}
+	return decision.Allowed, nil
+}
+
+// Start runs DeliverDue every interval.
+func (s *Scheduler) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				delivered, err := s.DeliverDue(now)
+				if err != nil {
+					fmt.Println("Scheduler - error delivering delayed notifications:", err)
+				}
+				if delivered > 0 {
+					fmt.Printf("Scheduler - delivered %d delayed notifications and digests\n", delivered)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler started by Start.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
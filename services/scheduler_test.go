@@ -0,0 +1,284 @@
+package services
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"rate-limiter/dao/delayed"
+	"rate-limiter/domain"
+	"rate-limiter/errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_DeliverDue(t *testing.T) {
+	now := time.Now()
+	rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: domain.OnExceedDelay}
+	allowed := map[string]bool{"user1": true, "user2": false}
+	mockNotificationsContainer := &NotificationsContainerMock{
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			if allowed[params.UserID] {
+				return &domain.AddNotificationResult{}, nil
+			}
+			return &domain.AddNotificationResult{RejectedBy: rule}, nil
+		},
+		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+			return []*domain.Notification{{Timestamp: now}}, nil
+		},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{"status": {rule}}, nil
+		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+	}
+	delayedContainer := delayed.NewInMemoryContainer()
+	digestsContainer := delayed.NewInMemoryDigestsContainer()
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), delayedContainer, digestsContainer)
+	scheduler := NewScheduler(delayedContainer, digestsContainer, rateLimitService)
+
+	for _, userID := range []string{"user1", "user2"} {
+		require.NoError(t, delayedContainer.AddDelayedNotification(&domain.DelayedNotification{
+			ID:               userID,
+			UserID:           userID,
+			NotificationType: "status",
+			CreatedAt:        now.Add(-time.Minute),
+			DeliverAt:        now.Add(-time.Second),
+		}))
+	}
+	require.NoError(t, delayedContainer.AddDelayedNotification(&domain.DelayedNotification{
+		ID:               "future",
+		UserID:           "user1",
+		NotificationType: "status",
+		DeliverAt:        now.Add(time.Hour),
+	}))
+
+	for _, deliverAt := range []time.Time{now, now.Add(time.Hour)} {
+		_, err := digestsContainer.AddToDigest(domain.NewDigest(&domain.DelayedNotification{
+			UserID:           "user1",
+			NotificationType: "status",
+			CreatedAt:        deliverAt.Add(-time.Minute),
+		}, deliverAt))
+		require.NoError(t, err)
+		_, err = digestsContainer.AddToDigest(domain.NewDigest(&domain.DelayedNotification{
+			UserID:           "user2",
+			NotificationType: "status",
+			CreatedAt:        deliverAt.Add(-time.Minute),
+		}, deliverAt.Add(time.Hour)))
+		require.NoError(t, err)
+	}
+
+	delivered, err := scheduler.DeliverDue(now)
+	require.NoError(t, err)
+	// The notification of user1, and the digest of user1 due at now, which has both notifications.
+	assert.Equal(t, 2, delivered)
+	digests, err := digestsContainer.GetDigests()
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	assert.Equal(t, "user2", digests[0].UserID)
+	assert.Equal(t, 2, digests[0].Count)
+
+	// The notification the rule still rejects is delayed again, and the one not due yet is kept.
+	pending, err := delayedContainer.GetDelayedNotifications()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "user2", pending[0].ID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.True(t, pending[0].DeliverAt.After(now))
+	assert.Equal(t, "future", pending[1].ID)
+}
+
+func TestScheduler_DeliverDue_ErrorSendNotification(t *testing.T) {
+	now := time.Now()
+	rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: domain.OnExceedDelay}
+	mockNotificationsContainer := &NotificationsContainerMock{
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			return nil, stderrors.New("storage unavailable")
+		},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{"status": {rule}}, nil
+		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+	}
+	delayedContainer := delayed.NewInMemoryContainer()
+	digestsContainer := delayed.NewInMemoryDigestsContainer()
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), delayedContainer, digestsContainer)
+	scheduler := NewScheduler(delayedContainer, digestsContainer, rateLimitService)
+
+	// The notification with one attempt left is dropped when it fails again.
+	for id, attempts := range map[string]int{"retried": 1, "dropped": domain.MaxDeliveryAttempts - 1} {
+		require.NoError(t, delayedContainer.AddDelayedNotification(&domain.DelayedNotification{
+			ID:               id,
+			UserID:           "user1",
+			NotificationType: "status",
+			DeliverAt:        now.Add(-time.Second),
+			Attempts:         attempts,
+		}))
+	}
+
+	_, err := digestsContainer.AddToDigest(domain.NewDigest(&domain.DelayedNotification{
+		UserID:           "user1",
+		NotificationType: "status",
+		CreatedAt:        now.Add(-time.Minute),
+	}, now.Add(-time.Second)))
+	require.NoError(t, err)
+
+	delivered, err := scheduler.DeliverDue(now)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+
+	digests, err := digestsContainer.GetDigests()
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	assert.Equal(t, 1, digests[0].Attempts)
+	assert.WithinDuration(t, now.Add(10*time.Second), digests[0].DeliverAt, time.Second)
+
+	// The notification that can't be checked is retried after a backoff, not on the next run, until
+	// it runs out of attempts.
+	pending, err := delayedContainer.GetDelayedNotifications()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "retried", pending[0].ID)
+	assert.Equal(t, 2, pending[0].Attempts)
+	assert.WithinDuration(t, now.Add(20*time.Second), pending[0].DeliverAt, time.Second)
+}
+
+func TestScheduler_DeliverDue_ErrorStoringAgain(t *testing.T) {
+	now := time.Now()
+	rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Minute}, OnExceed: domain.OnExceedDelay}
+	mockNotificationsContainer := &NotificationsContainerMock{
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			if params.UserID == "user1" {
+				return nil, stderrors.New("storage unavailable")
+			}
+			return &domain.AddNotificationResult{}, nil
+		},
+		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+			return nil, nil
+		},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{"status": {rule}}, nil
+		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+	}
+	mockDelayedContainer := &DelayedNotificationsContainerMock{
+		PopDueNotificationsFunc: func(now time.Time, limit int) ([]*domain.DelayedNotification, error) {
+			return []*domain.DelayedNotification{
+				{ID: "1", UserID: "user1", NotificationType: "status", DeliverAt: now},
+				{ID: "2", UserID: "user2", NotificationType: "status", DeliverAt: now},
+			}, nil
+		},
+		AddDelayedNotificationFunc: func(notification *domain.DelayedNotification) error {
+			return stderrors.New("storage unavailable")
+		},
+	}
+	mockDigestsContainer := &DigestsContainerMock{
+		PopDueDigestsFunc: func(now time.Time, limit int) ([]*domain.Digest, error) {
+			return []*domain.Digest{
+				{UserID: "user1", NotificationType: "status", Count: 1, DeliverAt: now},
+				{UserID: "user2", NotificationType: "status", Count: 1, DeliverAt: now},
+			}, nil
+		},
+		AddToDigestFunc: func(digest *domain.Digest) (*domain.Digest, error) {
+			return nil, stderrors.New("storage unavailable")
+		},
+	}
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), mockDelayedContainer, mockDigestsContainer)
+	scheduler := NewScheduler(mockDelayedContainer, mockDigestsContainer, rateLimitService)
+
+	// The notification and the digest that can't be stored again don't stop the delivery of the rest
+	// of their batch.
+	delivered, err := scheduler.DeliverDue(now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	calls := mockDelayedContainer.AddDelayedNotificationCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "1", calls[0].Notification.ID)
+	digestCalls := mockDigestsContainer.AddToDigestCalls()
+	require.Len(t, digestCalls, 1)
+	assert.Equal(t, "user1", digestCalls[0].Digest.UserID)
+}
+
+func TestScheduler_DeliverDue_Digests(t *testing.T) {
+	now := time.Now()
+	rule := &domain.RateLimitRule{NotificationType: "status", MaxLimit: 1, TimeInterval: domain.Duration{Duration: time.Hour}, OnExceed: domain.OnExceedDigest}
+	allowed := map[string]bool{"user1": true, "user2": false}
+	mockNotificationsContainer := &NotificationsContainerMock{
+		AddNotificationIfAllowedFunc: func(params domain.AddNotificationParams) (*domain.AddNotificationResult, error) {
+			if allowed[params.UserID] {
+				return &domain.AddNotificationResult{}, nil
+			}
+			return &domain.AddNotificationResult{RejectedBy: rule}, nil
+		},
+		// Like memcached, the storage can't tell when the rule frees a slot.
+		GetNotificationsByUserFunc: func(params domain.GetNotificationParams) ([]*domain.Notification, error) {
+			return nil, errors.ErrNotSupported
+		},
+	}
+	mockRulesContainer := &RulesContainerMock{
+		GetRulesFunc: func() (map[string][]*domain.RateLimitRule, error) {
+			return map[string][]*domain.RateLimitRule{"status": {rule}}, nil
+		},
+		GetUserTierFunc: func(userID string) (string, error) {
+			return "", nil
+		},
+	}
+	delayedContainer := delayed.NewInMemoryContainer()
+	digestsContainer := delayed.NewInMemoryDigestsContainer()
+	rateLimitService := NewRateLimitService(mockNotificationsContainer, NewRulesService(mockRulesContainer), delayedContainer, digestsContainer)
+	mockCommunicationClient := &CommunicationClientMock{
+		SendFunc: func(userID string, payload json.RawMessage) error {
+			return nil
+		},
+	}
+	rateLimitService.communicationClient = mockCommunicationClient
+	scheduler := NewScheduler(delayedContainer, digestsContainer, rateLimitService)
+
+	for _, userID := range []string{"user1", "user2"} {
+		for _, payload := range []json.RawMessage{json.RawMessage(`{"id":1}`), nil} {
+			_, err := digestsContainer.AddToDigest(domain.NewDigest(&domain.DelayedNotification{
+				UserID:           userID,
+				NotificationType: "status",
+				Payload:          payload,
+				CreatedAt:        now.Add(-time.Minute),
+			}, now.Add(-time.Second)))
+			require.NoError(t, err)
+		}
+	}
+
+	delivered, err := scheduler.DeliverDue(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	// The digests are checked by the rules like any other notification.
+	assert.Len(t, mockNotificationsContainer.AddNotificationIfAllowedCalls(), 2)
+	// The digest is sent with the count and the payloads of its notifications.
+	sends := mockCommunicationClient.SendCalls()
+	require.Len(t, sends, 1)
+	assert.Equal(t, "user1", sends[0].UserID)
+	assert.JSONEq(t, `{"count":2,"payloads":[{"id":1}]}`, string(sends[0].Payload))
+
+	// The digest the rule rejects waits for the end of its window, not for the next run.
+	digests, err := digestsContainer.GetDigests()
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	assert.Equal(t, "user2", digests[0].UserID)
+	assert.True(t, digests[0].DeliverAt.After(now))
+	assert.Equal(t, digests[0].DeliverAt.Truncate(time.Hour), digests[0].DeliverAt)
+
+	delivered, err = scheduler.DeliverDue(now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Len(t, mockNotificationsContainer.AddNotificationIfAllowedCalls(), 2)
+}
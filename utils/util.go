@@ -4,25 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
-func LoadRulesFile() []byte {
-	fileData, err := os.ReadFile("./dao/rules/rules.json")
+const RulesFilePath = "./dao/rules/rules.json"
+
+func LoadRulesFile(filePath string) ([]byte, error) {
+	fileData, err := os.ReadFile(filePath)
 	if err != nil {
-		fmt.Println("error reading from rules.json file:", err)
+		return nil, fmt.Errorf("error reading from rules.json file: %w", err)
 	}
-	return fileData
+	return fileData, nil
 }
 
+// FormatDuration returns the duration like time.Duration.String without the trailing zero units,
+// e.g. "1h" or "1h30m", so it is parsed back by time.ParseDuration to the same duration.
 func FormatDuration(d time.Duration) string {
-	if d.Hours() >= 1 {
-		return fmt.Sprintf("%.0fh", d.Hours())
-	} else if d.Minutes() >= 1 {
-		return fmt.Sprintf("%.0fm", d.Minutes())
-	} else {
-		return fmt.Sprintf("%.0fs", d.Seconds())
+	formatted := d.String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
 	}
+	return formatted
 }
 
 func SerializeObject(object interface{}) string {